@@ -0,0 +1,141 @@
+// Package ad implements the forward mode automatic differentiation of the rate
+// functions, with dual numbers: the derivatives are computed exactly by the
+// chain rule while evaluating the function, instead of being approximated by
+// finite differences (see the package jacobian), whose precision is lost for
+// the functions with large variations (e.g. exponential terms).
+//
+// The rate function is written once as a generic Function, whose arithmetic
+// is defined by the methods of the interface Number:
+//
+//	func rate[T ad.Number[T]](t float64, X []T, dXdt []T) error {
+//		dXdt[0] = X[1]
+//		dXdt[1] = X[0].Exp().Neg()
+//		return nil
+//	}
+//
+// and it is instantiated with the real numbers (rate[ad.Real]) for the
+// solvers (see InPlace), and with the dual numbers (rate[ad.Dual]) for the
+// Jacobian matrices and the directional derivatives (see Differentiator),
+// e.g. for the implicit solvers, the sensitivity analysis and the stability
+// analysis.
+package ad
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// Function is the generic form of a solver.InPlaceFunction, for the numbers
+// of type T
+type Function[T Number[T]] func(t float64, X []T, dXdt []T) error
+
+// InPlace returns the solver.InPlaceFunction that evaluates f with the real
+// numbers. The function uses its own workspace, and it should not be called
+// concurrently.
+func InPlace(f Function[Real]) solver.InPlaceFunction {
+	var x, dx []Real
+	return func(t float64, X []float64, dXdt []float64) error {
+		if len(x) != len(X) {
+			x, dx = make([]Real, len(X)), make([]Real, len(X))
+		}
+		for i, v := range X {
+			x[i] = Real(v)
+		}
+		if err := f(t, x, dx); err != nil {
+			return err
+		}
+		for i, v := range dx {
+			dXdt[i] = float64(v)
+		}
+		return nil
+	}
+}
+
+// Differentiator computes the exact derivatives of a function evaluated with
+// the dual numbers. Its method Jacobian is a solver.JacobianFunction. A
+// Differentiator uses its own workspace, and it should not be used
+// concurrently.
+type Differentiator struct {
+	f     Function[Dual]
+	x, dx []Dual
+	evals uint64
+}
+
+// NewDifferentiator creates a differentiator of the function f
+func NewDifferentiator(f Function[Dual]) (*Differentiator, error) {
+	if f == nil {
+		err := errors.New("the function f is not defined")
+		return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+	}
+	return &Differentiator{f: f}, nil
+}
+
+// eval evaluates the function f at (t,X) in the direction V (nil for the
+// column col of the identity matrix)
+func (d *Differentiator) eval(t float64, X, V []float64, col int) error {
+	n := len(X)
+	if len(d.x) != n {
+		d.x, d.dx = make([]Dual, n), make([]Dual, n)
+	}
+	for i, v := range X {
+		d.x[i] = Dual{Re: v}
+		if V != nil {
+			d.x[i].Eps = V[i]
+		} else if i == col {
+			d.x[i].Eps = 1
+		}
+	}
+	d.evals++
+	return d.f(t, d.x, d.dx)
+}
+
+// Directional computes the value F=f(t,X) and the directional derivative
+// JV=J.V of f at (t,X) in the direction V, where J is the Jacobian matrix of
+// f, with one evaluation of f. The slice F can be nil if the value is not
+// needed.
+func (d *Differentiator) Directional(t float64, X, V []float64, F, JV []float64) error {
+	if len(V) != len(X) || len(JV) != len(X) || (F != nil && len(F) != len(X)) {
+		err := fmt.Errorf("the vectors should have the size %d of the state", len(X))
+		return core.NewError(solver.ErrDimensionMismatch, err, t, X, 0)
+	}
+	if err := d.eval(t, X, V, -1); err != nil {
+		return err
+	}
+	for i, y := range d.dx {
+		if F != nil {
+			F[i] = y.Re
+		}
+		JV[i] = y.Eps
+	}
+	return nil
+}
+
+// Jacobian computes the Jacobian matrix J of f at (t,X), column by column,
+// i.e. with n evaluations of f for a state of size n. The matrix J should have
+// the size n x n (as given by the solvers).
+func (d *Differentiator) Jacobian(t float64, X []float64, J *linalg.Matrix) error {
+	n := len(X)
+	if J.Rows != n || J.Cols != n {
+		err := fmt.Errorf("the matrix of size %dx%d does not match the size %d of the state", J.Rows, J.Cols, n)
+		return core.NewError(solver.ErrDimensionMismatch, err, t, X, 0)
+	}
+	for col := 0; col < n; col++ {
+		if err := d.eval(t, X, nil, col); err != nil {
+			return err
+		}
+		for row, y := range d.dx {
+			J.Set(row, col, y.Eps)
+		}
+	}
+	return nil
+}
+
+// Evaluations returns the number of evaluations of f since the creation of
+// the differentiator
+func (d *Differentiator) Evaluations() uint64 {
+	return d.evals
+}
@@ -0,0 +1,90 @@
+package ad
+
+import "math"
+
+// Dual is a dual number x = Re + Eps.ε, where ε is an infinitesimal number
+// such that ε^2 = 0. The value of a function g at a dual number is then:
+//
+//	g(Re + Eps.ε) = g(Re) + g'(Re).Eps.ε
+//
+// i.e. the part Eps of the result is the derivative of g in the direction Eps,
+// computed exactly (up to the rounding errors) by the chain rule.
+type Dual struct {
+	Re  float64 // the value
+	Eps float64 // the derivative
+}
+
+// Variable returns the dual number of the value x and of derivative 1, i.e.
+// the variable whose derivatives are computed
+func Variable(x float64) Dual {
+	return Dual{Re: x, Eps: 1}
+}
+
+// Add returns x+y
+func (x Dual) Add(y Dual) Dual { return Dual{x.Re + y.Re, x.Eps + y.Eps} }
+
+// Sub returns x-y
+func (x Dual) Sub(y Dual) Dual { return Dual{x.Re - y.Re, x.Eps - y.Eps} }
+
+// Mul returns x*y
+func (x Dual) Mul(y Dual) Dual { return Dual{x.Re * y.Re, x.Eps*y.Re + x.Re*y.Eps} }
+
+// Div returns x/y
+func (x Dual) Div(y Dual) Dual {
+	return Dual{x.Re / y.Re, (x.Eps*y.Re - x.Re*y.Eps) / (y.Re * y.Re)}
+}
+
+// Neg returns -x
+func (x Dual) Neg() Dual { return Dual{-x.Re, -x.Eps} }
+
+// Shift returns x+c
+func (x Dual) Shift(c float64) Dual { return Dual{x.Re + c, x.Eps} }
+
+// Scale returns c*x
+func (x Dual) Scale(c float64) Dual { return Dual{c * x.Re, c * x.Eps} }
+
+// Const returns the constant c, whose derivative is zero
+func (x Dual) Const(c float64) Dual { return Dual{Re: c} }
+
+// Float returns the value of x
+func (x Dual) Float() float64 { return x.Re }
+
+// Exp returns exp(x)
+func (x Dual) Exp() Dual {
+	e := math.Exp(x.Re)
+	return Dual{e, x.Eps * e}
+}
+
+// Log returns ln(x)
+func (x Dual) Log() Dual { return Dual{math.Log(x.Re), x.Eps / x.Re} }
+
+// Sin returns sin(x)
+func (x Dual) Sin() Dual { return Dual{math.Sin(x.Re), x.Eps * math.Cos(x.Re)} }
+
+// Cos returns cos(x)
+func (x Dual) Cos() Dual { return Dual{math.Cos(x.Re), -x.Eps * math.Sin(x.Re)} }
+
+// Tanh returns tanh(x)
+func (x Dual) Tanh() Dual {
+	th := math.Tanh(x.Re)
+	return Dual{th, x.Eps * (1 - th*th)}
+}
+
+// Sqrt returns the square root of x
+func (x Dual) Sqrt() Dual {
+	s := math.Sqrt(x.Re)
+	return Dual{s, x.Eps / (2 * s)}
+}
+
+// Pow returns x^p
+func (x Dual) Pow(p float64) Dual {
+	return Dual{math.Pow(x.Re, p), x.Eps * p * math.Pow(x.Re, p-1)}
+}
+
+// Abs returns |x|, whose derivative at 0 is taken as the derivative of x
+func (x Dual) Abs() Dual {
+	if x.Re < 0 {
+		return x.Neg()
+	}
+	return x
+}
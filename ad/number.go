@@ -0,0 +1,90 @@
+package ad
+
+import "math"
+
+// Number is the constraint of the numbers of a generic function, whose
+// arithmetic is defined by methods (there is no operator overloading in Go):
+// x.Add(y) is x+y, x.Mul(y) is x*y, etc. The methods with a float64 argument
+// combine the number with a constant, e.g. x.Scale(2) is 2*x. The method
+// Const returns the constant c as a number of the same type as the receiver
+// (whose value is ignored, see also the function Const).
+type Number[T any] interface {
+	Add(y T) T
+	Sub(y T) T
+	Mul(y T) T
+	Div(y T) T
+	Neg() T
+	Shift(c float64) T // x+c
+	Scale(c float64) T // c*x
+	Const(c float64) T // the constant c
+	Float() float64    // the value of the number
+	Exp() T
+	Log() T
+	Sin() T
+	Cos() T
+	Tanh() T
+	Sqrt() T
+	Pow(p float64) T // x^p
+	Abs() T
+}
+
+// Const returns the constant c as a number of type T
+func Const[T Number[T]](c float64) T {
+	var x T
+	return x.Const(c)
+}
+
+// Real is a real number, i.e. a float64 that implements the interface Number,
+// to evaluate a generic function with the real numbers.
+type Real float64
+
+// Add returns x+y
+func (x Real) Add(y Real) Real { return x + y }
+
+// Sub returns x-y
+func (x Real) Sub(y Real) Real { return x - y }
+
+// Mul returns x*y
+func (x Real) Mul(y Real) Real { return x * y }
+
+// Div returns x/y
+func (x Real) Div(y Real) Real { return x / y }
+
+// Neg returns -x
+func (x Real) Neg() Real { return -x }
+
+// Shift returns x+c
+func (x Real) Shift(c float64) Real { return x + Real(c) }
+
+// Scale returns c*x
+func (x Real) Scale(c float64) Real { return Real(c) * x }
+
+// Const returns the constant c
+func (x Real) Const(c float64) Real { return Real(c) }
+
+// Float returns the value of x
+func (x Real) Float() float64 { return float64(x) }
+
+// Exp returns exp(x)
+func (x Real) Exp() Real { return Real(math.Exp(float64(x))) }
+
+// Log returns ln(x)
+func (x Real) Log() Real { return Real(math.Log(float64(x))) }
+
+// Sin returns sin(x)
+func (x Real) Sin() Real { return Real(math.Sin(float64(x))) }
+
+// Cos returns cos(x)
+func (x Real) Cos() Real { return Real(math.Cos(float64(x))) }
+
+// Tanh returns tanh(x)
+func (x Real) Tanh() Real { return Real(math.Tanh(float64(x))) }
+
+// Sqrt returns the square root of x
+func (x Real) Sqrt() Real { return Real(math.Sqrt(float64(x))) }
+
+// Pow returns x^p
+func (x Real) Pow(p float64) Real { return Real(math.Pow(float64(x), p)) }
+
+// Abs returns |x|
+func (x Real) Abs() Real { return Real(math.Abs(float64(x))) }
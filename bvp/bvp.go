@@ -0,0 +1,181 @@
+// Package bvp implements the solvers of the two-point boundary value problems
+// (BVP), i.e. the ODE systems dX/dt = f(t,X) on an interval [a,b] whose
+// solution is defined by conditions on the states X(a) and X(b) instead of an
+// initial state (e.g. the steady profiles or the periodic solutions).
+//
+// The rate function f is a solver.Function, as for the initial value problems.
+// The shooting solvers use the solvers of the package solver for the
+// integrations, and the collocation solver computes the solution on a mesh
+// refined until the residual of the ODE system is small enough.
+package bvp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// BoundaryFunction defines the boundary conditions r(Xa,Xb)=0 of a BVP, where
+// Xa and Xb are the states at the ends of the interval. The residual r is
+// written in the slice R, of the size of the state (the number of conditions
+// should be the number of unknowns).
+type BoundaryFunction func(Xa, Xb []float64, R []float64) error
+
+// GuessFunction defines an initial guess of the solution of a BVP, i.e. an
+// approximation of the state X at time t.
+type GuessFunction func(t float64) []float64
+
+// ConstantGuess returns a GuessFunction that guesses the constant state X
+func ConstantGuess(X []float64) GuessFunction {
+	return func(t float64) []float64 {
+		guess := make([]float64, len(X))
+		copy(guess, X)
+		return guess
+	}
+}
+
+// Problem defines a two-point boundary value problem:
+//
+//	dX/dt = f(t,X) for t in [a,b]
+//	r(X(a),X(b)) = 0
+type Problem struct {
+	F    solver.Function  // the rate function f(t,X)
+	BC   BoundaryFunction // the boundary conditions r(Xa,Xb)
+	A, B float64          // the ends of the interval
+}
+
+// check returns an error if the problem is not well defined
+func (p Problem) check() error {
+	if p.F == nil || p.BC == nil {
+		err := errors.New("the functions f and r of the BVP should be defined")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	if !(p.B > p.A) {
+		err := fmt.Errorf("the interval [%g,%g] of the BVP is not valid", p.A, p.B)
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	return nil
+}
+
+// ParametricFunction defines the rate function f(t,X,P) of a BVP with
+// unknown parameters P
+type ParametricFunction func(t float64, X, P []float64) (dXdt []float64, err error)
+
+// ParametricBoundaryFunction defines the boundary conditions r(Xa,Xb,P)=0 of
+// a BVP with unknown parameters P. The residual r is written in the slice R,
+// whose size is the size of the state plus the number of parameters (one
+// additional condition per parameter).
+type ParametricBoundaryFunction func(Xa, Xb, P []float64, R []float64) error
+
+// ParametricProblem defines a two-point boundary value problem with unknown
+// parameters P (e.g. an eigenvalue or the period of a periodic orbit):
+//
+//	dX/dt = f(t,X,P) for t in [a,b]
+//	r(X(a),X(b),P) = 0
+type ParametricProblem struct {
+	F    ParametricFunction         // the rate function f(t,X,P)
+	BC   ParametricBoundaryFunction // the boundary conditions r(Xa,Xb,P)
+	A, B float64                    // the ends of the interval
+}
+
+// Parametric returns the problem p as a ParametricProblem without parameters
+func (p Problem) Parametric() ParametricProblem {
+	return ParametricProblem{
+		F: func(t float64, X, P []float64) ([]float64, error) {
+			return p.F(t, X)
+		},
+		BC: func(Xa, Xb, P []float64, R []float64) error {
+			return p.BC(Xa, Xb, R)
+		},
+		A: p.A,
+		B: p.B,
+	}
+}
+
+// Stats gathers the statistics of the solving of a BVP
+type Stats struct {
+	Iterations   int     // number of Newton iterations
+	Integrations int     // number of integrations of the ODE system (shooting)
+	MeshPoints   int     // number of points of the final mesh (collocation)
+	Residual     float64 // max norm of the residual at the solution
+}
+
+func (stats Stats) String() string {
+	return fmt.Sprintf("iterations: %d, integrations: %d, mesh points: %d, residual: %.3e", stats.Iterations, stats.Integrations, stats.MeshPoints, stats.Residual)
+}
+
+// newton solves the nonlinear system F(S)=0 with the damped Newton method,
+// starting from S (modified in place). The function correction computes the
+// Newton correction dS=J^-1.R, where J is the Jacobian matrix of F at S and R
+// the residual F(S). The correction is halved while it does not decrease the
+// norm of the residual. If the iterations do not converge, the function
+// returns a solver.Error of kind ErrNewtonDivergence, with the time t (the
+// beginning of the interval) and the unknowns S of the last iteration.
+func newton(F func(S, R []float64) error, correction func(S, R, dS []float64) error, t float64, S []float64, config settings, stats *Stats) error {
+	n := len(S)
+	R := make([]float64, n)
+	Rt := make([]float64, n)
+	St := make([]float64, n)
+	dS := make([]float64, n)
+	if err := F(S, R); err != nil {
+		return err
+	}
+	norm := maxNorm(R)
+	for iteration := 0; ; iteration++ {
+		stats.Residual = norm
+		if norm <= config.tolerance {
+			return nil
+		}
+		if iteration == config.iterations {
+			err := fmt.Errorf("no convergence after %d iterations (residual %.3e)", iteration, norm)
+			return core.NewError(solver.ErrNewtonDivergence, err, t, S, 0)
+		}
+		stats.Iterations++
+		if err := correction(S, R, dS); err != nil {
+			if errors.Is(err, linalg.ErrSingular) {
+				err = fmt.Errorf("iteration %d (residual %.3e): %w", iteration, norm, err)
+				return core.NewError(solver.ErrNewtonDivergence, err, t, S, 0)
+			}
+			return err
+		}
+		if math.IsInf(maxNorm(dS), 1) {
+			err := fmt.Errorf("iteration %d (residual %.3e): the correction is not finite", iteration, norm)
+			return core.NewError(solver.ErrNewtonDivergence, err, t, S, 0)
+		}
+		lambda := 1.0
+		for {
+			for i := range S {
+				St[i] = S[i] - lambda*dS[i]
+			}
+			err := F(St, Rt)
+			if err == nil && maxNorm(Rt) < norm {
+				break
+			}
+			if lambda *= 0.5; lambda < 1./1024 {
+				if err != nil {
+					return err
+				}
+				break // the full correction is kept
+			}
+		}
+		copy(S, St)
+		copy(R, Rt)
+		norm = maxNorm(R)
+	}
+}
+
+// maxNorm returns the max norm of the vector X (+Inf if X is not finite)
+func maxNorm(X []float64) float64 {
+	norm := 0.0
+	for _, x := range X {
+		if math.IsNaN(x) {
+			return math.Inf(1)
+		}
+		norm = math.Max(norm, math.Abs(x))
+	}
+	return norm
+}
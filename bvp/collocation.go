@@ -0,0 +1,457 @@
+package bvp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// CollocationSolver solves the BVPs with the collocation method of bvp4c
+// (Shampine, Kierzenka and Reichelt): the solution is a continuous piecewise
+// cubic polynomial S on a mesh a=x0<x1<...<xN=b, that satisfies the ODE system
+// at the ends and the middle of each interval (3-stage Lobatto IIIA formula,
+// of order 4). The states at the mesh points and the unknown parameters are
+// computed by the Newton method for all the mesh at once, which is stable for
+// the BVPs that are too sensitive for the shooting methods.
+//
+// The mesh is refined until the residual S'(t)-f(t,S(t)) of the continuous
+// solution is lower than the tolerance (see the option Tolerance) on each
+// interval: the intervals whose residual exceeds the tolerance are split in
+// two, and the ones whose residual exceeds 100 times the tolerance are split in
+// three.
+//
+// The boundary conditions that are not separated (e.g. the periodic
+// conditions) and the unknown parameters are handled by adding the constant
+// states w=X(b) and P to the state of the system, so that the Jacobian matrix
+// of the collocation equations is a band matrix.
+type CollocationSolver struct {
+	mesh   []float64
+	Y, F   [][]float64 // states and slopes of the solution at the mesh points
+	P      []float64
+	series solver.TimeSeries
+	stats  Stats
+}
+
+// NewCollocationSolver returns a CollocationSolver
+func NewCollocationSolver() *CollocationSolver {
+	return &CollocationSolver{}
+}
+
+// check returns an error if the problem is not well defined
+func (p ParametricProblem) check() error {
+	if p.F == nil || p.BC == nil {
+		err := errors.New("the functions f and r of the BVP should be defined")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	if !(p.B > p.A) {
+		err := fmt.Errorf("the interval [%g,%g] of the BVP is not valid", p.A, p.B)
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	return nil
+}
+
+// Solve solves the BVP p from the initial guess of the solution (evaluated at
+// the points of the initial mesh). The solution can then be retrieved with the
+// functions Solution and Eval.
+func (s *CollocationSolver) Solve(p Problem, guess GuessFunction, opts ...Option) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	return s.SolveParametric(p.Parametric(), guess, nil, opts...)
+}
+
+// SolveParametric solves the BVP p with unknown parameters, from the initial
+// guess of the solution and the initial guess P0 of the parameters. The
+// parameters can then be retrieved with the function Parameters.
+func (s *CollocationSolver) SolveParametric(p ParametricProblem, guess GuessFunction, P0 []float64, opts ...Option) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	if guess == nil {
+		err := errors.New("the initial guess is not defined")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	config := newSettings(opts...)
+	if config.intervals < 1 {
+		err := errors.New("the initial mesh should have at least one interval")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	s.stats = Stats{}
+	s.series = nil
+	s.P = make([]float64, len(P0))
+	copy(s.P, P0)
+
+	s.mesh = make([]float64, config.intervals+1)
+	for i := range s.mesh {
+		s.mesh[i] = p.A + float64(i)*(p.B-p.A)/float64(config.intervals)
+	}
+	s.mesh[config.intervals] = p.B
+	n := len(guess(p.A))
+	s.Y = make([][]float64, len(s.mesh))
+	for i, x := range s.mesh {
+		if s.Y[i] = guess(x); len(s.Y[i]) != n {
+			err := errors.New("the initial guess should have the same size at all the points")
+			return core.NewError(solver.ErrDimensionMismatch, err, p.A, nil, 0)
+		}
+	}
+
+	c := collocation{p: p, n: n, np: len(P0), d: 2*n + len(P0)}
+	for {
+		if err := c.solve(s, config); err != nil {
+			return err
+		}
+		residuals, err := c.residuals(s)
+		if err != nil {
+			return err
+		}
+		s.stats.Residual = 0
+		for _, r := range residuals {
+			s.stats.Residual = math.Max(s.stats.Residual, r)
+		}
+		s.stats.MeshPoints = len(s.mesh)
+		if s.stats.Residual <= config.residual {
+			break
+		}
+		if err := s.refine(residuals, config); err != nil {
+			return err
+		}
+	}
+	for i, x := range s.mesh {
+		s.series = append(s.series, solver.NewTimeData(x, s.Y[i]))
+	}
+	return nil
+}
+
+// refine inserts one point in the intervals whose residual exceeds the
+// tolerance, and two points in the intervals whose residual exceeds 100 times
+// the tolerance. The states at the new points are given by the current
+// solution.
+func (s *CollocationSolver) refine(residuals []float64, config settings) error {
+	mesh := []float64{s.mesh[0]}
+	Y := [][]float64{s.Y[0]}
+	for i, r := range residuals {
+		x0, x1 := s.mesh[i], s.mesh[i+1]
+		points := 0
+		if r > 100*config.residual {
+			points = 2
+		} else if r > config.residual {
+			points = 1
+		}
+		for k := 1; k <= points; k++ {
+			x := x0 + float64(k)*(x1-x0)/float64(points+1)
+			X := make([]float64, len(s.Y[i]))
+			s.hermite(i, x, X, nil)
+			mesh = append(mesh, x)
+			Y = append(Y, X)
+		}
+		mesh = append(mesh, x1)
+		Y = append(Y, s.Y[i+1])
+	}
+	if len(mesh) > config.maxPoints {
+		return fmt.Errorf("ERR: the mesh exceeds %d points (residual %.3e)", config.maxPoints, s.stats.Residual)
+	}
+	s.mesh, s.Y = mesh, Y
+	return nil
+}
+
+// hermite evaluates in X the cubic polynomial of the solution at the point x of
+// the interval i, and its derivative in dX if not nil.
+func (s *CollocationSolver) hermite(i int, x float64, X, dX []float64) {
+	h := s.mesh[i+1] - s.mesh[i]
+	t := (x - s.mesh[i]) / h
+	h00, h10 := (1+2*t)*(1-t)*(1-t), t*(1-t)*(1-t)
+	h01, h11 := t*t*(3-2*t), t*t*(t-1)
+	for k := range X {
+		X[k] = h00*s.Y[i][k] + h*h10*s.F[i][k] + h01*s.Y[i+1][k] + h*h11*s.F[i+1][k]
+	}
+	if dX == nil {
+		return
+	}
+	d00, d10 := 6*t*(t-1), (1-t)*(1-3*t)
+	d01, d11 := -6*t*(t-1), t*(3*t-2)
+	for k := range dX {
+		dX[k] = (d00*s.Y[i][k]+d01*s.Y[i+1][k])/h + d10*s.F[i][k] + d11*s.F[i+1][k]
+	}
+}
+
+// Solution returns the time series of the solution at the points of the mesh
+func (s *CollocationSolver) Solution() solver.TimeSeries {
+	return s.series
+}
+
+// Eval writes in X the value of the continuous solution at time t, i.e. the
+// cubic polynomial of the interval of the mesh that contains t (extrapolated
+// outside of the interval [a,b]).
+func (s *CollocationSolver) Eval(t float64, X []float64) {
+	i := sort.SearchFloat64s(s.mesh, t) - 1
+	if i < 0 {
+		i = 0
+	} else if i > len(s.mesh)-2 {
+		i = len(s.mesh) - 2
+	}
+	s.hermite(i, t, X, nil)
+}
+
+// Parameters returns the unknown parameters computed by SolveParametric
+func (s *CollocationSolver) Parameters() []float64 {
+	P := make([]float64, len(s.P))
+	copy(P, s.P)
+	return P
+}
+
+// Stats returns the statistics of the last solving
+func (s *CollocationSolver) Stats() Stats {
+	return s.stats
+}
+
+// collocation defines the collocation equations of a ParametricProblem. The
+// state of the system is augmented to z=(X,w,P) of size d, where w'=0 and
+// P'=0, so that the boundary conditions are separated: r(X,w,P)=0 at t=a,
+// and w-X=0 at t=b.
+type collocation struct {
+	p     ParametricProblem
+	n, np int
+	d     int
+}
+
+// f computes in dz the slope of the augmented state z at time t
+func (c *collocation) f(t float64, z, dz []float64) error {
+	slope, err := c.p.F(t, z[:c.n], z[2*c.n:])
+	if err != nil {
+		return err
+	}
+	if len(slope) != c.n {
+		err := fmt.Errorf("the function f returns %d values for a state of size %d", len(slope), c.n)
+		return core.NewError(solver.ErrDimensionMismatch, err, t, z[:c.n], 0)
+	}
+	copy(dz, slope)
+	for k := c.n; k < c.d; k++ {
+		dz[k] = 0
+	}
+	return nil
+}
+
+// jacobian computes the Jacobian matrix of the augmented slope at (t,z) by
+// finite differences, where fz is the slope at (t,z). The columns of w are
+// zero.
+func (c *collocation) jacobian(t float64, z, fz []float64, J *linalg.Matrix, work []float64) error {
+	J.Resize(c.d, c.d)
+	for col := 0; col < c.d; col++ {
+		if col >= c.n && col < 2*c.n {
+			continue
+		}
+		delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(z[col]))
+		v := z[col]
+		z[col] = v + delta
+		err := c.f(t, z, work)
+		z[col] = v
+		if err != nil {
+			return err
+		}
+		for row := 0; row < c.n; row++ {
+			J.Set(row, col, (work[row]-fz[row])/delta)
+		}
+	}
+	return nil
+}
+
+// solve computes the augmented states at the mesh points with the Newton
+// method, starting from the current solution, and updates the solution.
+func (c *collocation) solve(s *CollocationSolver, config settings) error {
+	n, np, d := c.n, c.np, c.d
+	ka := n + np // number of conditions at t=a
+	N := len(s.mesh) - 1
+	Z := make([]float64, (N+1)*d)
+	for i := range s.mesh {
+		z := Z[i*d : (i+1)*d]
+		copy(z[:n], s.Y[i])
+		copy(z[n:2*n], s.Y[N])
+		copy(z[2*n:], s.P)
+	}
+
+	Fz := make([][]float64, N+1) // slopes at the mesh points
+	zmid := make([][]float64, N) // states at the middle of the intervals
+	fmid := make([][]float64, N) // slopes at the middle of the intervals
+	for i := range Fz {
+		Fz[i] = make([]float64, d)
+	}
+	for i := range zmid {
+		zmid[i] = make([]float64, d)
+		fmid[i] = make([]float64, d)
+	}
+
+	// residual computes the collocation equations, ordered as the conditions at
+	// t=a, the equations of each interval, and the conditions at t=b.
+	residual := func(Z, R []float64) error {
+		for i, x := range s.mesh {
+			if err := c.f(x, Z[i*d:(i+1)*d], Fz[i]); err != nil {
+				return err
+			}
+		}
+		z0 := Z[:d]
+		if err := c.p.BC(z0[:n], z0[n:2*n], z0[2*n:], R[:ka]); err != nil {
+			return err
+		}
+		for i := 0; i < N; i++ {
+			h := s.mesh[i+1] - s.mesh[i]
+			zi, zj := Z[i*d:(i+1)*d], Z[(i+1)*d:(i+2)*d]
+			for k := 0; k < d; k++ {
+				zmid[i][k] = (zi[k]+zj[k])/2 - h/8*(Fz[i+1][k]-Fz[i][k])
+			}
+			if err := c.f(s.mesh[i]+h/2, zmid[i], fmid[i]); err != nil {
+				return err
+			}
+			for k := 0; k < d; k++ {
+				R[ka+i*d+k] = zj[k] - zi[k] - h/6*(Fz[i][k]+4*fmid[i][k]+Fz[i+1][k])
+			}
+		}
+		zN := Z[N*d:]
+		for k := 0; k < n; k++ {
+			R[ka+N*d+k] = zN[n+k] - zN[k]
+		}
+		return nil
+	}
+
+	kl := ka + d - 1
+	ku := 2*d - 1 - ka
+	if ku < d-1 {
+		ku = d - 1
+	}
+	A := linalg.NewBand((N+1)*d, kl, ku)
+	var lu linalg.BandLU
+	var Ji, Jj, Jm, M linalg.Matrix
+	work := make([]float64, d)
+	Ra := make([]float64, ka)
+
+	// correction assembles the Jacobian matrix of the collocation equations
+	// (evaluated by residual at Z) and solves the Newton system.
+	correction := func(Z, R, dS []float64) error {
+		A.Zero()
+		z0 := Z[:d]
+		for col := 0; col < d; col++ {
+			delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(z0[col]))
+			v := z0[col]
+			z0[col] = v + delta
+			err := c.p.BC(z0[:n], z0[n:2*n], z0[2*n:], Ra)
+			z0[col] = v
+			if err != nil {
+				return err
+			}
+			for row := 0; row < ka; row++ {
+				A.Set(row, col, (Ra[row]-R[row])/delta)
+			}
+		}
+		if err := c.jacobian(s.mesh[0], Z[:d], Fz[0], &Jj, work); err != nil {
+			return err
+		}
+		for i := 0; i < N; i++ {
+			h := s.mesh[i+1] - s.mesh[i]
+			Ji.Copy(&Jj)
+			if err := c.jacobian(s.mesh[i+1], Z[(i+1)*d:(i+2)*d], Fz[i+1], &Jj, work); err != nil {
+				return err
+			}
+			if err := c.jacobian(s.mesh[i]+h/2, zmid[i], fmid[i], &Jm, work); err != nil {
+				return err
+			}
+			// dPhi/dzi = -I - h/6*(Ji + 4*Jm*(I/2 + h/8*Ji))
+			// dPhi/dzj =  I - h/6*(Jj + 4*Jm*(I/2 - h/8*Jj))
+			row := ka + i*d
+			product(&Jm, &Ji, h/8, &M)
+			for r := 0; r < d; r++ {
+				for k := 0; k < d; k++ {
+					v := -h / 6 * (Ji.At(r, k) + 4*M.At(r, k))
+					if r == k {
+						v--
+					}
+					A.Set(row+r, i*d+k, v)
+				}
+			}
+			product(&Jm, &Jj, -h/8, &M)
+			for r := 0; r < d; r++ {
+				for k := 0; k < d; k++ {
+					v := -h / 6 * (Jj.At(r, k) + 4*M.At(r, k))
+					if r == k {
+						v++
+					}
+					A.Set(row+r, (i+1)*d+k, v)
+				}
+			}
+		}
+		for k := 0; k < n; k++ {
+			A.Set(ka+N*d+k, N*d+n+k, 1)
+			A.Set(ka+N*d+k, N*d+k, -1)
+		}
+		if err := lu.Factorize(A); err != nil {
+			return err
+		}
+		copy(dS, R)
+		lu.Solve(dS)
+		return nil
+	}
+
+	// The collocation equations are of the order of h*(S'-f): the tolerance
+	// of the Newton iterations is reduced so that their error is negligible
+	// relatively to the tolerance of the residual.
+	for i := 0; i < N; i++ {
+		config.tolerance = math.Min(config.tolerance, 0.1*config.residual*(s.mesh[i+1]-s.mesh[i]))
+	}
+	if err := newton(residual, correction, s.mesh[0], Z, config, &s.stats); err != nil {
+		return err
+	}
+
+	s.Y = make([][]float64, N+1)
+	s.F = make([][]float64, N+1)
+	for i := range s.mesh {
+		z := Z[i*d : (i+1)*d]
+		s.Y[i] = append([]float64(nil), z[:n]...)
+		s.F[i] = append([]float64(nil), Fz[i][:n]...)
+	}
+	copy(s.P, Z[2*n:d])
+	return nil
+}
+
+// product computes M = Jm*(I/2 + c*J) for the square matrices Jm and J
+func product(Jm, J *linalg.Matrix, c float64, M *linalg.Matrix) {
+	d := J.Rows
+	M.Resize(d, d)
+	for r := 0; r < d; r++ {
+		for k := 0; k < d; k++ {
+			v := Jm.At(r, k) / 2
+			for l := 0; l < d; l++ {
+				v += c * Jm.At(r, l) * J.At(l, k)
+			}
+			M.Set(r, k, v)
+		}
+	}
+}
+
+// residuals returns the relative residual of the continuous solution on each
+// interval, evaluated at the two interior points of the 5-point Lobatto
+// quadrature (the residual is zero at the collocation points).
+func (c *collocation) residuals(s *CollocationSolver) ([]float64, error) {
+	N := len(s.mesh) - 1
+	residuals := make([]float64, N)
+	X := make([]float64, c.n)
+	dX := make([]float64, c.n)
+	for i := 0; i < N; i++ {
+		h := s.mesh[i+1] - s.mesh[i]
+		for _, t := range []float64{0.5 - math.Sqrt(21)/14, 0.5 + math.Sqrt(21)/14} {
+			x := s.mesh[i] + t*h
+			s.hermite(i, x, X, dX)
+			slope, err := c.p.F(x, X, s.P)
+			if err != nil {
+				return nil, err
+			}
+			for k := range slope {
+				r := math.Abs(dX[k]-slope[k]) / (1 + math.Abs(slope[k]))
+				residuals[i] = math.Max(residuals[i], r)
+			}
+		}
+	}
+	return residuals, nil
+}
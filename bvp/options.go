@@ -0,0 +1,78 @@
+package bvp
+
+// settings gathers the parameters of the solving of a BVP. The parameters are
+// initialized with default values and then modified by the Option functions.
+type settings struct {
+	tolerance  float64
+	iterations int
+	residual   float64 // tolerance of the residual of the collocation
+	intervals  int     // number of intervals of the initial mesh
+	maxPoints  int     // maximal number of points of the mesh
+}
+
+// Default values of the settings
+const (
+	defaultTolerance  = 1e-8
+	defaultIterations = 50
+	defaultResidual   = 1e-6
+	defaultIntervals  = 10
+	defaultMaxPoints  = 10000
+)
+
+// Option defines a function that modifies the settings of the solving of a BVP
+type Option func(s *settings)
+
+// newSettings returns the settings defined by the default values modified by
+// the given options.
+func newSettings(opts ...Option) settings {
+	s := settings{
+		tolerance:  defaultTolerance,
+		iterations: defaultIterations,
+		residual:   defaultResidual,
+		intervals:  defaultIntervals,
+		maxPoints:  defaultMaxPoints,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// Newton specifies the parameters of the Newton iterations: the iterations
+// stop when the max norm of the residual is lower than tolerance, and the
+// solving fails with an error of kind solver.ErrNewtonDivergence if the
+// convergence is not reached after maxIterations iterations. By default, tolerance=1e-8 and
+// maxIterations=50.
+func Newton(tolerance float64, maxIterations int) Option {
+	return func(s *settings) {
+		s.tolerance = tolerance
+		s.iterations = maxIterations
+	}
+}
+
+// Tolerance specifies the tolerance of the collocation solver on the residual
+// of the ODE system, i.e. the mesh is refined until the relative residual
+// |S'(t)-f(t,S(t))|/(1+|f(t,S(t))|) of the continuous solution S is lower than
+// tolerance. The default value is 1e-6.
+func Tolerance(tolerance float64) Option {
+	return func(s *settings) {
+		s.residual = tolerance
+	}
+}
+
+// InitialMesh specifies the number of intervals of the uniform initial mesh of
+// the collocation solver. The default value is 10.
+func InitialMesh(intervals int) Option {
+	return func(s *settings) {
+		s.intervals = intervals
+	}
+}
+
+// MaxMeshPoints specifies the maximal number of points of the mesh of the
+// collocation solver. The solving fails if the refinement of the mesh exceeds
+// this number. The default value is 10000.
+func MaxMeshPoints(n int) Option {
+	return func(s *settings) {
+		s.maxPoints = n
+	}
+}
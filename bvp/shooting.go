@@ -0,0 +1,211 @@
+package bvp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// ShootingSolver solves the BVPs with the shooting method: the unknown
+// initial values are the states at the nodes a=t0<t1<...<tm=b, from which the
+// ODE system is integrated on each interval [tk,tk+1] by a solver.Solver. The
+// unknowns are computed with the Newton method so that the integrated states
+// are continuous at the nodes and satisfy the boundary conditions. The
+// sensitivity matrix of the Newton method is computed by finite differences.
+//
+// The single shooting (one interval) integrates the whole interval from the
+// unknown state X(a). The multiple shooting splits the interval, so that the
+// integrations are shorter and less sensitive to the unknowns, which is
+// necessary when the solutions of the ODE system grow quickly.
+type ShootingSolver struct {
+	method    solver.Solver
+	h         float64
+	intervals int
+	nodes     []float64
+	S         []float64   // states at the nodes (unknowns of the Newton method)
+	E         [][]float64 // integrated states at the end of each interval
+	series    solver.TimeSeries
+	stats     Stats
+}
+
+// NewShootingSolver returns a ShootingSolver that implements the single
+// shooting method, using the solver method with the step size h for the
+// integrations.
+func NewShootingSolver(method solver.Solver, h float64) *ShootingSolver {
+	return NewMultipleShootingSolver(method, h, 1)
+}
+
+// NewMultipleShootingSolver returns a ShootingSolver that implements the
+// multiple shooting method on the given number of intervals of the same
+// length, using the solver method with the step size h for the integrations.
+func NewMultipleShootingSolver(method solver.Solver, h float64, intervals int) *ShootingSolver {
+	return &ShootingSolver{method: method, h: h, intervals: intervals}
+}
+
+// Solve solves the BVP p from the initial guess of the solution (used at the
+// nodes of the intervals). The tolerance of the Newton iterations can be
+// specified with the option Newton. The solution can then be retrieved with the
+// functions Initial and Solution.
+func (s *ShootingSolver) Solve(p Problem, guess GuessFunction, opts ...Option) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	if s.method == nil || s.h <= 0 || s.intervals < 1 {
+		err := errors.New("the shooting solver should have a method, a positive step size and at least one interval")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	if guess == nil {
+		err := errors.New("the initial guess is not defined")
+		return core.NewError(solver.ErrInvalidInput, err, p.A, nil, 0)
+	}
+	config := newSettings(opts...)
+	s.stats = Stats{}
+	s.series = nil
+
+	m := s.intervals
+	s.nodes = make([]float64, m+1)
+	for k := range s.nodes {
+		s.nodes[k] = p.A + float64(k)*(p.B-p.A)/float64(m)
+	}
+	s.nodes[m] = p.B
+	n := len(guess(p.A))
+	s.S = make([]float64, m*n)
+	s.E = make([][]float64, m)
+	for k := 0; k < m; k++ {
+		X := guess(s.nodes[k])
+		if len(X) != n {
+			err := errors.New("the initial guess should have the same size at all the nodes")
+			return core.NewError(solver.ErrDimensionMismatch, err, p.A, nil, 0)
+		}
+		copy(s.S[k*n:], X)
+		s.E[k] = make([]float64, n)
+	}
+
+	residual := func(S, R []float64) error {
+		for k := 0; k < m; k++ {
+			if err := s.integrate(p, k, S[k*n:(k+1)*n], s.E[k]); err != nil {
+				return err
+			}
+			if k < m-1 {
+				for i := 0; i < n; i++ {
+					R[k*n+i] = s.E[k][i] - S[(k+1)*n+i]
+				}
+			}
+		}
+		return p.BC(S[:n], s.E[m-1], R[(m-1)*n:])
+	}
+
+	// The state at the node k only changes the continuity conditions at the
+	// nodes k and k+1 (or the boundary conditions for the first and the last
+	// node), so that one integration per column is sufficient.
+	Ed := make([]float64, n)
+	Rd := make([]float64, n)
+	var J linalg.Matrix
+	var lu linalg.LU
+	J.Resize(m*n, m*n)
+	correction := func(S, R, dS []float64) error {
+		for k := 0; k < m; k++ {
+			Sk := S[k*n : (k+1)*n]
+			for j := 0; j < n; j++ {
+				col := k*n + j
+				if k > 0 {
+					J.Set((k-1)*n+j, col, -1)
+				}
+				delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(Sk[j]))
+				x := Sk[j]
+				Sk[j] = x + delta
+				if err := s.integrate(p, k, Sk, Ed); err != nil {
+					return err
+				}
+				if k < m-1 {
+					for i := 0; i < n; i++ {
+						J.Set(k*n+i, col, (Ed[i]-s.E[k][i])/delta)
+					}
+				}
+				if k == 0 || k == m-1 {
+					Eb := s.E[m-1]
+					if k == m-1 {
+						Eb = Ed
+					}
+					if err := p.BC(S[:n], Eb, Rd); err != nil {
+						return err
+					}
+					for i := 0; i < n; i++ {
+						J.Set((m-1)*n+i, col, (Rd[i]-R[(m-1)*n+i])/delta)
+					}
+				}
+				Sk[j] = x
+			}
+		}
+		if err := lu.Factorize(&J); err != nil {
+			return err
+		}
+		copy(dS, R)
+		lu.Solve(dS)
+		return nil
+	}
+
+	if err := newton(residual, correction, s.nodes[0], s.S, config, &s.stats); err != nil {
+		return err
+	}
+	return s.record(p, n)
+}
+
+// integrate integrates the ODE system on the interval k from the state Xk, and
+// writes the state at the end of the interval in Xe.
+func (s *ShootingSolver) integrate(p Problem, k int, Xk []float64, Xe []float64) error {
+	s.stats.Integrations++
+	_, err := s.method.Solve(p.F, s.nodes[k], Xk, s.h, nil, nil, solver.OutputTimes(s.nodes[k+1]))
+	if err != nil {
+		return err
+	}
+	_, X := s.method.Result()
+	copy(Xe, X)
+	return nil
+}
+
+// record integrates the solution on each interval, recording the states of the
+// iterations of the solver in the time series of the solution.
+func (s *ShootingSolver) record(p Problem, n int) error {
+	for k := 0; k < s.intervals; k++ {
+		tk := s.nodes[k+1]
+		end := solver.Controller(func(t float64, X []float64) (bool, error) {
+			return t >= tk, nil
+		})
+		var recorder solver.RecorderTimeSeries
+		_, err := s.method.Solve(p.F, s.nodes[k], s.S[k*n:(k+1)*n], s.h, end, &recorder, solver.Breakpoints(tk))
+		if err != nil {
+			return err
+		}
+		series := recorder.Series
+		if k > 0 {
+			series = series[1:] // the node is the end of the previous interval
+		}
+		s.series = append(s.series, series...)
+	}
+	return nil
+}
+
+// Initial returns the state X(a) of the solution at the beginning of the
+// interval
+func (s *ShootingSolver) Initial() []float64 {
+	n := len(s.S) / s.intervals
+	X := make([]float64, n)
+	copy(X, s.S[:n])
+	return X
+}
+
+// Solution returns the time series of the solution, i.e. the states computed by
+// the iterations of the solver integrating each interval from the solution
+// states at the nodes.
+func (s *ShootingSolver) Solution() solver.TimeSeries {
+	return s.series
+}
+
+// Stats returns the statistics of the last solving
+func (s *ShootingSolver) Stats() Stats {
+	return s.stats
+}
@@ -20,12 +20,48 @@ var demolist = []demodef{
 	{"spring01", system.DemoSpring01, "damped spring simulation with basic implementation"},
 	{"spring02", system.DemoSpring02, "damped spring simulation with structured implementation"},
 	{"spring03", system.DemoSpring03, "damped spring simulation with comparrison to analytical solution"},
+	{"spring04", system.DemoSpring04, "damped spring pseudo-period measured by event detection"},
+	{"springmass", system.DemoSpringMassMatrix, "damped spring solved in its mass matrix form"},
 	{"lorenz", system.DemoLorenz, "demonstration of the Lorenz chaotic attractor"},
+	{"checkpoint", system.DemoLorenzCheckpoint, "checkpoint and restart of a Lorenz simulation"},
 	{"laser01", system.DemoLaser, "demonstration of a chaotic laser dynamics"},
 	{"laser02", system.DemoLaserFirstReturnMap, "Poincaré map of a chaotic laser dynamics"},
 	{"watertank", system.DemoWaterTank, "water tank fill in/out"},
 	{"cwatertank", system.DemoCascadingWaterTank, "cascading water tank fill in/out"},
+	{"steady", system.DemoWaterTankSteadyState, "cascading water tank solved until its steady state"},
+	{"relay", system.DemoWaterTankRelay, "water tank with a pump controlled by a relay"},
+	{"schedule", system.DemoWaterTankSchedule, "water tank with a scheduled pump and breakpoints"},
 	{"volterra", system.DemoVolterra, "model of preys/predators populations"},
+	{"stopreason", system.DemoVolterraStopReason, "controller combinators and stop reason of a solving process"},
+	{"stoptime", system.DemoVolterraStopTime, "controller combinators And and Debounce applied to StopAtTime"},
+	{"backward", system.DemoVolterraBackward, "backward integration of the preys/predators model"},
+	{"blowup", system.DemoBlowUp, "errors of a solving process that diverges in a finite time"},
+	{"guards", system.DemoBlowUpGuards, "guard controllers of a solving process that diverges"},
+	{"circuit", system.DemoCircuit, "RC circuit solved as a DAE in mass matrix form"},
+	{"hydraulic", system.DemoHydraulicNetwork, "hydraulic network solved as a semi-explicit DAE"},
+	{"dde", system.DemoDelayedDecay, "delayed decay DDE with the tracking of the discontinuities"},
+	{"ddestate", system.DemoStateDelayedDecay, "DDE with a state dependent delay"},
+	{"mackeyglass", system.DemoMackeyGlass, "chaotic Mackey-Glass DDE"},
+	{"sdevolterra", system.DemoStochasticVolterra, "Volterra system with a multiplicative noise (SDE)"},
+	{"sdelaser", system.DemoStochasticLaser, "laser system with an additive noise (SDE)"},
+	{"sdeconvergence", system.DemoSDEConvergence, "strong convergence of the SDE methods"},
+	{"ssavolterra", system.DemoDiscreteVolterra, "Volterra system with discrete populations (Gillespie)"},
+	{"ssabirthdeath", system.DemoBirthDeath, "stationary distribution of a birth-death process (Gillespie)"},
+	{"ssadimerization", system.DemoDimerization, "dimerization model with the exact and tau-leaping methods"},
+	{"springnystrom", system.DemoSpringNystrom, "spring in the second order form solved with the RKN4 method"},
+	{"pendulum", system.DemoPendulum, "pendulum solved with the Runge-Kutta-Nyström methods"},
+	{"bratu", system.DemoBratu, "Bratu BVP solved with the single and multiple shooting methods"},
+	{"springperiodic", system.DemoPeriodicSpring, "periodic regime of the forced spring as a BVP"},
+	{"troesch", system.DemoTroesch, "Troesch BVP solved with the collocation method"},
+	{"mathieu", system.DemoMathieu, "eigenvalue of the Mathieu equation as an unknown parameter of a BVP"},
+	{"fractionalrelaxation", system.DemoFractionalRelaxation, "fractional relaxation solved with the fractional Adams-Bashforth-Moulton method"},
+	{"battery", system.DemoBattery, "discharge of a battery modelled with a fractional order element"},
+	{"heat", system.DemoHeat, "heat equation and advection solved with the method of lines"},
+	{"brusselator", system.DemoBrusselator, "2D Brusselator reaction-diffusion solved with the method of lines"},
+	{"jacobian", system.DemoJacobian, "dense and sparse jacobian matrices computed by finite differences"},
+	{"banded", system.DemoBandedJacobian, "implicit solvers with a band iteration matrix given by the jacobian pattern"},
+	{"laserad", system.DemoLaserDerivatives, "exact derivatives of the laser system computed with dual numbers"},
+	{"allocs", system.DemoAllocations, "check that the iterations do not allocate memory"},
 }
 
 func getDemoFunc(label string) (demofunc, error) {
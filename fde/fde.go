@@ -0,0 +1,202 @@
+// Package fde implements the solvers of the fractional differential equations
+// (FDE), whose components are defined by Caputo fractional derivatives of
+// orders alpha_i in (0,1]:
+//
+//	D^alpha_i X_i = f_i(t,X), X(t0) = X0
+//
+// The fractional derivatives model the systems with a memory of their whole
+// history, such as the viscoelastic materials or the diffusion in the
+// electrodes of a battery. The components of order 1 are ordinary
+// derivatives, so that a system can mix fractional and ordinary equations.
+//
+// The solvers follow the conventions of the package solver: the process is
+// driven by a solver.Controller and the states are recorded by a
+// solver.Recorder.
+package fde
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// Problem defines a fractional differential equation
+type Problem struct {
+	F solver.InPlaceFunction // the rate function f(t,X)
+	// Alpha gives the orders of the Caputo derivatives of the components, in
+	// (0,1]. A single value defines the same order for all the components.
+	Alpha []float64
+}
+
+// orders returns the order of each of the n components
+func (p Problem) orders(n int) ([]float64, error) {
+	alpha := p.Alpha
+	if len(alpha) == 1 {
+		alpha = make([]float64, n)
+		for i := range alpha {
+			alpha[i] = p.Alpha[0]
+		}
+	}
+	if len(alpha) != n {
+		err := fmt.Errorf("the problem defines %d orders for %d components", len(p.Alpha), n)
+		return nil, core.NewError(solver.ErrDimensionMismatch, err, 0, nil, 0)
+	}
+	for _, a := range alpha {
+		if !(a > 0 && a <= 1) {
+			err := fmt.Errorf("the order %g of a derivative should be in (0,1]", a)
+			return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+		}
+	}
+	return alpha, nil
+}
+
+// ABMSolver implements the fractional Adams-Bashforth-Moulton method of
+// Diethelm, Ford and Freed, i.e. the predictor-corrector method derived from
+// the Volterra integral form of the FDE:
+//
+//	X(t) = X0 + 1/Gamma(alpha) * integral[t0,t] (t-s)^(alpha-1) * f(s,X(s)) ds
+//
+// where the predictor approximates f by a piecewise constant function, and the
+// corrector by a piecewise linear function. The error of the method is of
+// order 1+alpha. For the components of order 1, the method is the
+// trapezoidal rule, computed from the previous state without the history.
+//
+// The integral over the history is a discrete convolution, which is computed
+// by blocks with the FFT (Hairer, Lubich and Schlichte), so that the cost of N
+// steps is proportional to N*log(N)^2 instead of N^2 (see the options
+// DirectSummation and ShortMemory).
+type ABMSolver struct {
+	t     float64
+	X     []float64
+	stats solver.Stats
+}
+
+// NewABMSolver returns a solver that implements the fractional
+// Adams-Bashforth-Moulton method
+func NewABMSolver() *ABMSolver {
+	return &ABMSolver{}
+}
+
+// Solve solves the FDE p from the initial conditions (t0,X0) with a step size
+// h > 0, stopping the process when the controller c requests it, and recording
+// the states with the recorder r.
+func (s *ABMSolver) Solve(p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	return s.SolveContext(context.Background(), p, t0, X0, h, c, r, opts...)
+}
+
+// SolveContext is the same as Solve, but the solving process is aborted when
+// the context is canceled or when its deadline is exceeded.
+func (s *ABMSolver) SolveContext(ctx context.Context, p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	if p.F == nil {
+		err := errors.New("the function f is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	if !(h > 0) {
+		err := fmt.Errorf("the step size h (%g) should be positive", h)
+		return 0, core.NewError(solver.ErrStepSizeTooSmall, err, t0, X0, h)
+	}
+	if c == nil {
+		err := errors.New("the controller c is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	if r == nil {
+		r = &solver.RecorderNone{}
+	}
+	alpha, err := p.orders(len(X0))
+	if err != nil {
+		return 0, err
+	}
+	config := newSettings(opts...)
+
+	start := time.Now()
+	s.stats = solver.Stats{}
+	hist := newHistory(core.Instrument(&s.stats, p.F), alpha, t0, X0, h, config)
+
+	var iterations uint64 = 0
+	tm, Xm := t0, make([]float64, len(X0))
+	copy(Xm, X0)
+	defer func() {
+		s.t = tm
+		s.X = append(s.X[:0], Xm...)
+		s.stats.WallTime = time.Since(start)
+	}()
+
+	r.Record(tm, Xm)
+	process := solver.NewProcess(t0, h)
+	if stop, err := process.Request(c, tm, Xm); stop || err != nil {
+		if err != nil {
+			err = core.NewError(solver.ErrFunction, err, tm, Xm, h)
+		}
+		return iterations, err
+	}
+	hist.done = func(n int, X []float64) (bool, error) {
+		core.Accept(&s.stats, h)
+		tn := t0 + float64(n)*h
+		r.Record(tn, X)
+		stop, err := process.Request(c, tn, X)
+		if err != nil {
+			return true, core.NewError(solver.ErrFunction, err, tn, X, h)
+		}
+		if stop {
+			return true, nil
+		}
+		tm = tn
+		copy(Xm, X)
+		iterations++
+		if iterations%config.checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return true, core.NewError(solver.ErrInterrupted, err, tm, Xm, h)
+			}
+		}
+		return false, nil
+	}
+	if err := hist.run(); err != nil {
+		return iterations, core.NewError(solver.ErrFunction, err, tm, Xm, h)
+	}
+	return iterations, nil
+}
+
+// Result returns the values of t and X obtained at the end of the solving
+// process. As for the solvers, when the controller stops the process, the
+// result is the state before the step that triggers the stop.
+func (s *ABMSolver) Result() (t float64, X []float64) {
+	X = make([]float64, len(s.X))
+	copy(X, s.X)
+	return s.t, X
+}
+
+// Stats returns the statistics of the last solving process
+func (s *ABMSolver) Stats() solver.Stats {
+	return s.stats
+}
+
+// kernel holds the weights of the convolutions of the predictor (B) and of
+// the corrector (A) for an order alpha:
+//
+//	B_k = k^alpha - (k-1)^alpha
+//	A_k = (k+1)^(alpha+1) - 2*k^(alpha+1) + (k-1)^(alpha+1)
+type kernel struct {
+	alpha float64
+	B, A  []float64
+}
+
+// weights returns the weights B and A up to the index k (included)
+func (w *kernel) weights(k int) ([]float64, []float64) {
+	for j := len(w.B); j <= k; j++ {
+		x := float64(j)
+		if j == 0 {
+			w.B = append(w.B, 0)
+			w.A = append(w.A, 0)
+			continue
+		}
+		a := w.alpha
+		w.B = append(w.B, math.Pow(x, a)-math.Pow(x-1, a))
+		w.A = append(w.A, math.Pow(x+1, a+1)-2*math.Pow(x, a+1)+math.Pow(x-1, a+1))
+	}
+	return w.B, w.A
+}
@@ -0,0 +1,83 @@
+package fde
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// mittagLeffler returns the value of the Mittag-Leffler function E_alpha(z),
+// computed with its series (for the small values of |z|)
+func mittagLeffler(alpha, z float64) float64 {
+	e := 0.0
+	for k := 0; k < 150; k++ {
+		e += math.Pow(z, float64(k)) / math.Gamma(alpha*float64(k)+1)
+	}
+	return e
+}
+
+// relaxation is the fractional relaxation D^alpha x = -x, whose solution for
+// x(0)=1 is E_alpha(-t^alpha)
+func relaxation(alpha float64) Problem {
+	return Problem{
+		F: func(t float64, X []float64, dXdt []float64) error {
+			dXdt[0] = -X[0]
+			return nil
+		},
+		Alpha: []float64{alpha},
+	}
+}
+
+// TestABMConvergence checks the order 1+alpha of the ABM method on the
+// fractional relaxation, with the FFT and the direct summations.
+func TestABMConvergence(t *testing.T) {
+	steps := []int{40, 80, 160, 320}
+	for _, alpha := range []float64{0.5, 0.8} {
+		exact := mittagLeffler(alpha, -1)
+		for _, opts := range [][]Option{nil, {DirectSummation()}} {
+			errs := make([]float64, len(steps))
+			for i, n := range steps {
+				s := NewABMSolver()
+				if _, err := s.Solve(relaxation(alpha), 0, []float64{1}, 1/float64(n), solver.StopAtTime(1), nil, opts...); err != nil {
+					t.Fatal(err)
+				}
+				tr, X := s.Result()
+				if math.Abs(tr-1) > 1e-9 {
+					t.Fatalf("the process ends at t=%g instead of t=1", tr)
+				}
+				errs[i] = math.Abs(X[0] - exact)
+			}
+			for i := 1; i < len(steps); i++ {
+				order := math.Log2(errs[i-1] / errs[i])
+				if math.Abs(order-(1+alpha)) > 0.15 {
+					t.Errorf("alpha=%g, %d options: the observed order is %.2f between h=1/%d and h=1/%d",
+						alpha, len(opts), order, steps[i-1], steps[i])
+				}
+			}
+		}
+	}
+}
+
+// TestHistoryBuffers checks that the direct summation does not allocate the
+// sums of the FFT summation, and that the slopes kept with a short memory are
+// the ones of the memory window.
+func TestHistoryBuffers(t *testing.T) {
+	h := 0.01
+	for _, opts := range [][]Option{{DirectSummation()}, {ShortMemory(0.5)}} {
+		config := newSettings(opts...)
+		hist := newHistory(relaxation(0.5).F, []float64{0.5}, 0, []float64{1}, h, config)
+		hist.done = func(n int, X []float64) (bool, error) {
+			return n == 300, nil
+		}
+		if err := hist.run(); err != nil {
+			t.Fatal(err)
+		}
+		if hist.sumP != nil || hist.sumC != nil {
+			t.Errorf("%d sums are allocated for the direct summation", len(hist.sumP))
+		}
+		if config.memory > 0 && len(hist.slopes) > hist.memory+1 {
+			t.Errorf("%d slopes are kept for a memory of %d steps", len(hist.slopes), hist.memory)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package fde
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft computes in place the discrete Fourier transform of x, whose length
+// should be a power of 2 (radix-2 Cooley-Tukey algorithm). The inverse
+// transform (without the normalization by the length) is computed if inverse
+// is true.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := x[start+k]
+				v := x[start+k+size/2] * wk
+				x[start+k] = u + v
+				x[start+k+size/2] = u - v
+				wk *= w
+			}
+		}
+	}
+}
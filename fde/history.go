@@ -0,0 +1,247 @@
+package fde
+
+import (
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// leafSize is the size of the blocks of steps whose history is summed
+// directly by the FFT summation
+const leafSize = 64
+
+// history computes the steps of the ABM method and the sums over their
+// history. The slopes f_j of the steps are kept (only the ones of the memory
+// window with a short memory), and for the FFT summation, the contributions of
+// the past blocks of steps to the next ones are accumulated in sumP and sumC.
+type history struct {
+	f       solver.InPlaceFunction
+	n       int
+	kernels []*kernel // kernel of each component
+	c1, c2  []float64 // h^alpha/Gamma(alpha+1) and h^alpha/Gamma(alpha+2)
+	t0, h   float64
+	x0      []float64
+	slopes  [][]float64 // slopes f_j at the steps j, from the step first
+	first   int
+	sumP    [][]float64 // sums of the past blocks for the predictor at each step
+	sumC    [][]float64 // sums of the past blocks for the corrector at each step
+	memory  int         // length of the short memory in steps (0 for the full memory)
+	direct  bool
+	iters   int
+	xp, fp  []float64
+	x       []float64
+	prev    []float64                              // state of the previous step
+	done    func(n int, X []float64) (bool, error) // called at the end of each step (true to stop)
+}
+
+// newHistory creates the history of a solving process
+func newHistory(f solver.InPlaceFunction, alpha []float64, t0 float64, X0 []float64, h float64, config settings) *history {
+	n := len(X0)
+	hist := &history{
+		f:       f,
+		n:       n,
+		kernels: make([]*kernel, n),
+		c1:      make([]float64, n),
+		c2:      make([]float64, n),
+		t0:      t0,
+		h:       h,
+		x0:      append([]float64(nil), X0...),
+		direct:  config.direct || config.memory > 0,
+		iters:   config.correctors,
+		xp:      make([]float64, n),
+		fp:      make([]float64, n),
+		x:       make([]float64, n),
+		prev:    append([]float64(nil), X0...),
+	}
+	if config.memory > 0 {
+		hist.memory = int(math.Ceil(config.memory / h))
+	}
+	kernels := make(map[float64]*kernel)
+	for i, a := range alpha {
+		if kernels[a] == nil {
+			kernels[a] = &kernel{alpha: a}
+		}
+		hist.kernels[i] = kernels[a]
+		ha := math.Pow(h, a)
+		hist.c1[i] = ha / math.Gamma(a+1)
+		hist.c2[i] = ha / math.Gamma(a+2)
+	}
+	return hist
+}
+
+// run computes the steps until the function done requests a stop or returns
+// an error
+func (hist *history) run() error {
+	f0 := make([]float64, hist.n)
+	if err := hist.f(hist.t0, hist.x0, f0); err != nil {
+		return err
+	}
+	hist.slopes = append(hist.slopes, f0)
+	if hist.direct {
+		for n := 1; ; n++ {
+			lo := 1
+			if hist.memory > 0 && n-hist.memory > lo {
+				lo = n - hist.memory
+			}
+			if stop, err := hist.step(n, lo); stop || err != nil {
+				return err
+			}
+		}
+	}
+
+	// The steps are computed by blocks of doubling size: the contributions of
+	// the steps [1,1+L) to the steps [1+L,1+2L) are added with the FFT before
+	// the computation of the block [1+L,1+2L).
+	hist.grow(1 + leafSize)
+	if stop, err := hist.block(1, 1+leafSize); stop || err != nil {
+		return err
+	}
+	for L := leafSize; ; L *= 2 {
+		hist.grow(1 + 2*L)
+		hist.cross(1, 1+L, 1+2*L)
+		if stop, err := hist.block(1+L, 1+2*L); stop || err != nil {
+			return err
+		}
+	}
+}
+
+// slope returns the slope of the step j
+func (hist *history) slope(j int) []float64 {
+	return hist.slopes[j-hist.first]
+}
+
+// grow allocates the sums of the steps up to the step n (excluded), for the
+// FFT summation
+func (hist *history) grow(n int) {
+	for len(hist.sumP) < n {
+		hist.sumP = append(hist.sumP, make([]float64, hist.n))
+		hist.sumC = append(hist.sumC, make([]float64, hist.n))
+	}
+}
+
+// block computes the steps [a,b), whose history before a is already summed in
+// sumP and sumC: the block is split in two halves, and the contributions of
+// the first half to the second one are added with the FFT. The function
+// returns true if a step requests a stop.
+func (hist *history) block(a, b int) (bool, error) {
+	if b-a <= leafSize {
+		for n := a; n < b; n++ {
+			if stop, err := hist.step(n, a); stop || err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	}
+	m := (a + b) / 2
+	if stop, err := hist.block(a, m); stop || err != nil {
+		return true, err
+	}
+	hist.cross(a, m, b)
+	return hist.block(m, b)
+}
+
+// cross adds the contributions of the slopes of the steps [a,m) to the sums of
+// the steps [m,b), where b-a=2*(m-a), with the FFT convolution of the slopes
+// and the weights.
+func (hist *history) cross(a, m, b int) {
+	L := m - a
+	size := 4 * L
+	g := make([]complex128, size)
+	kb := make([]complex128, size)
+	ka := make([]complex128, size)
+	for i := 0; i < hist.n; i++ {
+		if hist.kernels[i].alpha == 1 {
+			continue
+		}
+		B, A := hist.kernels[i].weights(b - a)
+		for k := range g {
+			g[k], kb[k], ka[k] = 0, 0, 0
+		}
+		for s := 0; s < L; s++ {
+			g[s] = complex(hist.slope(a + s)[i], 0)
+		}
+		for q := 1; q < b-a; q++ {
+			kb[q] = complex(B[q], 0)
+			ka[q] = complex(A[q], 0)
+		}
+		fft(g, false)
+		fft(kb, false)
+		fft(ka, false)
+		for k := range g {
+			kb[k] *= g[k]
+			ka[k] *= g[k]
+		}
+		fft(kb, true)
+		fft(ka, true)
+		// The step n=m+u receives the terms of the convolution of index L+u
+		for u := 0; u < b-m; u++ {
+			hist.sumP[m+u][i] += real(kb[L+u]) / float64(size)
+			hist.sumC[m+u][i] += real(ka[L+u]) / float64(size)
+		}
+	}
+}
+
+// step computes the step n, whose history before the step lo is already
+// summed in sumP and sumC for the FFT summation (the steps [lo,n) are summed
+// directly). The function returns true if the function done requests a stop.
+func (hist *history) step(n, lo int) (bool, error) {
+	t := hist.t0 + float64(n)*hist.h
+	full := hist.memory == 0 || n <= hist.memory // the initial slope is in the memory
+	fn1 := hist.slope(n - 1)
+	for i := 0; i < hist.n; i++ {
+		if hist.kernels[i].alpha == 1 {
+			// The method is the trapezoidal rule, whose history is summed in
+			// the previous state (that is not subject to the short memory)
+			hist.xp[i] = hist.prev[i] + hist.c1[i]*fn1[i]
+			hist.x[i] = hist.prev[i] + hist.c2[i]*fn1[i]
+			continue
+		}
+		B, A := hist.kernels[i].weights(n)
+		sp, sc := 0.0, 0.0
+		if !hist.direct {
+			sp, sc = hist.sumP[n][i], hist.sumC[n][i]
+		}
+		for j := lo; j < n; j++ {
+			fj := hist.slope(j)
+			sp += B[n-j] * fj[i]
+			sc += A[n-j] * fj[i]
+		}
+		if full {
+			alpha := hist.kernels[i].alpha
+			nf := float64(n)
+			a0 := math.Pow(nf-1, alpha+1) - (nf-1-alpha)*math.Pow(nf, alpha)
+			f0 := hist.slope(0)
+			sp += B[n] * f0[i]
+			sc += a0 * f0[i]
+		}
+		hist.xp[i] = hist.x0[i] + hist.c1[i]*sp
+		hist.x[i] = hist.x0[i] + hist.c2[i]*sc // without the corrector term
+	}
+	for k := 0; k < hist.iters; k++ {
+		if err := hist.f(t, hist.xp, hist.fp); err != nil {
+			return true, err
+		}
+		for i := range hist.xp {
+			hist.xp[i] = hist.x[i] + hist.c2[i]*hist.fp[i]
+		}
+	}
+	copy(hist.x, hist.xp)
+	copy(hist.prev, hist.x)
+
+	// With a short memory, the slope of the step n-memory is out of the
+	// memory of the next steps, and its vector is reused for the slope of the
+	// step n.
+	var fn []float64
+	if hist.memory > 0 && hist.first <= n-hist.memory {
+		fn = hist.slopes[0]
+		hist.slopes = append(hist.slopes[:0], hist.slopes[1:]...)
+		hist.first++
+	} else {
+		fn = make([]float64, hist.n)
+	}
+	if err := hist.f(t, hist.x, fn); err != nil {
+		return true, err
+	}
+	hist.slopes = append(hist.slopes, fn)
+	return hist.done(n, hist.x)
+}
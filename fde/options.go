@@ -0,0 +1,67 @@
+package fde
+
+import "github.com/gboulant/dingo-ode/solver"
+
+// settings gathers the parameters of a solving process. The parameters are
+// initialized with default values and then modified by the Option functions.
+type settings struct {
+	memory     float64 // length of the short memory (0 for the full memory)
+	direct     bool    // direct summation of the history instead of the FFT
+	correctors int     // number of corrector iterations
+
+	checkInterval uint64 // number of steps between two checks of the context
+}
+
+// Option defines a function that modifies the settings of a solving process
+type Option func(s *settings)
+
+// newSettings returns the settings defined by the default values modified by
+// the given options.
+func newSettings(opts ...Option) settings {
+	s := settings{correctors: 1, checkInterval: solver.DefaultCheckInterval}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// ShortMemory specifies that the history is truncated to the given length
+// (the short memory principle of Podlubny): the fractional derivatives only
+// depend on the states of the interval [t-length,t]. The cost of a step is
+// then constant instead of growing with the length of the history, at the
+// price of an error that decreases with the length of the memory.
+func ShortMemory(length float64) Option {
+	return func(s *settings) {
+		s.memory = length
+	}
+}
+
+// DirectSummation specifies that the whole history is summed directly at each
+// step, instead of using the FFT. The cost of the solving process is then
+// proportional to N^2 instead of N*log(N)^2 for N steps. It is intended for
+// the comparison with the FFT summation.
+func DirectSummation() Option {
+	return func(s *settings) {
+		s.direct = true
+	}
+}
+
+// Correctors specifies the number of iterations of the corrector at each step.
+// The default value is 1 (PECE scheme).
+func Correctors(n int) Option {
+	return func(s *settings) {
+		s.correctors = n
+	}
+}
+
+// CheckEvery specifies the number of steps between two checks of the context
+// of a solving process (see solver.CheckEvery). The default value is
+// solver.DefaultCheckInterval, and a value of 0 is considered as 1.
+func CheckEvery(n uint64) Option {
+	return func(s *settings) {
+		if n == 0 {
+			n = 1
+		}
+		s.checkInterval = n
+	}
+}
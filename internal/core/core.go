@@ -0,0 +1,13 @@
+// Package core implements the helpers shared by the solvers of the packages
+// solver, sde, ssa and fde (the errors, the statistics and the workspaces),
+// that are not part of the API of the package solver.
+package core
+
+// Resize returns a slice of length n, reusing the memory of v if its capacity
+// is sufficient.
+func Resize(v []float64, n int) []float64 {
+	if cap(v) < n {
+		return make([]float64, n)
+	}
+	return v[:n]
+}
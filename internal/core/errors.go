@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is the error returned by the solvers and the controllers when a solving
+// process fails (see solver.Error).
+type Error struct {
+	Kind error     // kind of the error (ErrStepSizeTooSmall, ErrNonFinite, etc)
+	T    float64   // time of the failure
+	X    []float64 // state at the time of the failure (a copy)
+	H    float64   // step size at the time of the failure (0 if unknown)
+	Err  error     // underlying error, or details about the failure (can be nil)
+}
+
+// NewError creates an Error of the given kind for the state (t,X) and the step
+// size h, with a copy of X. If err is already an Error, it is returned
+// unchanged, except for the step size that is completed if unknown, so that
+// all the solvers return the same errors.
+func NewError(kind error, err error, t float64, X []float64, h float64) error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.H == 0 {
+			e.H = h
+		}
+		return err
+	}
+	state := make([]float64, len(X))
+	copy(state, X)
+	return &Error{Kind: kind, T: t, X: state, H: h, Err: err}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	s := fmt.Sprintf("%v at t=%g", e.Kind, e.T)
+	if e.H != 0 {
+		s += fmt.Sprintf(" (h=%g)", e.H)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Is returns true if the target is the kind of the error, so that the kind can
+// be checked with errors.Is.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
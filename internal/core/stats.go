@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Stats gathers the statistics of a solving process (see solver.Stats)
+type Stats struct {
+	FunctionEvaluations uint64        // number of calls of the function f
+	JacobianEvaluations uint64        // number of evaluations of the Jacobian matrix of f
+	LUFactorizations    uint64        // number of LU factorizations of the iteration matrix
+	AcceptedSteps       uint64        // number of steps accepted by the method
+	RejectedSteps       uint64        // number of steps rejected by the error control
+	MinStep             float64       // minimal absolute step size of the accepted steps
+	MaxStep             float64       // maximal absolute step size of the accepted steps
+	MeanStep            float64       // mean absolute step size of the accepted steps
+	WallTime            time.Duration // duration of the whole solving process
+	FunctionTime        time.Duration // time spent inside the function f
+}
+
+// Accept registers in the statistics an accepted step of size h
+func Accept(stats *Stats, h float64) {
+	h = math.Abs(h)
+	stats.AcceptedSteps++
+	if stats.AcceptedSteps == 1 || h < stats.MinStep {
+		stats.MinStep = h
+	}
+	if h > stats.MaxStep {
+		stats.MaxStep = h
+	}
+	stats.MeanStep += (h - stats.MeanStep) / float64(stats.AcceptedSteps)
+}
+
+// Instrument returns a function that wraps f to count its evaluations and to
+// measure the time spent inside in the statistics.
+func Instrument(stats *Stats, f func(t float64, X []float64, dXdt []float64) error) func(t float64, X []float64, dXdt []float64) error {
+	return func(t float64, X []float64, dXdt []float64) error {
+		start := time.Now()
+		err := f(t, X, dXdt)
+		stats.FunctionTime += time.Since(start)
+		stats.FunctionEvaluations++
+		return err
+	}
+}
+
+// String returns a summary of the statistics, one counter per line
+func (stats Stats) String() string {
+	s := ""
+	s += fmt.Sprintf("function evaluations: %d\n", stats.FunctionEvaluations)
+	s += fmt.Sprintf("jacobian evaluations: %d\n", stats.JacobianEvaluations)
+	s += fmt.Sprintf("LU factorizations   : %d\n", stats.LUFactorizations)
+	s += fmt.Sprintf("accepted steps      : %d\n", stats.AcceptedSteps)
+	s += fmt.Sprintf("rejected steps      : %d\n", stats.RejectedSteps)
+	s += fmt.Sprintf("step size (min)     : %g\n", stats.MinStep)
+	s += fmt.Sprintf("step size (max)     : %g\n", stats.MaxStep)
+	s += fmt.Sprintf("step size (mean)    : %g\n", stats.MeanStep)
+	s += fmt.Sprintf("wall time           : %v\n", stats.WallTime)
+	s += fmt.Sprintf("function time       : %v\n", stats.FunctionTime)
+	return s
+}
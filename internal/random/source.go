@@ -0,0 +1,53 @@
+// Package random implements a random source whose state can be saved and
+// restored, for the checkpoints of the stochastic solvers.
+package random
+
+import "math/rand"
+
+// Source is a rand.Source64 that counts the random numbers drawn from the
+// standard source of the package math/rand. Its state is then given by the
+// seed and the number of draws (see State), and it can be restored by drawing
+// again the same number of values from the seed (see Restore).
+type Source struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+// New creates a Source initialized with the given seed
+func New(seed int64) *Source {
+	return &Source{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+// Restore creates a Source initialized with the given seed, from which the
+// given number of values have been drawn.
+func Restore(seed int64, draws uint64) *Source {
+	s := New(seed)
+	for ; s.draws < draws; s.draws++ {
+		s.src.Uint64()
+	}
+	return s
+}
+
+// Int63 implements the rand.Source interface
+func (s *Source) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+// Uint64 implements the rand.Source64 interface
+func (s *Source) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+// Seed implements the rand.Source interface
+func (s *Source) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.seed, s.draws = seed, 0
+}
+
+// State returns the seed and the number of values drawn from the Source
+func (s *Source) State() (seed int64, draws uint64) {
+	return s.seed, s.draws
+}
@@ -0,0 +1,25 @@
+package random
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRestore checks that a restored Source draws the same values as the
+// original one, whatever the methods of rand.Rand used to draw them.
+func TestRestore(t *testing.T) {
+	src := New(42)
+	rng := rand.New(src)
+	for i := 0; i < 100; i++ {
+		rng.NormFloat64()
+		rng.Float64()
+		rng.Intn(10)
+	}
+	seed, draws := src.State()
+	restored := rand.New(Restore(seed, draws))
+	for i := 0; i < 100; i++ {
+		if x, y := rng.NormFloat64(), restored.NormFloat64(); x != y {
+			t.Fatalf("the value %d is %g instead of %g", i, y, x)
+		}
+	}
+}
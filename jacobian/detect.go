@@ -0,0 +1,86 @@
+package jacobian
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// probes is the number of random states used to detect a sparsity pattern
+const probes = 3
+
+// Detect detects the sparsity pattern of the Jacobian matrix of f by probing:
+// the dense Jacobian matrices are computed by finite differences at random
+// states around (t,X), and their nonzero values define the pattern. A value
+// that is zero at all the probed states (e.g. a term u*v with u=0) is missed,
+// so that the random states avoid the particular values of X. The detection
+// costs a few dense Jacobian matrices, and it is intended to be done once
+// before the solving process.
+func Detect(f solver.InPlaceFunction, t float64, X []float64) (*linalg.Pattern, error) {
+	if f == nil {
+		err := errors.New("the function f is not defined")
+		return nil, core.NewError(solver.ErrInvalidInput, err, t, X, 0)
+	}
+	n := len(X)
+	e, err := NewDense(f, n)
+	if err != nil {
+		return nil, err
+	}
+	pattern := linalg.NewPattern(n, n)
+	rng := rand.New(rand.NewSource(1))
+	J := linalg.NewMatrix(n, n)
+	Y := make([]float64, n)
+	for probe := 0; probe < probes; probe++ {
+		for i, x := range X {
+			Y[i] = x + 0.1*math.Max(1, math.Abs(x))*(rng.Float64()-0.5)
+		}
+		J.Zero()
+		if err := e.Jacobian(t, Y, J); err != nil {
+			return nil, err
+		}
+		for row := 0; row < n; row++ {
+			for col := 0; col < n; col++ {
+				if J.At(row, col) != 0 {
+					pattern.Add(row, col)
+				}
+			}
+		}
+	}
+	return pattern, nil
+}
+
+// Check checks that the given pattern contains the pattern detected by probing
+// the function f around (t,X) (see Detect), and returns an error for the
+// first value of the Jacobian matrix that is outside the pattern. It is
+// intended to validate a pattern given by the user (e.g. with NewSparse)
+// during the development of a model, as the detection costs a few dense
+// Jacobian matrices.
+func Check(f solver.InPlaceFunction, pattern *linalg.Pattern, t float64, X []float64) error {
+	if pattern == nil {
+		err := errors.New("the sparsity pattern is not defined")
+		return core.NewError(solver.ErrInvalidInput, err, t, X, 0)
+	}
+	n := len(X)
+	if pattern.Rows != n || pattern.Cols != n {
+		err := fmt.Errorf("the pattern is %dx%d for a state of size %d", pattern.Rows, pattern.Cols, n)
+		return core.NewError(solver.ErrDimensionMismatch, err, t, X, 0)
+	}
+	detected, err := Detect(f, t, X)
+	if err != nil {
+		return err
+	}
+	for row := 0; row < n; row++ {
+		for _, col := range detected.Row(row) {
+			if !pattern.Has(row, col) {
+				err := fmt.Errorf("the Jacobian value (%d,%d) is outside the pattern", row, col)
+				return core.NewError(solver.ErrInvalidInput, err, t, X, 0)
+			}
+		}
+	}
+	return nil
+}
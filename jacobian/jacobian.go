@@ -0,0 +1,210 @@
+// Package jacobian computes the Jacobian matrices J=∂f/∂X of the rate
+// functions by finite differences, for the implicit solvers of the package
+// solver (see solver.JacobianFunction).
+//
+// A dense Jacobian matrix costs one evaluation of f for each column, i.e. N
+// evaluations for a system of size N. The Jacobian matrices of the large
+// systems (e.g. the method of lines) are sparse: the columns that have no
+// nonzero value on the same row (structurally orthogonal columns) can then be
+// computed with the same evaluation of f, by perturbing their components
+// together. The columns are grouped by a coloring of their intersection graph
+// (Curtis, Powell and Reid), so that the number of evaluations is the number
+// of colors, that does not depend on N for the sparse matrices whose rows have
+// a bounded number of nonzero values.
+//
+// The sparsity pattern of the Jacobian matrix is given (e.g. by the layout of
+// a PDE in the package mol), or detected by probing the function f (see
+// Detect).
+package jacobian
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// epsilon is the machine epsilon of the float64 numbers
+const epsilon = 2.220446049250313e-16
+
+// Estimator computes the Jacobian matrices of a function by finite
+// differences. Its method Jacobian is a solver.JacobianFunction.
+type Estimator struct {
+	f       solver.InPlaceFunction
+	n       int
+	columns [][]int // rows of the nonzero values of each column (nil for dense)
+	groups  [][]int // groups of columns computed with the same evaluation
+	f0, fd  []float64
+	y       []float64
+	delta   []float64
+	evals   uint64
+}
+
+// NewDense creates an estimator of the dense Jacobian matrices of the
+// function f for the states of size n: each column is computed with one
+// evaluation of f.
+func NewDense(f solver.InPlaceFunction, n int) (*Estimator, error) {
+	if f == nil {
+		err := errors.New("the function f is not defined")
+		return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+	}
+	e := newEstimator(f, n)
+	e.groups = make([][]int, n)
+	for col := range e.groups {
+		e.groups[col] = []int{col}
+	}
+	return e, nil
+}
+
+// NewSparse creates an estimator of the sparse Jacobian matrices of the
+// function f, whose nonzero values are defined by the pattern: the columns are
+// grouped by a coloring of the pattern, and each group of columns is computed
+// with one evaluation of f. The values outside the pattern are zero. For a
+// pattern with narrow bands, the implicit solvers can also use the pattern
+// directly with a band factorization (see solver.MassMatrixProblem).
+func NewSparse(f solver.InPlaceFunction, pattern *linalg.Pattern) (*Estimator, error) {
+	if f == nil {
+		err := errors.New("the function f is not defined")
+		return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+	}
+	if pattern == nil {
+		err := errors.New("the sparsity pattern is not defined")
+		return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+	}
+	if pattern.Rows != pattern.Cols {
+		err := fmt.Errorf("the pattern is not square (%dx%d)", pattern.Rows, pattern.Cols)
+		return nil, core.NewError(solver.ErrDimensionMismatch, err, 0, nil, 0)
+	}
+	n := pattern.Rows
+	e := newEstimator(f, n)
+	e.columns = make([][]int, n)
+	for row := 0; row < n; row++ {
+		for _, col := range pattern.Row(row) {
+			e.columns[col] = append(e.columns[col], row)
+		}
+	}
+	colors := Coloring(pattern)
+	for col, color := range colors {
+		for len(e.groups) <= color {
+			e.groups = append(e.groups, nil)
+		}
+		e.groups[color] = append(e.groups[color], col)
+	}
+	return e, nil
+}
+
+// newEstimator creates an estimator with the workspace for the size n
+func newEstimator(f solver.InPlaceFunction, n int) *Estimator {
+	return &Estimator{
+		f:     f,
+		n:     n,
+		f0:    make([]float64, n),
+		fd:    make([]float64, n),
+		y:     make([]float64, n),
+		delta: make([]float64, n),
+	}
+}
+
+// Jacobian computes the Jacobian matrix J of f at (t,X). The matrix J should
+// have the size n x n and be filled with zeros (as given by the solvers).
+func (e *Estimator) Jacobian(t float64, X []float64, J *linalg.Matrix) error {
+	if len(X) != e.n {
+		err := fmt.Errorf("the state of size %d does not match the size %d of the estimator", len(X), e.n)
+		return core.NewError(solver.ErrDimensionMismatch, err, t, X, 0)
+	}
+	if J.Rows != e.n || J.Cols != e.n {
+		err := fmt.Errorf("the matrix of size %dx%d does not match the size %d of the estimator", J.Rows, J.Cols, e.n)
+		return core.NewError(solver.ErrDimensionMismatch, err, t, X, 0)
+	}
+	e.evals++
+	if err := e.f(t, X, e.f0); err != nil {
+		return err
+	}
+	copy(e.y, X)
+	for _, group := range e.groups {
+		for _, col := range group {
+			e.delta[col] = math.Sqrt(epsilon) * math.Max(1, math.Abs(X[col]))
+			e.y[col] = X[col] + e.delta[col]
+		}
+		e.evals++
+		if err := e.f(t, e.y, e.fd); err != nil {
+			return err
+		}
+		for _, col := range group {
+			e.y[col] = X[col]
+			if e.columns == nil {
+				for row := 0; row < e.n; row++ {
+					J.Set(row, col, (e.fd[row]-e.f0[row])/e.delta[col])
+				}
+				continue
+			}
+			for _, row := range e.columns[col] {
+				J.Set(row, col, (e.fd[row]-e.f0[row])/e.delta[col])
+			}
+		}
+	}
+	return nil
+}
+
+// Groups returns the number of groups of columns, i.e. the number of
+// evaluations of f for a Jacobian matrix (in addition to the evaluation at X)
+func (e *Estimator) Groups() int {
+	return len(e.groups)
+}
+
+// Evaluations returns the number of evaluations of f since the creation of
+// the estimator
+func (e *Estimator) Evaluations() uint64 {
+	return e.evals
+}
+
+// Coloring returns the colors of the columns of the pattern, such that two
+// columns with a nonzero value on the same row have different colors. The
+// colors are 0, 1, 2, etc. The coloring is computed by the greedy algorithm,
+// with the columns ordered by decreasing number of nonzero values (largest
+// first), which gives a number of colors close to the maximal number of
+// nonzero values of a row for the patterns of the finite difference stencils.
+func Coloring(pattern *linalg.Pattern) []int {
+	n := pattern.Cols
+	columns := make([][]int, n)
+	for row := 0; row < pattern.Rows; row++ {
+		for _, col := range pattern.Row(row) {
+			columns[col] = append(columns[col], row)
+		}
+	}
+	order := make([]int, n)
+	for col := range order {
+		order[col] = col
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(columns[order[a]]) > len(columns[order[b]])
+	})
+
+	colors := make([]int, n)
+	for col := range colors {
+		colors[col] = -1
+	}
+	forbidden := make([]int, 0) // forbidden[c] == col if the color c is used by a neighbour of col
+	for _, col := range order {
+		for _, row := range columns[col] {
+			for _, other := range pattern.Row(row) {
+				if c := colors[other]; c >= 0 {
+					forbidden[c] = col
+				}
+			}
+		}
+		color := 0
+		for color < len(forbidden) && forbidden[color] == col {
+			color++
+		}
+		if color == len(forbidden) {
+			forbidden = append(forbidden, -1)
+		}
+		colors[col] = color
+	}
+	return colors
+}
@@ -0,0 +1,160 @@
+package linalg
+
+import (
+	"fmt"
+	"math"
+)
+
+// Band is a square band matrix of size N x N with KL sub-diagonals and KU
+// super-diagonals, i.e. the value (i,j) is zero if j-i<-KL or j-i>KU. The
+// values are stored by columns with KL additional super-diagonals for the
+// fill-in of the LU factorization (the storage of LAPACK).
+type Band struct {
+	N, KL, KU int
+	data      []float64
+}
+
+// NewBand creates a band matrix of size n x n with kl sub-diagonals and ku
+// super-diagonals, filled with zeros
+func NewBand(n, kl, ku int) *Band {
+	b := &Band{}
+	b.Resize(n, kl, ku)
+	return b
+}
+
+// stride returns the number of values stored for each column
+func (b *Band) stride() int {
+	return 2*b.KL + b.KU + 1
+}
+
+// index returns the index of the value (i,j) in the storage
+func (b *Band) index(i, j int) int {
+	return j*b.stride() + b.KL + b.KU + i - j
+}
+
+// At returns the value (i,j) of the matrix
+func (b *Band) At(i, j int) float64 {
+	if j-i < -b.KL || j-i > b.KU {
+		return 0
+	}
+	return b.data[b.index(i, j)]
+}
+
+// Set defines the value (i,j) of the matrix, that should be in the band
+func (b *Band) Set(i, j int, v float64) {
+	if j-i < -b.KL || j-i > b.KU {
+		panic(fmt.Sprintf("ERR: the value (%d,%d) is outside the band of the matrix", i, j))
+	}
+	b.data[b.index(i, j)] = v
+}
+
+// Resize changes the size and the bandwidths of the matrix, reusing its
+// memory if possible, and fills it with zeros.
+func (b *Band) Resize(n, kl, ku int) {
+	b.N, b.KL, b.KU = n, kl, ku
+	size := n * b.stride()
+	if cap(b.data) < size {
+		b.data = make([]float64, size)
+	}
+	b.data = b.data[:size]
+	b.Zero()
+}
+
+// Zero sets all the values of the matrix to zero
+func (b *Band) Zero() {
+	for i := range b.data {
+		b.data[i] = 0
+	}
+}
+
+// BandLU is the LU factorization with partial pivoting of a band matrix. The
+// factors have the lower bandwidth KL and the upper bandwidth KL+KU, so that
+// the cost of the factorization is proportional to N*KL*(KL+KU) instead of
+// N^3 for a dense matrix.
+type BandLU struct {
+	lu     Band
+	pivots []int
+}
+
+// Factorize computes the LU factorization of the band matrix a. The error
+// ErrSingular is returned if a pivot is zero (relatively to the magnitude of
+// the matrix).
+func (f *BandLU) Factorize(a *Band) error {
+	n, kl, ku := a.N, a.KL, a.KU
+	f.lu.Resize(n, kl, ku)
+	copy(f.lu.data, a.data)
+	if cap(f.pivots) < n {
+		f.pivots = make([]int, n)
+	}
+	f.pivots = f.pivots[:n]
+
+	norm := 0.0
+	for _, v := range a.data {
+		norm = math.Max(norm, math.Abs(v))
+	}
+	threshold := float64(n) * 2.220446049250313e-16 * norm
+
+	lu := &f.lu
+	for k := 0; k < n; k++ {
+		last := min(n-1, k+kl)     // last row with a value in the column k
+		right := min(n-1, k+kl+ku) // last column of the row k after the fill-in
+		p := k
+		for i := k + 1; i <= last; i++ {
+			if math.Abs(lu.data[lu.index(i, k)]) > math.Abs(lu.data[lu.index(p, k)]) {
+				p = i
+			}
+		}
+		f.pivots[k] = p
+		if math.Abs(lu.data[lu.index(p, k)]) <= threshold {
+			return ErrSingular
+		}
+		if p != k {
+			for j := k; j <= right; j++ {
+				ik, ip := lu.index(k, j), lu.index(p, j)
+				lu.data[ik], lu.data[ip] = lu.data[ip], lu.data[ik]
+			}
+		}
+		pivot := lu.data[lu.index(k, k)]
+		for i := k + 1; i <= last; i++ {
+			l := lu.data[lu.index(i, k)] / pivot
+			lu.data[lu.index(i, k)] = l
+			if l == 0 {
+				continue
+			}
+			for j := k + 1; j <= right; j++ {
+				lu.data[lu.index(i, j)] -= l * lu.data[lu.index(k, j)]
+			}
+		}
+	}
+	return nil
+}
+
+// Solve solves the system A.x = b using the factorization of A. The vector b
+// is overwritten by the solution x.
+func (f *BandLU) Solve(b []float64) {
+	lu := &f.lu
+	n, kl, ku := lu.N, lu.KL, lu.KU
+	for k := 0; k < n; k++ {
+		if p := f.pivots[k]; p != k {
+			b[k], b[p] = b[p], b[k]
+		}
+		for i := k + 1; i <= min(n-1, k+kl); i++ {
+			b[i] -= lu.data[lu.index(i, k)] * b[k]
+		}
+	}
+	for k := n - 1; k >= 0; k-- {
+		s := b[k]
+		for j := k + 1; j <= min(n-1, k+kl+ku); j++ {
+			s -= lu.data[lu.index(k, j)] * b[j]
+		}
+		b[k] = s / lu.data[lu.index(k, k)]
+	}
+}
+
+// min returns the minimum of the integers a and b
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
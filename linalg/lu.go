@@ -0,0 +1,100 @@
+package linalg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrSingular is the error returned when a matrix is singular
+var ErrSingular = errors.New("ERR: the matrix is singular")
+
+// LU is the LU factorization with partial pivoting of a square matrix A, i.e.
+// P.A = L.U where P is a permutation matrix, L a lower triangular matrix with
+// unit diagonal and U an upper triangular matrix. The memory of an LU is
+// reused from one factorization to the other.
+type LU struct {
+	lu     Matrix // L and U stored in the same matrix
+	pivots []int  // row permutations
+}
+
+// Factorize computes the LU factorization of the square matrix a. The error
+// ErrSingular is returned if a pivot is zero (relatively to the magnitude of
+// the matrix).
+func (f *LU) Factorize(a *Matrix) error {
+	if a.Rows != a.Cols {
+		return fmt.Errorf("ERR: the matrix is not square (%dx%d)", a.Rows, a.Cols)
+	}
+	n := a.Rows
+	f.lu.Copy(a)
+	if cap(f.pivots) < n {
+		f.pivots = make([]int, n)
+	}
+	f.pivots = f.pivots[:n]
+
+	norm := 0.0
+	for _, v := range a.Data {
+		norm = math.Max(norm, math.Abs(v))
+	}
+	threshold := float64(n) * 2.220446049250313e-16 * norm
+
+	lu := f.lu.Data
+	for k := 0; k < n; k++ {
+		// Selection of the pivot
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(lu[i*n+k]) > math.Abs(lu[p*n+k]) {
+				p = i
+			}
+		}
+		f.pivots[k] = p
+		if math.Abs(lu[p*n+k]) <= threshold {
+			return ErrSingular
+		}
+		if p != k {
+			for j := 0; j < n; j++ {
+				lu[k*n+j], lu[p*n+j] = lu[p*n+j], lu[k*n+j]
+			}
+		}
+
+		// Elimination below the pivot
+		pivot := lu[k*n+k]
+		for i := k + 1; i < n; i++ {
+			l := lu[i*n+k] / pivot
+			lu[i*n+k] = l
+			if l == 0 {
+				continue
+			}
+			for j := k + 1; j < n; j++ {
+				lu[i*n+j] -= l * lu[k*n+j]
+			}
+		}
+	}
+	return nil
+}
+
+// Solve solves the system A.x = b using the factorization of A. The vector b
+// is overwritten by the solution x.
+func (f *LU) Solve(b []float64) {
+	n := f.lu.Rows
+	lu := f.lu.Data
+	for k := 0; k < n; k++ {
+		if p := f.pivots[k]; p != k {
+			b[k], b[p] = b[p], b[k]
+		}
+	}
+	for i := 0; i < n; i++ {
+		s := b[i]
+		for j := 0; j < i; j++ {
+			s -= lu[i*n+j] * b[j]
+		}
+		b[i] = s
+	}
+	for i := n - 1; i >= 0; i-- {
+		s := b[i]
+		for j := i + 1; j < n; j++ {
+			s -= lu[i*n+j] * b[j]
+		}
+		b[i] = s / lu[i*n+i]
+	}
+}
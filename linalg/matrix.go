@@ -0,0 +1,95 @@
+// Package linalg provides the basic linear algebra tools required by the
+// implicit solvers of the package solver: a dense matrix, a band matrix and
+// their LU factorizations, and the sparsity pattern of a matrix.
+package linalg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Matrix is a dense matrix whose values are stored by rows in the slice Data,
+// i.e. the value (i,j) is Data[i*Cols+j].
+type Matrix struct {
+	Rows, Cols int
+	Data       []float64
+}
+
+// NewMatrix creates a matrix of size rows x cols filled with zeros
+func NewMatrix(rows, cols int) *Matrix {
+	return &Matrix{Rows: rows, Cols: cols, Data: make([]float64, rows*cols)}
+}
+
+// Identity creates the identity matrix of size n x n
+func Identity(n int) *Matrix {
+	m := NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.Data[i*n+i] = 1
+	}
+	return m
+}
+
+// Diagonal creates a square matrix with the given values on its diagonal
+func Diagonal(values ...float64) *Matrix {
+	n := len(values)
+	m := NewMatrix(n, n)
+	for i, v := range values {
+		m.Data[i*n+i] = v
+	}
+	return m
+}
+
+// At returns the value (i,j) of the matrix
+func (m *Matrix) At(i, j int) float64 {
+	return m.Data[i*m.Cols+j]
+}
+
+// Set defines the value (i,j) of the matrix
+func (m *Matrix) Set(i, j int, v float64) {
+	m.Data[i*m.Cols+j] = v
+}
+
+// Resize changes the size of the matrix, reusing its memory if possible, and
+// fills it with zeros.
+func (m *Matrix) Resize(rows, cols int) {
+	if cap(m.Data) < rows*cols {
+		m.Data = make([]float64, rows*cols)
+	}
+	m.Rows, m.Cols, m.Data = rows, cols, m.Data[:rows*cols]
+	m.Zero()
+}
+
+// Zero sets all the values of the matrix to zero
+func (m *Matrix) Zero() {
+	for i := range m.Data {
+		m.Data[i] = 0
+	}
+}
+
+// Copy copies the values of the matrix a in the matrix m, that is resized if
+// needed.
+func (m *Matrix) Copy(a *Matrix) {
+	m.Resize(a.Rows, a.Cols)
+	copy(m.Data, a.Data)
+}
+
+// MulVec computes the product y = m.x
+func (m *Matrix) MulVec(x, y []float64) {
+	for i := 0; i < m.Rows; i++ {
+		s := 0.0
+		row := m.Data[i*m.Cols : (i+1)*m.Cols]
+		for j, v := range row {
+			s += v * x[j]
+		}
+		y[i] = s
+	}
+}
+
+// String returns a representation of the matrix, one row per line
+func (m *Matrix) String() string {
+	var b strings.Builder
+	for i := 0; i < m.Rows; i++ {
+		fmt.Fprintf(&b, "%v\n", m.Data[i*m.Cols:(i+1)*m.Cols])
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package linalg
+
+import "sort"
+
+// Pattern is the sparsity pattern of a matrix of size Rows x Cols, i.e. the
+// positions of the values that can be nonzero. The columns of the nonzero
+// values of each row are kept sorted.
+type Pattern struct {
+	Rows, Cols int
+	rows       [][]int
+}
+
+// NewPattern creates the pattern of a matrix of size rows x cols without
+// nonzero values
+func NewPattern(rows, cols int) *Pattern {
+	return &Pattern{Rows: rows, Cols: cols, rows: make([][]int, rows)}
+}
+
+// Add adds the position (i,j) to the nonzero values of the pattern
+func (p *Pattern) Add(i, j int) {
+	row := p.rows[i]
+	k := sort.SearchInts(row, j)
+	if k < len(row) && row[k] == j {
+		return
+	}
+	row = append(row, 0)
+	copy(row[k+1:], row[k:])
+	row[k] = j
+	p.rows[i] = row
+}
+
+// Has returns true if the value (i,j) can be nonzero
+func (p *Pattern) Has(i, j int) bool {
+	row := p.rows[i]
+	k := sort.SearchInts(row, j)
+	return k < len(row) && row[k] == j
+}
+
+// Row returns the sorted columns of the nonzero values of the row i. The
+// slice should not be modified.
+func (p *Pattern) Row(i int) []int {
+	return p.rows[i]
+}
+
+// Nonzeros returns the number of nonzero values of the pattern
+func (p *Pattern) Nonzeros() int {
+	n := 0
+	for _, row := range p.rows {
+		n += len(row)
+	}
+	return n
+}
+
+// Bandwidths returns the numbers of sub-diagonals and super-diagonals of the
+// smallest band that contains the pattern (see Band)
+func (p *Pattern) Bandwidths() (kl, ku int) {
+	for i, row := range p.rows {
+		if len(row) == 0 {
+			continue
+		}
+		if d := i - row[0]; d > kl {
+			kl = d
+		}
+		if d := row[len(row)-1] - i; d > ku {
+			ku = d
+		}
+	}
+	return kl, ku
+}
@@ -0,0 +1,101 @@
+package mol
+
+// ConditionKind defines the kind of a boundary condition
+type ConditionKind int
+
+const (
+	// DirichletCondition defines the value u of the field on the boundary
+	DirichletCondition ConditionKind = iota
+	// NeumannCondition defines the derivative du/dn of the field along the
+	// outward normal of the boundary (the flux leaving the domain is -du/dn)
+	NeumannCondition
+	// PeriodicCondition defines a periodic field, whose ghost points are the
+	// points of the opposite side of the grid. The opposite boundary should
+	// also be periodic.
+	PeriodicCondition
+)
+
+func (kind ConditionKind) String() string {
+	switch kind {
+	case DirichletCondition:
+		return "dirichlet"
+	case NeumannCondition:
+		return "neumann"
+	case PeriodicCondition:
+		return "periodic"
+	}
+	return "unknown"
+}
+
+// BoundaryFunction defines the value of a boundary condition at time t and at
+// the coordinate s along the boundary (the ordinate y for the left and right
+// boundaries, the abscissa x for the bottom and top boundaries, and 0 for a
+// 1D grid).
+type BoundaryFunction func(t, s float64) float64
+
+// Condition defines the boundary condition of a field on a side of the grid.
+// A nil Value defines the value 0, e.g. a homogeneous Dirichlet condition
+// u=0, or an insulated wall du/dn=0.
+type Condition struct {
+	Kind  ConditionKind
+	Value BoundaryFunction
+}
+
+// Dirichlet returns the condition of a constant value u=v on the boundary
+func Dirichlet(v float64) Condition {
+	return Condition{Kind: DirichletCondition, Value: constant(v)}
+}
+
+// Neumann returns the condition of a constant outward derivative du/dn=v on
+// the boundary
+func Neumann(v float64) Condition {
+	return Condition{Kind: NeumannCondition, Value: constant(v)}
+}
+
+// Periodic returns the periodic condition
+func Periodic() Condition {
+	return Condition{Kind: PeriodicCondition}
+}
+
+// constant returns the boundary function of the constant value v
+func constant(v float64) BoundaryFunction {
+	if v == 0 {
+		return nil
+	}
+	return func(t, s float64) float64 { return v }
+}
+
+// value returns the value of the condition at (t,s)
+func (c Condition) value(t, s float64) float64 {
+	if c.Value == nil {
+		return 0
+	}
+	return c.Value(t, s)
+}
+
+// ghost returns the value of the ghost point outside the boundary, from the
+// value u of the point inside the boundary (at the distance d/2 of the
+// boundary), and the value w of the point of the opposite side (for a
+// periodic condition).
+func (c Condition) ghost(t, s, u, w, d float64) float64 {
+	switch c.Kind {
+	case DirichletCondition:
+		return 2*c.value(t, s) - u
+	case NeumannCondition:
+		return u + d*c.value(t, s)
+	default:
+		return w
+	}
+}
+
+// Boundaries defines the boundary conditions of a field on the four sides of
+// the grid. The bottom and top conditions are ignored for a 1D grid. The zero
+// value defines the homogeneous Dirichlet conditions u=0.
+type Boundaries struct {
+	Left, Right, Bottom, Top Condition
+}
+
+// AllSides returns the boundaries with the same condition c on the four sides
+func AllSides(c Condition) Boundaries {
+	return Boundaries{Left: c, Right: c, Bottom: c, Top: c}
+}
@@ -0,0 +1,73 @@
+package mol
+
+import "fmt"
+
+// Grid is a uniform cell-centered grid of a rectangle [Ax,Bx]x[Ay,By] divided
+// in Nx x Ny cells, whose points are the centers of the cells: the point (i,j)
+// is at x=Ax+(i+1/2).dx, y=Ay+(j+1/2).dy. A 1D grid is a grid with Ny=1.
+//
+// The boundaries are then halfway between the first (or last) point and the
+// ghost point outside the grid, whatever the boundary conditions, so that the
+// same grid can be used by fields with different boundary conditions.
+type Grid struct {
+	Nx, Ny int
+	Ax, Bx float64
+	Ay, By float64
+}
+
+// NewGrid1D creates a 1D grid of the interval [a,b] with n points
+func NewGrid1D(a, b float64, n int) Grid {
+	return Grid{Nx: n, Ny: 1, Ax: a, Bx: b}
+}
+
+// NewGrid2D creates a 2D grid of the rectangle [ax,bx]x[ay,by] with nx x ny
+// points
+func NewGrid2D(ax, bx float64, nx int, ay, by float64, ny int) Grid {
+	return Grid{Nx: nx, Ny: ny, Ax: ax, Bx: bx, Ay: ay, By: by}
+}
+
+// check returns an error if the grid is not valid
+func (g Grid) check() error {
+	if g.Nx < 1 || g.Ny < 1 {
+		return fmt.Errorf("ERR: the grid should have at least one point (%dx%d)", g.Nx, g.Ny)
+	}
+	if !(g.Bx > g.Ax) || (g.Ny > 1 && !(g.By > g.Ay)) {
+		return fmt.Errorf("ERR: the grid of [%g,%g]x[%g,%g] is empty", g.Ax, g.Bx, g.Ay, g.By)
+	}
+	return nil
+}
+
+// Is2D returns true if the grid is a 2D grid
+func (g Grid) Is2D() bool {
+	return g.Ny > 1
+}
+
+// Points returns the number of points of the grid
+func (g Grid) Points() int {
+	return g.Nx * g.Ny
+}
+
+// Index returns the index of the point (i,j) (the points are ordered by rows)
+func (g Grid) Index(i, j int) int {
+	return j*g.Nx + i
+}
+
+// Dx returns the distance between two points along the x axis
+func (g Grid) Dx() float64 {
+	return (g.Bx - g.Ax) / float64(g.Nx)
+}
+
+// Dy returns the distance between two points along the y axis
+func (g Grid) Dy() float64 {
+	return (g.By - g.Ay) / float64(g.Ny)
+}
+
+// X returns the abscissa of the points (i,j)
+func (g Grid) X(i int) float64 {
+	return g.Ax + (float64(i)+0.5)*g.Dx()
+}
+
+// Y returns the ordinate of the points (i,j)
+func (g Grid) Y(j int) float64 {
+	return g.Ay + (float64(j)+0.5)*g.Dy()
+}
@@ -0,0 +1,158 @@
+package mol
+
+import (
+	"fmt"
+
+	"github.com/gboulant/dingo-ode/linalg"
+)
+
+// Field defines a scalar field of a PDE, e.g. a temperature or a
+// concentration, with its boundary conditions
+type Field struct {
+	Name string
+	BC   Boundaries
+}
+
+// Layout defines the layout of the state vector X of a PDE discretized on a
+// grid: the state is made of the values of the fields at the points of the
+// grid, stored field by field, i.e. the values of the field k are the slice
+// X[k*P:(k+1)*P] where P is the number of points of the grid.
+type Layout struct {
+	Grid   Grid
+	Fields []Field
+}
+
+// NewLayout creates the layout of the given fields on the grid, and checks
+// the grid and the boundary conditions.
+func NewLayout(grid Grid, fields ...Field) (Layout, error) {
+	if err := grid.check(); err != nil {
+		return Layout{}, err
+	}
+	if len(fields) == 0 {
+		return Layout{}, fmt.Errorf("ERR: the layout should have at least one field")
+	}
+	for _, field := range fields {
+		bc := field.BC
+		if (bc.Left.Kind == PeriodicCondition) != (bc.Right.Kind == PeriodicCondition) {
+			return Layout{}, fmt.Errorf("ERR: the left and right conditions of the field %s should be both periodic", field.Name)
+		}
+		if grid.Is2D() && (bc.Bottom.Kind == PeriodicCondition) != (bc.Top.Kind == PeriodicCondition) {
+			return Layout{}, fmt.Errorf("ERR: the bottom and top conditions of the field %s should be both periodic", field.Name)
+		}
+	}
+	return Layout{Grid: grid, Fields: fields}, nil
+}
+
+// Size returns the size of the state vector
+func (l Layout) Size() int {
+	return len(l.Fields) * l.Grid.Points()
+}
+
+// Index returns the index in the state vector of the value of the field k at
+// the point (i,j) of the grid (j=0 for a 1D grid)
+func (l Layout) Index(k, i, j int) int {
+	return k*l.Grid.Points() + l.Grid.Index(i, j)
+}
+
+// Field returns the values of the field k in the vector X (a state or a rate)
+func (l Layout) Field(X []float64, k int) []float64 {
+	P := l.Grid.Points()
+	return X[k*P : (k+1)*P]
+}
+
+// Initial returns the state vector defined by the initial values of the
+// fields, given by a function of (x,y) for each field (y=0 for a 1D grid).
+func (l Layout) Initial(values ...func(x, y float64) float64) ([]float64, error) {
+	if len(values) != len(l.Fields) {
+		return nil, fmt.Errorf("ERR: %d initial values are given for %d fields", len(values), len(l.Fields))
+	}
+	g := l.Grid
+	X := make([]float64, l.Size())
+	for k, value := range values {
+		for j := 0; j < g.Ny; j++ {
+			y := 0.0
+			if g.Is2D() {
+				y = g.Y(j)
+			}
+			for i := 0; i < g.Nx; i++ {
+				X[l.Index(k, i, j)] = value(g.X(i), y)
+			}
+		}
+	}
+	return X, nil
+}
+
+// Names returns the names of the components of the state vector, e.g. for
+// saving a time series: name_i for a 1D grid, and name_i_j for a 2D grid.
+func (l Layout) Names() []string {
+	g := l.Grid
+	names := make([]string, 0, l.Size())
+	for _, field := range l.Fields {
+		for j := 0; j < g.Ny; j++ {
+			for i := 0; i < g.Nx; i++ {
+				if g.Is2D() {
+					names = append(names, fmt.Sprintf("%s_%d_%d", field.Name, i, j))
+				} else {
+					names = append(names, fmt.Sprintf("%s_%d", field.Name, i))
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Pattern returns the sparsity pattern of the Jacobian matrix of a rate
+// function built with the operators of the layout: the rate of a field at a
+// point depends on the values of all the fields at the same point (the local
+// terms, e.g. the reactions) and at the neighbour points (the stencil of the
+// operators, wrapped for the periodic conditions). The stencil applies to all
+// the pairs of fields, because the rate of a field can depend on the gradient
+// of another one (e.g. the term u.∂v/∂x computed with Gradient). The pattern
+// can be used to compute the Jacobian matrix efficiently for the stiff
+// solvers (see the package jacobian), and it can be checked against the
+// pattern detected by probing the rate function (see jacobian.Check).
+func (l Layout) Pattern() *linalg.Pattern {
+	g := l.Grid
+	n := l.Size()
+	p := linalg.NewPattern(n, n)
+	for k := range l.Fields {
+		for j := 0; j < g.Ny; j++ {
+			for i := 0; i < g.Nx; i++ {
+				row := l.Index(k, i, j)
+				neighbours := [][2]int{{i, j}, {i - 1, j}, {i + 1, j}}
+				if g.Is2D() {
+					neighbours = append(neighbours, [2]int{i, j - 1}, [2]int{i, j + 1})
+				}
+				for m, field := range l.Fields {
+					for _, nb := range neighbours {
+						if ni, nj, ok := l.wrap(field.BC, nb[0], nb[1]); ok {
+							p.Add(row, l.Index(m, ni, nj))
+						}
+					}
+				}
+			}
+		}
+	}
+	return p
+}
+
+// wrap returns the point of the grid that defines the value at the point
+// (i,j), which is the point itself inside the grid, and the point of the
+// opposite side for a periodic condition. It returns false for the ghost
+// points of the other conditions.
+func (l Layout) wrap(bc Boundaries, i, j int) (int, int, bool) {
+	g := l.Grid
+	if i < 0 || i >= g.Nx {
+		if bc.Left.Kind != PeriodicCondition {
+			return 0, 0, false
+		}
+		i = (i + g.Nx) % g.Nx
+	}
+	if j < 0 || j >= g.Ny {
+		if bc.Bottom.Kind != PeriodicCondition {
+			return 0, 0, false
+		}
+		j = (j + g.Ny) % g.Ny
+	}
+	return i, j, true
+}
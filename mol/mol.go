@@ -0,0 +1,71 @@
+// Package mol implements the method of lines (MOL) for the partial
+// differential equations (PDE) of evolution in one or two space dimensions:
+//
+//	∂u/∂t = F(t, x, y, u, ∇u, Δu)
+//
+// The fields u are discretized on a grid, and their spatial derivatives are
+// approximated by finite differences, so that the PDE becomes a (large) ODE
+// system on the values of the fields at the points of the grid, that can be
+// solved by the solvers of the package solver. The ODE systems of the
+// diffusion terms are stiff, and they should be solved by an implicit solver
+// or with small step sizes.
+//
+// A PDE is defined by:
+//
+//   - a Grid, i.e. a uniform 1D or 2D grid of the domain,
+//   - a Layout, i.e. the fields of the PDE with their boundary conditions
+//     (Dirichlet, Neumann or periodic), that defines the state vector,
+//   - a rate function that sums the terms of the PDE, computed with the
+//     operators of the Layout (Laplacian, Advection, Gradient) and the local
+//     terms (e.g. reactions).
+//
+// The System gathers these definitions into a dynamical system that
+// implements the interfaces System and InPlaceSystem of the package system.
+package mol
+
+import (
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// System defines the ODE system of a PDE discretized on a layout, with its
+// default input parameters.
+type System struct {
+	Layout Layout
+	// Rate is the rate function of the discretized PDE. The rate dXdt is given
+	// filled with zeros, so that the terms computed by the operators of the
+	// layout can be added directly.
+	Rate solver.InPlaceFunction
+	T0   float64   // the initial time
+	X0   []float64 // the initial state (see Layout.Initial)
+	Step float64   // the default step size
+	Tmax float64   // the default final time
+}
+
+// F implements the rate function of the system
+func (system System) F(t float64, X []float64) ([]float64, error) {
+	dXdt := make([]float64, len(X))
+	err := system.FInPlace(t, X, dXdt)
+	return dXdt, err
+}
+
+// FInPlace implements the allocation-free variant of the function F
+func (system System) FInPlace(t float64, X []float64, dXdt []float64) error {
+	for i := range dXdt {
+		dXdt[i] = 0
+	}
+	return system.Rate(t, X, dXdt)
+}
+
+// GetDefaultInput returns the default input parameters of the system
+func (system System) GetDefaultInput() (t0 float64, X0 []float64, step float64, tmax float64) {
+	X0 = make([]float64, len(system.X0))
+	copy(X0, system.X0)
+	return system.T0, X0, system.Step, system.Tmax
+}
+
+// Pattern returns the sparsity pattern of the Jacobian matrix of the system
+// (see Layout.Pattern)
+func (system System) Pattern() *linalg.Pattern {
+	return system.Layout.Pattern()
+}
@@ -0,0 +1,108 @@
+package mol
+
+// The operators compute the finite difference approximations of the spatial
+// derivatives of a field k of a state X at time t (the time of the boundary
+// conditions). They add their result to the rate dXdt, so that the rate
+// function of a PDE is the sum of its terms, and they do not allocate memory.
+
+// at returns the value of the field u at the point (i,j), where (i,j) can be
+// a ghost point outside the grid whose value is defined by the boundary
+// conditions bc.
+func (l Layout) at(t float64, u []float64, bc Boundaries, i, j int) float64 {
+	g := l.Grid
+	switch {
+	case i < 0:
+		return bc.Left.ghost(t, l.along(j), u[g.Index(0, j)], u[g.Index(g.Nx-1, j)], g.Dx())
+	case i >= g.Nx:
+		return bc.Right.ghost(t, l.along(j), u[g.Index(g.Nx-1, j)], u[g.Index(0, j)], g.Dx())
+	case j < 0:
+		return bc.Bottom.ghost(t, g.X(i), u[g.Index(i, 0)], u[g.Index(i, g.Ny-1)], g.Dy())
+	case j >= g.Ny:
+		return bc.Top.ghost(t, g.X(i), u[g.Index(i, g.Ny-1)], u[g.Index(i, 0)], g.Dy())
+	}
+	return u[g.Index(i, j)]
+}
+
+// along returns the coordinate along the left and right boundaries of the
+// points of the row j (0 for a 1D grid)
+func (l Layout) along(j int) float64 {
+	if !l.Grid.Is2D() {
+		return 0
+	}
+	return l.Grid.Y(j)
+}
+
+// Laplacian adds coef*Δu to the rate of the field k, where Δu is the
+// Laplacian of the field computed with the 3 points (1D) or 5 points (2D)
+// centered scheme (second order).
+func (l Layout) Laplacian(t float64, X []float64, k int, coef float64, dXdt []float64) {
+	g := l.Grid
+	u, du, bc := l.Field(X, k), l.Field(dXdt, k), l.Fields[k].BC
+	cx := coef / (g.Dx() * g.Dx())
+	cy := 0.0
+	if g.Is2D() {
+		cy = coef / (g.Dy() * g.Dy())
+	}
+	for j := 0; j < g.Ny; j++ {
+		for i := 0; i < g.Nx; i++ {
+			p := g.Index(i, j)
+			d := cx * (l.at(t, u, bc, i-1, j) - 2*u[p] + l.at(t, u, bc, i+1, j))
+			if g.Is2D() {
+				d += cy * (l.at(t, u, bc, i, j-1) - 2*u[p] + l.at(t, u, bc, i, j+1))
+			}
+			du[p] += d
+		}
+	}
+}
+
+// Advection adds -(vx.∂u/∂x + vy.∂u/∂y) to the rate of the field k, i.e. the
+// transport of the field by the velocity (vx,vy), computed with the first
+// order upwind scheme. The velocities are given at each point of the grid,
+// and a nil velocity is a zero velocity (vy is ignored for a 1D grid).
+func (l Layout) Advection(t float64, X []float64, k int, vx, vy []float64, dXdt []float64) {
+	g := l.Grid
+	u, du, bc := l.Field(X, k), l.Field(dXdt, k), l.Fields[k].BC
+	dx, dy := g.Dx(), g.Dy()
+	for j := 0; j < g.Ny; j++ {
+		for i := 0; i < g.Nx; i++ {
+			p := g.Index(i, j)
+			if vx != nil {
+				if v := vx[p]; v > 0 {
+					du[p] -= v * (u[p] - l.at(t, u, bc, i-1, j)) / dx
+				} else {
+					du[p] -= v * (l.at(t, u, bc, i+1, j) - u[p]) / dx
+				}
+			}
+			if vy != nil && g.Is2D() {
+				if v := vy[p]; v > 0 {
+					du[p] -= v * (u[p] - l.at(t, u, bc, i, j-1)) / dy
+				} else {
+					du[p] -= v * (l.at(t, u, bc, i, j+1) - u[p]) / dy
+				}
+			}
+		}
+	}
+}
+
+// Gradient computes the gradient (∂u/∂x,∂u/∂y) of the field k at the points
+// of the grid with the centered scheme (second order), and writes it in the
+// slices Gx and Gy (a nil slice is not computed). Contrary to the other
+// operators, the result is not added to a rate, but it can be used to build
+// the nonlinear terms of a PDE, including the terms that couple the fields
+// (e.g. u.∂v/∂x), that are covered by the pattern of the layout (see Pattern).
+func (l Layout) Gradient(t float64, X []float64, k int, Gx, Gy []float64) {
+	g := l.Grid
+	u, bc := l.Field(X, k), l.Fields[k].BC
+	dx, dy := g.Dx(), g.Dy()
+	for j := 0; j < g.Ny; j++ {
+		for i := 0; i < g.Nx; i++ {
+			p := g.Index(i, j)
+			if Gx != nil {
+				Gx[p] = (l.at(t, u, bc, i+1, j) - l.at(t, u, bc, i-1, j)) / (2 * dx)
+			}
+			if Gy != nil && g.Is2D() {
+				Gy[p] = (l.at(t, u, bc, i, j+1) - l.at(t, u, bc, i, j-1)) / (2 * dy)
+			}
+		}
+	}
+}
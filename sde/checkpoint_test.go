@@ -0,0 +1,81 @@
+package sde
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// ornsteinUhlenbeck is the process dX = -X.dt + 0.5.dW with a state of size 2
+// (diagonal noise)
+var ornsteinUhlenbeck = Problem{
+	Drift: func(t float64, X []float64, dXdt []float64) error {
+		for i, x := range X {
+			dXdt[i] = -x
+		}
+		return nil
+	},
+	Diffusion: func(t float64, X []float64, G []float64) error {
+		for i := range G {
+			G[i] = 0.5
+		}
+		return nil
+	},
+	Noise:    DiagonalNoise,
+	Additive: true,
+}
+
+// TestCheckpointResume checks that a process resumed from a checkpoint, created
+// during the process or at its end, is driven by the same path of the Wiener
+// processes as the process solved in one run, with a fixed and an adaptive step
+// size.
+func TestCheckpointResume(t *testing.T) {
+	X0 := []float64{1, -1}
+	for _, opts := range [][]Option{{Seed(7)}, {Seed(7), Adaptive(1e-3, 1e-3)}} {
+		algo := NewSRA1Solver()
+		var reference solver.RecorderTimeSeries
+		if _, err := algo.Solve(ornsteinUhlenbeck, 0, X0, 0.01, solver.StopAtTime(2), &reference, opts...); err != nil {
+			t.Fatal(err)
+		}
+		tref, Xref := algo.Result()
+		check := func(name string, recorder solver.RecorderTimeSeries) {
+			tr, Xr := algo.Result()
+			if tr != tref || !reflect.DeepEqual(Xr, Xref) || len(recorder.Series) != len(reference.Series) {
+				t.Errorf("%s: the resumed process ends at (%g,%v) with %d records instead of (%g,%v) with %d records",
+					name, tr, Xr, len(recorder.Series), tref, Xref, len(reference.Series))
+			}
+		}
+
+		// Checkpoint created during the process, with the state of the
+		// controller
+		var cp *solver.Checkpoint
+		handler := func(c solver.Checkpoint) error {
+			if cp == nil {
+				cp = &c
+			}
+			return nil
+		}
+		var recorder solver.RecorderTimeSeries
+		options := append(opts, CheckpointEvery(10, handler))
+		if _, err := algo.Solve(ornsteinUhlenbeck, 0, X0, 0.01, solver.StopAtTime(2), &recorder, options...); err != nil {
+			t.Fatal(err)
+		}
+		options = append(opts, ResumeFrom(*cp))
+		if _, err := algo.Solve(ornsteinUhlenbeck, 0, nil, 0, solver.StopAtTime(2), &recorder, options...); err != nil {
+			t.Fatal(err)
+		}
+		check("CheckpointEvery", recorder)
+
+		// Checkpoint of the end of a process
+		recorder = solver.RecorderTimeSeries{}
+		if _, err := algo.Solve(ornsteinUhlenbeck, 0, X0, 0.01, solver.StopAtTime(1), &recorder, opts...); err != nil {
+			t.Fatal(err)
+		}
+		options = append(opts, ResumeFrom(algo.Checkpoint()))
+		if _, err := algo.Solve(ornsteinUhlenbeck, 0, nil, 0, solver.StopAtTime(2), &recorder, options...); err != nil {
+			t.Fatal(err)
+		}
+		check("Checkpoint", recorder)
+	}
+}
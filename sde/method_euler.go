@@ -0,0 +1,36 @@
+package sde
+
+import "github.com/gboulant/dingo-ode/internal/core"
+
+// emWorkspace holds the vectors used by the Euler-Maruyama iteration
+type emWorkspace struct {
+	f, g []float64
+}
+
+// iteration implements the Iteration of the Euler-Maruyama method:
+//
+//	Xs = Xn + f(tn,Xn).h + g(tn,Xn).dW
+func (w *emWorkspace) iteration(p *Problem, tn float64, Xn []float64, h float64, dW, dZ []float64, Xs []float64) error {
+	n := len(Xn)
+	w.f = core.Resize(w.f, n)
+	w.g = core.Resize(w.g, p.diffusionSize(n))
+	if err := p.Drift(tn, Xn, w.f); err != nil {
+		return err
+	}
+	if err := p.Diffusion(tn, Xn, w.g); err != nil {
+		return err
+	}
+	for i := range Xs {
+		Xs[i] = Xn[i] + h*w.f[i]
+	}
+	p.apply(w.g, dW, 1, Xs)
+	return nil
+}
+
+// NewEulerMaruyamaSolver returns a Solver that implements the Euler-Maruyama
+// method. The method is of strong order 0.5 (1 for an additive noise) and
+// supports all the noises.
+func NewEulerMaruyamaSolver() Solver {
+	w := emWorkspace{}
+	return &StandardSolver{method: "euler-maruyama", order: 0.5, iteration: w.iteration}
+}
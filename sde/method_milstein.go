@@ -0,0 +1,62 @@
+package sde
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// milsteinWorkspace holds the vectors used by the Milstein iteration
+type milsteinWorkspace struct {
+	f, g, gs, ys []float64
+}
+
+// iteration implements the Iteration of the Milstein method, in its
+// derivative-free form (Kloeden and Platen), where the term g.∂g/∂X is
+// approximated with the supporting value Ys = Xn + f.h + g.sqrt(h):
+//
+//	Xs = Xn + f.h + g.dW + (g(Ys) - g(Xn))/(2.sqrt(h)).(dW^2 - h)
+//
+// For a diagonal noise, the component g_i should depend only on X_i (the
+// cross terms of the general Milstein method are neglected).
+func (w *milsteinWorkspace) iteration(p *Problem, tn float64, Xn []float64, h float64, dW, dZ []float64, Xs []float64) error {
+	if p.Noise == GeneralNoise {
+		err := errors.New("the Milstein method supports only the scalar and diagonal noises")
+		return core.NewError(solver.ErrInvalidInput, err, tn, Xn, h)
+	}
+	n := len(Xn)
+	w.f = core.Resize(w.f, n)
+	w.g = core.Resize(w.g, n)
+	w.gs = core.Resize(w.gs, n)
+	w.ys = core.Resize(w.ys, n)
+	if err := p.Drift(tn, Xn, w.f); err != nil {
+		return err
+	}
+	if err := p.Diffusion(tn, Xn, w.g); err != nil {
+		return err
+	}
+	sh := math.Sqrt(h)
+	for i := range w.ys {
+		w.ys[i] = Xn[i] + h*w.f[i] + sh*w.g[i]
+	}
+	if err := p.Diffusion(tn, w.ys, w.gs); err != nil {
+		return err
+	}
+	for i := range Xs {
+		dWi := dW[0]
+		if p.Noise == DiagonalNoise {
+			dWi = dW[i]
+		}
+		Xs[i] = Xn[i] + h*w.f[i] + w.g[i]*dWi + (w.gs[i]-w.g[i])/(2*sh)*(dWi*dWi-h)
+	}
+	return nil
+}
+
+// NewMilsteinSolver returns a Solver that implements the Milstein method. The
+// method is of strong order 1, and supports the scalar and diagonal noises.
+func NewMilsteinSolver() Solver {
+	w := milsteinWorkspace{}
+	return &StandardSolver{method: "milstein", order: 1, iteration: w.iteration}
+}
@@ -0,0 +1,69 @@
+package sde
+
+import (
+	"errors"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// sraWorkspace holds the vectors used by the SRA1 iteration
+type sraWorkspace struct {
+	f1, f2, g0, g1, h2 []float64
+}
+
+// iteration implements the Iteration of the SRA1 method of Rößler, a
+// stochastic Runge-Kutta method for the additive noises, with 2 stages:
+//
+//	H1 = Xn
+//	H2 = Xn + 3/4.f(tn,H1).h + 3/2.g(tn+h).dZ/h
+//	Xs = Xn + (1/3.f(tn,H1) + 2/3.f(tn+3h/4,H2)).h
+//	        + g(tn+h).dW + (g(tn) - g(tn+h)).dZ/h
+//
+// where dZ is the integral of W-W(tn) over the step (see Wiener). The times of
+// the diffusion are given by the coefficients c(1)=(1,0) of the method: the
+// diffusion of the first stage, used in H2, is evaluated at tn+h.
+func (w *sraWorkspace) iteration(p *Problem, tn float64, Xn []float64, h float64, dW, dZ []float64, Xs []float64) error {
+	if !p.Additive {
+		err := errors.New("the SRA methods support only the additive noises")
+		return core.NewError(solver.ErrInvalidInput, err, tn, Xn, h)
+	}
+	n := len(Xn)
+	w.f1 = core.Resize(w.f1, n)
+	w.f2 = core.Resize(w.f2, n)
+	w.g0 = core.Resize(w.g0, p.diffusionSize(n))
+	w.g1 = core.Resize(w.g1, p.diffusionSize(n))
+	w.h2 = core.Resize(w.h2, n)
+	if err := p.Drift(tn, Xn, w.f1); err != nil {
+		return err
+	}
+	if err := p.Diffusion(tn, Xn, w.g0); err != nil {
+		return err
+	}
+	if err := p.Diffusion(tn+h, Xn, w.g1); err != nil {
+		return err
+	}
+	for i := range w.h2 {
+		w.h2[i] = Xn[i] + 0.75*h*w.f1[i]
+	}
+	p.apply(w.g1, dZ, 1.5/h, w.h2)
+	if err := p.Drift(tn+0.75*h, w.h2, w.f2); err != nil {
+		return err
+	}
+	for i := range Xs {
+		Xs[i] = Xn[i] + h*(w.f1[i]/3+2*w.f2[i]/3)
+	}
+	p.apply(w.g1, dW, 1, Xs)
+	p.apply(w.g0, dZ, 1/h, Xs)
+	p.apply(w.g1, dZ, -1/h, Xs)
+	return nil
+}
+
+// NewSRA1Solver returns a Solver that implements the SRA1 stochastic
+// Runge-Kutta method of Rößler. The method is of strong order 1.5 for the
+// additive noises (see Problem.Additive), and supports all the additive
+// noises.
+func NewSRA1Solver() Solver {
+	w := sraWorkspace{}
+	return &StandardSolver{method: "sra1", order: 1.5, iteration: w.iteration}
+}
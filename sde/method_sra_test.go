@@ -0,0 +1,90 @@
+package sde
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gboulant/dingo-ode/internal/random"
+)
+
+// timeNoise is the process dX = -sin(X).dt + (1+t)/2.dW, whose additive
+// diffusion depends on the time
+var timeNoise = Problem{
+	Drift: func(t float64, X []float64, dXdt []float64) error {
+		dXdt[0] = -math.Sin(X[0])
+		return nil
+	},
+	Diffusion: func(t float64, X []float64, G []float64) error {
+		G[0] = (1 + t) / 2
+		return nil
+	},
+	Noise:    ScalarNoise,
+	Additive: true,
+}
+
+// TestSRA1Stages checks the iteration of the SRA1 method against the formula
+// of the method, whose second stage uses the diffusion at the end of the step.
+func TestSRA1Stages(t *testing.T) {
+	tn, Xn, h := 1.0, []float64{1}, 0.1
+	dW, dZ := []float64{0.2}, []float64{0.01}
+	w := sraWorkspace{}
+	Xs := make([]float64, 1)
+	if err := w.iteration(&timeNoise, tn, Xn, h, dW, dZ, Xs); err != nil {
+		t.Fatal(err)
+	}
+	f := func(x float64) float64 { return -math.Sin(x) }
+	g := func(t float64) float64 { return (1 + t) / 2 }
+	H2 := Xn[0] + 0.75*h*f(Xn[0]) + 1.5*g(tn+h)*dZ[0]/h
+	expected := Xn[0] + h*(f(Xn[0])/3+2*f(H2)/3) + g(tn+h)*dW[0] + (g(tn)-g(tn+h))*dZ[0]/h
+	if math.Abs(Xs[0]-expected) > 1e-14 {
+		t.Errorf("the SRA1 iteration gives %.15g instead of %.15g", Xs[0], expected)
+	}
+}
+
+// TestSRA1Convergence checks the strong order 1.5 of the SRA1 method. The
+// solutions with the step sizes h=1/4 to h=1/32 are compared on the same paths
+// of the Wiener process to a reference solution computed with h=1/1024.
+func TestSRA1Convergence(t *testing.T) {
+	const paths, fine = 200, 1024
+	steps := []int{4, 8, 16, 32}
+	errs := make([]float64, len(steps))
+	w := sraWorkspace{}
+	wiener := newWiener(1, random.New(11))
+	dW, dZ := make([]float64, fine), make([]float64, fine)
+
+	// solve integrates the process on [0,1] with n steps, whose increments
+	// are combined from the increments of the fine steps
+	solve := func(n int) float64 {
+		h, k := 1.0/float64(n), fine/n
+		X, Xs := []float64{1}, []float64{0}
+		for i := 0; i < n; i++ {
+			W, Z := 0.0, 0.0
+			for j := i * k; j < (i+1)*k; j++ {
+				Z += dZ[j] + W/fine
+				W += dW[j]
+			}
+			if err := w.iteration(&timeNoise, float64(i)*h, X, h, []float64{W}, []float64{Z}, Xs); err != nil {
+				t.Fatal(err)
+			}
+			X, Xs = Xs, X
+		}
+		return X[0]
+	}
+	for p := 0; p < paths; p++ {
+		for j := range dW {
+			W, Z := wiener.Increment(1.0 / fine)
+			dW[j], dZ[j] = W[0], Z[0]
+		}
+		reference := solve(fine)
+		for i, n := range steps {
+			errs[i] += math.Abs(solve(n)-reference) / paths
+		}
+	}
+	for i := 1; i < len(steps); i++ {
+		order := math.Log2(errs[i-1] / errs[i])
+		if order < 1.3 {
+			t.Errorf("the observed order is %.2f between h=1/%d and h=1/%d (errors %g and %g)",
+				order, steps[i-1], steps[i], errs[i-1], errs[i])
+		}
+	}
+}
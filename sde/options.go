@@ -0,0 +1,84 @@
+package sde
+
+import "github.com/gboulant/dingo-ode/solver"
+
+// settings gathers the parameters of a solving process. The parameters are
+// initialized with default values and then modified by the Option functions.
+type settings struct {
+	seed               int64
+	adaptive           bool
+	atol, rtol         float64
+	checkInterval      uint64
+	resume             *solver.Checkpoint
+	checkpointInterval uint64
+	checkpointHandler  func(cp solver.Checkpoint) error
+}
+
+// defaultSeed is the default seed of the Wiener processes
+const defaultSeed = 1
+
+// Option defines a function that modifies the settings of a solving process
+type Option func(s *settings)
+
+// newSettings returns the settings defined by the default values modified by
+// the given options.
+func newSettings(opts ...Option) settings {
+	s := settings{seed: defaultSeed, checkInterval: solver.DefaultCheckInterval}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// Seed specifies the seed of the random generator of the Wiener processes.
+// Two solving processes with the same seed (and the same step sizes) are
+// driven by the same path of the Wiener processes. The default seed is 1.
+func Seed(seed int64) Option {
+	return func(s *settings) {
+		s.seed = seed
+	}
+}
+
+// Adaptive specifies that the step size is adapted to keep the local error
+// below the tolerance atol + rtol*|X|. The local error is estimated by the
+// comparison of a full step with two half steps, and the rejected steps are
+// retried on the same path of the Wiener processes (Brownian bridge).
+func Adaptive(atol, rtol float64) Option {
+	return func(s *settings) {
+		s.adaptive = true
+		s.atol, s.rtol = atol, rtol
+	}
+}
+
+// CheckEvery specifies the number of iterations between two checks of the context
+// of a solving process (see solver.CheckEvery). The default value is
+// solver.DefaultCheckInterval, and a value of 0 is considered as 1.
+func CheckEvery(n uint64) Option {
+	return func(s *settings) {
+		if n == 0 {
+			n = 1
+		}
+		s.checkInterval = n
+	}
+}
+
+// ResumeFrom specifies that the solving process should be resumed from the
+// given checkpoint (see solver.ResumeFrom). The initial conditions, the step
+// size and the state of the random generator are replaced by the values of
+// the checkpoint, so that the resumed process is driven by the same path of
+// the Wiener processes as the original one (the option Seed is then ignored).
+func ResumeFrom(cp solver.Checkpoint) Option {
+	return func(s *settings) {
+		s.resume = &cp
+	}
+}
+
+// CheckpointEvery specifies that a checkpoint of the solving process should be
+// created every n iterations and given to the handler function (see
+// solver.CheckpointEvery).
+func CheckpointEvery(n uint64, handler func(cp solver.Checkpoint) error) Option {
+	return func(s *settings) {
+		s.checkpointInterval = n
+		s.checkpointHandler = handler
+	}
+}
@@ -0,0 +1,426 @@
+// Package sde implements the solvers of the stochastic differential equations
+// (SDE) in the Itô form:
+//
+//	dX = f(t,X).dt + g(t,X).dW
+//
+// where f is the drift, g the diffusion and W a vector of independent Wiener
+// processes. The solvers follow the conventions of the package solver: the
+// solving process is driven by a solver.Controller and the states are
+// recorded by a solver.Recorder.
+package sde
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// Noise specifies the structure of the diffusion term g(t,X).dW
+type Noise int
+
+const (
+	// ScalarNoise is a noise driven by a single Wiener process: the diffusion
+	// g has n values (the noise of each component of X).
+	ScalarNoise Noise = iota
+	// DiagonalNoise is a noise driven by n independent Wiener processes, one
+	// for each component of X: the diffusion g has n values.
+	DiagonalNoise
+	// GeneralNoise is a noise driven by m independent Wiener processes: the
+	// diffusion g is a n x m matrix stored in n*m values (row-major).
+	GeneralNoise
+)
+
+// DiffusionFunction defines the function that computes the diffusion g(t,X)
+// of an SDE, written in the slice G whose size depends on the Noise.
+type DiffusionFunction func(t float64, X []float64, G []float64) error
+
+// Problem defines an SDE dX = f(t,X).dt + g(t,X).dW
+type Problem struct {
+	Drift     solver.InPlaceFunction // the drift f(t,X)
+	Diffusion DiffusionFunction      // the diffusion g(t,X)
+	Noise     Noise                  // the structure of the noise
+	Wieners   int                    // number of Wiener processes (GeneralNoise only)
+	// Additive specifies that the diffusion does not depend on X (g=g(t)),
+	// which is required by the SRA methods.
+	Additive bool
+}
+
+// wieners returns the number of Wiener processes for a state of size n
+func (p *Problem) wieners(n int) int {
+	switch p.Noise {
+	case ScalarNoise:
+		return 1
+	case DiagonalNoise:
+		return n
+	default:
+		return p.Wieners
+	}
+}
+
+// diffusionSize returns the number of values of the diffusion g
+func (p *Problem) diffusionSize(n int) int {
+	if p.Noise == GeneralNoise {
+		return n * p.Wieners
+	}
+	return n
+}
+
+// apply adds coef*g.dW to Y
+func (p *Problem) apply(G, dW []float64, coef float64, Y []float64) {
+	switch p.Noise {
+	case ScalarNoise:
+		for i := range Y {
+			Y[i] += coef * G[i] * dW[0]
+		}
+	case DiagonalNoise:
+		for i := range Y {
+			Y[i] += coef * G[i] * dW[i]
+		}
+	default:
+		m := p.Wieners
+		for i := range Y {
+			s := 0.0
+			for j := 0; j < m; j++ {
+				s += G[i*m+j] * dW[j]
+			}
+			Y[i] += coef * s
+		}
+	}
+}
+
+// Iteration defines a function that implements a step of an SDE method: the
+// state at time tn+h is computed in Xs from the state Xn at time tn, with the
+// increments dW and dZ of the Wiener processes over the step (see Wiener).
+type Iteration func(p *Problem, tn float64, Xn []float64, h float64, dW, dZ []float64, Xs []float64) error
+
+// Solver is the interface implemented by the SDE solvers
+type Solver interface {
+	// Solve solves the SDE p from the initial conditions (t0,X0) with a step
+	// size h > 0 (the initial step size of an adaptive process), stopping the
+	// process when the controller c requests it, and recording the states
+	// with the recorder r. The increments of the Wiener processes are
+	// generated from a seed (see the option Seed), so that a solving process
+	// is reproducible.
+	Solve(p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error)
+	// SolveContext is the same as Solve, but the solving process is aborted
+	// when the context is canceled or when its deadline is exceeded.
+	SolveContext(ctx context.Context, p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error)
+	// Result returns the values of t and X obtained at the end of the solving
+	// process. The vector X is a copy that can be freely kept by the caller.
+	Result() (t float64, X []float64)
+	// Wiener returns the values W(t) of the Wiener processes at the end of
+	// the solving process (with W(t0)=0).
+	Wiener() []float64
+	// Stats returns the statistics of the last solving process
+	Stats() solver.Stats
+	// Checkpoint returns a checkpoint of the state reached at the end of the
+	// last solving process (see Result), with the state of the Wiener
+	// processes, that can be used to resume the process later on (see the
+	// option ResumeFrom).
+	Checkpoint() solver.Checkpoint
+}
+
+// StandardSolver implements the interface Solver with a fixed step or an
+// adaptive step solving process, that applies an Iteration function (to be
+// defined) at each step.
+type StandardSolver struct {
+	method     string  // name of the method, for the checkpoints
+	order      float64 // strong order of the method, for the adaptive step size
+	iteration  Iteration
+	t          float64
+	X, W       []float64
+	h          float64
+	iterations uint64
+	records    uint64
+	wiener     *Wiener // generator of the Wiener processes at the end of the process
+	stats      solver.Stats
+	xm, xn     []float64 // states at the beginning and the end of a step
+	xh, xf     []float64 // states of the half steps and of the full step (adaptive)
+	wm, wn     []float64 // values of the Wiener processes at the beginning and the end of a step
+	dW, dZ     []float64 // increments of the step (of the first half step if adaptive)
+	dW2, dZ2   []float64 // increments of the second half step (adaptive)
+	dWf, dZf   []float64 // increments of the full step (adaptive)
+}
+
+// Solve implements the Solver interface
+func (s *StandardSolver) Solve(p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	return s.SolveContext(context.Background(), p, t0, X0, h, c, r, opts...)
+}
+
+// SolveContext implements the Solver interface
+func (s *StandardSolver) SolveContext(ctx context.Context, p Problem, t0 float64, X0 []float64, h float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	if p.Drift == nil || p.Diffusion == nil {
+		err := errors.New("the drift and the diffusion of the SDE should be defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	if p.Noise == GeneralNoise && p.Wieners <= 0 {
+		err := errors.New("the number of Wiener processes should be positive")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	if c == nil {
+		err := errors.New("the controller c is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	if r == nil {
+		r = &solver.RecorderNone{}
+	}
+	config := newSettings(opts...)
+	if config.checkpointInterval > 0 && config.checkpointHandler == nil {
+		err := errors.New("the checkpoint handler is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+	}
+	cp := config.resume
+	if cp != nil {
+		if cp.Method != s.method {
+			err := fmt.Errorf("the checkpoint was created with the method %s instead of %s", cp.Method, s.method)
+			return 0, core.NewError(solver.ErrInvalidInput, err, cp.T, cp.X, cp.H)
+		}
+		t0, X0, h = cp.T, cp.X, cp.H
+	}
+	if !(h > 0) {
+		err := fmt.Errorf("the step size h (%g) should be positive", h)
+		return 0, core.NewError(solver.ErrStepSizeTooSmall, err, t0, X0, h)
+	}
+
+	start := time.Now()
+	s.stats = solver.Stats{}
+	p.Drift = core.Instrument(&s.stats, p.Drift)
+	p.Diffusion = core.Instrument(&s.stats, p.Diffusion)
+
+	n, m := len(X0), p.wieners(len(X0))
+	for _, v := range []*[]float64{&s.xm, &s.xn, &s.xh, &s.xf} {
+		*v = core.Resize(*v, n)
+	}
+	for _, v := range []*[]float64{&s.wm, &s.wn, &s.dW, &s.dZ, &s.dW2, &s.dZ2, &s.dWf, &s.dZf} {
+		*v = core.Resize(*v, m)
+	}
+
+	var iterations, records uint64 = 0, 0
+	tm, Xm, Xn, Wm, Wn := t0, s.xm, s.xn, s.wm, s.wn
+	copy(Xm, X0)
+	for i := range Wm {
+		Wm[i] = 0
+	}
+	wiener := NewWiener(m, config.seed)
+	if cp != nil {
+		// The history holds the values of the Wiener processes, followed by
+		// the pending increments (see Wiener.state).
+		if len(cp.History) == 0 || len(cp.History[0]) != m {
+			err := errors.New("the checkpoint has no values of the Wiener processes")
+			return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, h)
+		}
+		var err error
+		wiener, err = restoreWiener(m, cp.Seed, cp.Draws, cp.History[1:])
+		if err != nil {
+			return 0, err
+		}
+		copy(Wm, cp.History[0])
+		iterations, records = cp.Iterations, cp.Records
+		if seeker, ok := r.(solver.RecorderSeeker); ok {
+			if err := seeker.Seek(records); err != nil {
+				return 0, err
+			}
+		}
+	}
+	defer func() {
+		s.t = tm
+		s.X = core.Resize(s.X, n)
+		copy(s.X, Xm)
+		s.W = core.Resize(s.W, m)
+		copy(s.W, Wm)
+		s.h = h
+		s.iterations, s.records = iterations, records
+		s.wiener = wiener
+		s.stats.WallTime = time.Since(start)
+	}()
+
+	// The Process of a checkpoint created during the solving process has
+	// already requested the controller at the checkpoint state.
+	process := solver.NewProcess(t0, h)
+	if cp != nil && cp.Process != nil {
+		process = solver.RestoreProcess(*cp.Process, h)
+	} else {
+		if cp == nil {
+			r.Record(tm, Xm)
+			records++
+		}
+		if stop, err := process.Request(c, tm, Xm); stop || err != nil {
+			if err != nil {
+				err = core.NewError(solver.ErrFunction, err, tm, Xm, h)
+			}
+			return iterations, err
+		}
+	}
+	for {
+		if iterations%config.checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return iterations, core.NewError(solver.ErrInterrupted, err, tm, Xm, h)
+			}
+		}
+		var hs float64
+		var err error
+		if config.adaptive {
+			hs, h, err = s.adaptiveStep(&p, wiener, config, tm, Xm, h, Xn, Wm, Wn)
+		} else {
+			hs = h
+			dW, dZ := wiener.Increment(h)
+			copy(s.dW, dW)
+			copy(s.dZ, dZ)
+			err = s.iteration(&p, tm, Xm, h, s.dW, s.dZ, Xn)
+			for i := range Wn {
+				Wn[i] = Wm[i] + s.dW[i]
+			}
+		}
+		if err != nil {
+			return iterations, core.NewError(solver.ErrFunction, err, tm, Xm, hs)
+		}
+		core.Accept(&s.stats, hs)
+		tn := tm + hs
+
+		r.Record(tn, Xn)
+		records++
+		stop, err := process.Request(c, tn, Xn)
+		if err != nil {
+			return iterations, core.NewError(solver.ErrFunction, err, tn, Xn, hs)
+		}
+		if stop {
+			// The step is not part of the result: its increments are put
+			// back, so that a process resumed from the final state (see
+			// Checkpoint) is driven by the same path.
+			if config.adaptive {
+				wiener.PushBack(hs/2, s.dW2, s.dZ2)
+				wiener.PushBack(hs/2, s.dW, s.dZ)
+			} else {
+				wiener.PushBack(hs, s.dW, s.dZ)
+			}
+			h = hs
+			records--
+			return iterations, nil
+		}
+		tm, Xm, Xn, Wm, Wn = tn, Xn, Xm, Wn, Wm
+		iterations++
+
+		if config.checkpointInterval > 0 && iterations%config.checkpointInterval == 0 {
+			cp := s.checkpoint(tm, Xm, Wm, h, iterations, records, wiener)
+			state := process.Save()
+			cp.Process = &state
+			if err := config.checkpointHandler(cp); err != nil {
+				return iterations, core.NewError(solver.ErrFunction, err, tm, Xm, h)
+			}
+		}
+	}
+}
+
+// adaptiveStep computes a step from (tm,Xm) with the step size h, controlled
+// by the comparison of a full step with two half steps on the same path of
+// the Wiener processes. The step is retried with a smaller step size while the
+// error exceeds the tolerance, the increments of the rejected steps being put
+// back to the Wiener generator, so that the path is refined with the Brownian
+// bridge. The function returns the size of the accepted step and the size
+// proposed for the next step. The state at the end of the step (the result of
+// the two half steps) is written in Xn and the Wiener processes in Wn.
+func (s *StandardSolver) adaptiveStep(p *Problem, wiener *Wiener, config settings, tm float64, Xm []float64, h float64, Xn, Wm, Wn []float64) (float64, float64, error) {
+	exponent := -1 / (s.order + 0.5)
+	for {
+		if h < 1e-12*math.Max(1, math.Abs(tm)) {
+			err := fmt.Errorf("the step size %g is too small", h)
+			return h, h, core.NewError(solver.ErrStepSizeTooSmall, err, tm, Xm, h)
+		}
+		dW1, dZ1 := wiener.Increment(h / 2)
+		copy(s.dW, dW1)
+		copy(s.dZ, dZ1)
+		dW2, dZ2 := wiener.Increment(h / 2)
+		copy(s.dW2, dW2)
+		copy(s.dZ2, dZ2)
+
+		// Two half steps
+		if err := s.iteration(p, tm, Xm, h/2, s.dW, s.dZ, s.xh); err != nil {
+			return h, h, err
+		}
+		if err := s.iteration(p, tm+h/2, s.xh, h/2, s.dW2, s.dZ2, Xn); err != nil {
+			return h, h, err
+		}
+
+		// One full step with the combined increments
+		for i := range s.dW {
+			s.dZf[i] = s.dZ[i] + s.dZ2[i] + s.dW[i]*h/2
+			s.dWf[i] = s.dW[i] + s.dW2[i]
+		}
+		if err := s.iteration(p, tm, Xm, h, s.dWf, s.dZf, s.xf); err != nil {
+			return h, h, err
+		}
+
+		e := 0.0
+		for i := range Xn {
+			scale := config.atol + config.rtol*math.Max(math.Abs(Xm[i]), math.Abs(Xn[i]))
+			e = math.Max(e, math.Abs(Xn[i]-s.xf[i])/scale)
+		}
+		factor := 1.5
+		if e > 0 {
+			factor = math.Min(1.5, math.Max(0.2, 0.9*math.Pow(e, exponent)))
+		}
+		if e <= 1 {
+			for i := range Wn {
+				Wn[i] = Wm[i] + s.dWf[i]
+			}
+			return h, h * factor, nil
+		}
+
+		// The step is rejected: the increments of both halves are put back
+		// (the first half is the next one)
+		s.stats.RejectedSteps++
+		wiener.PushBack(h/2, s.dW2, s.dZ2)
+		wiener.PushBack(h/2, s.dW, s.dZ)
+		h *= math.Min(factor, 0.9)
+	}
+}
+
+// Result implements the Solver interface
+func (s *StandardSolver) Result() (t float64, X []float64) {
+	X = make([]float64, len(s.X))
+	copy(X, s.X)
+	return s.t, X
+}
+
+// Wiener implements the Solver interface
+func (s *StandardSolver) Wiener() []float64 {
+	W := make([]float64, len(s.W))
+	copy(W, s.W)
+	return W
+}
+
+// Stats implements the Solver interface
+func (s *StandardSolver) Stats() solver.Stats {
+	return s.stats
+}
+
+// Checkpoint implements the Solver interface
+func (s *StandardSolver) Checkpoint() solver.Checkpoint {
+	return s.checkpoint(s.t, s.X, s.W, s.h, s.iterations, s.records, s.wiener)
+}
+
+// checkpoint creates a checkpoint with a copy of the given state and of the
+// state of the Wiener processes
+func (s *StandardSolver) checkpoint(t float64, X, W []float64, h float64, iterations, records uint64, wiener *Wiener) solver.Checkpoint {
+	cp := solver.Checkpoint{
+		Version:    solver.CheckpointVersion,
+		Method:     s.method,
+		T:          t,
+		X:          append([]float64(nil), X...),
+		H:          h,
+		Iterations: iterations,
+		Records:    records,
+		History:    [][]float64{append([]float64(nil), W...)},
+	}
+	if wiener != nil {
+		var pending [][]float64
+		cp.Seed, cp.Draws, pending = wiener.state()
+		cp.History = append(cp.History, pending...)
+	}
+	return cp
+}
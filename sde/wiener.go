@@ -0,0 +1,179 @@
+package sde
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/internal/random"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// increment is the increment of the Wiener processes over a time interval of
+// length h: dW = W(t+h)-W(t), and dZ = ∫(W(s)-W(t))ds over [t,t+h], i.e. the
+// multiple stochastic integral I(1,0) used by the methods of strong order 1.5.
+type increment struct {
+	h      float64
+	dW, dZ []float64
+}
+
+// Wiener generates the increments of m independent Wiener processes from a
+// seeded random generator, so that the paths are reproducible. The increments
+// that are not used (e.g. the increment of a rejected step) can be put back
+// with the function PushBack: a following request for a shorter interval is
+// then sampled from the Brownian bridge, i.e. conditionally to the increment
+// put back, so that the path of the processes is not modified by the
+// rejection of a step.
+type Wiener struct {
+	src     *random.Source
+	rng     *rand.Rand
+	m       int
+	pending []increment // increments of the future intervals (the next one is the last)
+	dW, dZ  []float64   // workspace of the returned increments
+}
+
+// NewWiener creates the generator of m Wiener processes with the given seed
+func NewWiener(m int, seed int64) *Wiener {
+	return newWiener(m, random.New(seed))
+}
+
+// newWiener creates the generator of m Wiener processes that draws its random
+// numbers from the source src
+func newWiener(m int, src *random.Source) *Wiener {
+	return &Wiener{
+		src: src,
+		rng: rand.New(src),
+		m:   m,
+		dW:  make([]float64, m),
+		dZ:  make([]float64, m),
+	}
+}
+
+// restoreWiener creates the generator of m Wiener processes from the state
+// given by the function state.
+func restoreWiener(m int, seed int64, draws uint64, pending [][]float64) (*Wiener, error) {
+	w := newWiener(m, random.Restore(seed, draws))
+	for _, values := range pending {
+		if len(values) != 1+2*m {
+			err := errors.New("the pending increments of the Wiener processes are not consistent")
+			return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+		}
+		w.PushBack(values[0], values[1:1+m], values[1+m:])
+	}
+	return w, nil
+}
+
+// state returns the state of the generator: the seed and the number of values
+// drawn from the random source, and the pending increments (in the order of
+// PushBack), each one written as [h, dW..., dZ...].
+func (w *Wiener) state() (seed int64, draws uint64, pending [][]float64) {
+	seed, draws = w.src.State()
+	for _, inc := range w.pending {
+		values := append([]float64{inc.h}, inc.dW...)
+		pending = append(pending, append(values, inc.dZ...))
+	}
+	return seed, draws, pending
+}
+
+// Increment returns the increments dW and dZ of the processes over the next
+// interval of length h > 0. The slices are owned by the Wiener and are valid
+// until the next call.
+func (w *Wiener) Increment(h float64) (dW, dZ []float64) {
+	for i := range w.dW {
+		w.dW[i], w.dZ[i] = 0, 0
+	}
+	done := 0.0 // length of the interval already covered
+	for remaining := h; remaining > 1e-12*h; remaining = h - done {
+		if len(w.pending) == 0 {
+			w.sample(remaining)
+			break
+		}
+		next := &w.pending[len(w.pending)-1]
+		if next.h-remaining > 1e-12*h {
+			w.split(next, remaining)
+			next = &w.pending[len(w.pending)-1]
+		}
+		w.combineWith(next)
+		done += next.h
+		w.pending = w.pending[:len(w.pending)-1]
+	}
+	return w.dW, w.dZ
+}
+
+// PushBack puts back the increments dW and dZ over an interval of length h,
+// that become the next increments.
+func (w *Wiener) PushBack(h float64, dW, dZ []float64) {
+	inc := increment{h: h, dW: make([]float64, w.m), dZ: make([]float64, w.m)}
+	copy(inc.dW, dW)
+	copy(inc.dZ, dZ)
+	w.pending = append(w.pending, inc)
+}
+
+// sample adds to the returned increments a new increment over an interval of
+// length h, sampled from the joint law of (dW,dZ): dW ~ N(0,h), dZ ~
+// N(0,h^3/3) and Cov(dW,dZ) = h^2/2.
+func (w *Wiener) sample(h float64) {
+	sh := math.Sqrt(h)
+	for i := 0; i < w.m; i++ {
+		u1, u2 := w.rng.NormFloat64(), w.rng.NormFloat64()
+		w.dZ[i] += h*sh/2*(u1+u2/math.Sqrt(3)) + w.dW[i]*h
+		w.dW[i] += sh * u1
+	}
+}
+
+// combineWith adds the increment inc to the returned increments: the
+// integral of the second interval includes the increment of W over the first
+// one (W(s)-W(t) = (W(s)-W(t1)) + dW1).
+func (w *Wiener) combineWith(inc *increment) {
+	for i := range w.dW {
+		w.dZ[i] += inc.dZ[i] + w.dW[i]*inc.h
+		w.dW[i] += inc.dW[i]
+	}
+}
+
+// split splits the increment inc (the next pending one) in two increments
+// over the intervals of length k1 and inc.h-k1, sampled from the Brownian
+// bridge: the first increment X1=(dW1,dZ1) is sampled conditionally to the
+// total increment c = A.X1 + X2, where X1 and X2 are independent of
+// covariance S(k) = [[k, k^2/2], [k^2/2, k^3/3]], and A = [[1,0],[k2,1]].
+func (w *Wiener) split(inc *increment, k1 float64) {
+	k2 := inc.h - k1
+	s := func(k float64) [3]float64 { return [3]float64{k, k * k / 2, k * k * k / 3} }
+	s1, s2 := s(k1), s(k2)
+
+	// B = S1.A^T = Cov(X1,c), C = A.S1.A^T + S2 = Cov(c)
+	b11, b12 := s1[0], k2*s1[0]+s1[1]
+	b21, b22 := s1[1], k2*s1[1]+s1[2]
+	c11 := s1[0] + s2[0]
+	c12 := k2*s1[0] + s1[1] + s2[1]
+	c22 := k2*k2*s1[0] + 2*k2*s1[1] + s1[2] + s2[2]
+	det := c11*c22 - c12*c12
+	i11, i12, i22 := c22/det, -c12/det, c11/det
+
+	// K = B.C^-1, mean = K.c, P = S1 - K.B^T (conditional covariance)
+	k11, k12 := b11*i11+b12*i12, b11*i12+b12*i22
+	k21, k22 := b21*i11+b22*i12, b21*i12+b22*i22
+	p11 := s1[0] - (k11*b11 + k12*b12)
+	p21 := s1[1] - (k21*b11 + k22*b12)
+	p22 := s1[2] - (k21*b21 + k22*b22)
+	l11 := math.Sqrt(math.Max(p11, 0))
+	l21 := 0.0
+	if l11 > 0 {
+		l21 = p21 / l11
+	}
+	l22 := math.Sqrt(math.Max(p22-l21*l21, 0))
+
+	first := increment{h: k1, dW: make([]float64, w.m), dZ: make([]float64, w.m)}
+	for i := 0; i < w.m; i++ {
+		cw, cz := inc.dW[i], inc.dZ[i]
+		u1, u2 := w.rng.NormFloat64(), w.rng.NormFloat64()
+		w1 := k11*cw + k12*cz + l11*u1
+		z1 := k21*cw + k22*cz + l21*u1 + l22*u2
+		first.dW[i], first.dZ[i] = w1, z1
+		inc.dW[i] = cw - w1
+		inc.dZ[i] = cz - k2*w1 - z1
+	}
+	inc.h = k2
+	w.pending = append(w.pending, first)
+}
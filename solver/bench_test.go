@@ -0,0 +1,47 @@
+package solver
+
+import "testing"
+
+// decay is a rate function of size 100 that does not allocate memory
+func decay(t float64, X []float64, dXdt []float64) error {
+	for i, x := range X {
+		dXdt[i] = -float64(i+1) * x
+	}
+	return nil
+}
+
+// benchmarkSolveInPlace solves the decay system with b.N iterations of the
+// solver, so that the reported allocations are the allocations per iteration
+// (the allocations of the solving process setup are divided by b.N).
+func benchmarkSolveInPlace(b *testing.B, solver Solver) {
+	X0 := make([]float64, 100)
+	for i := range X0 {
+		X0[i] = 1
+	}
+	controller := StopAtMaxIterations(uint64(b.N))
+	// The first solving process allocates the workspace of the solver
+	if _, err := solver.SolveInPlace(decay, 0, X0, 1e-3, StopAtMaxIterations(1), nil); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := solver.SolveInPlace(decay, 0, X0, 1e-3, controller, nil); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkEulerSolveInPlace(b *testing.B) {
+	benchmarkSolveInPlace(b, NewEulerSolver())
+}
+
+func BenchmarkRK2SolveInPlace(b *testing.B) {
+	benchmarkSolveInPlace(b, NewRK2Solver())
+}
+
+func BenchmarkRK4SolveInPlace(b *testing.B) {
+	benchmarkSolveInPlace(b, NewRK4Solver())
+}
+
+func BenchmarkSDIRK2SolveInPlace(b *testing.B) {
+	benchmarkSolveInPlace(b, NewSDIRK2Solver())
+}
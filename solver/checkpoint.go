@@ -0,0 +1,293 @@
+package solver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CheckpointVersion is the version of the checkpoint format. It should be
+// incremented when the format changes. The version history is:
+//
+//	1: initial format
+//	2: mode of the hybrid systems
+//	3: state of the process (controllers), of the random generator and
+//	   history of the solvers
+const CheckpointVersion = 3
+
+// checkpointMagic is the signature at the beginning of a binary checkpoint
+var checkpointMagic = [4]byte{'O', 'D', 'E', 'C'}
+
+// Checkpoint is a snapshot of the state of a solving process. A checkpoint can
+// be saved in a file (binary or JSON format) and used later to resume the
+// solving process (see the option ResumeFrom). For the deterministic methods,
+// the resumed process continues exactly (bitwise) as if it was never stopped.
+//
+// The checkpoints created during the solving process (see CheckpointEvery)
+// hold the state of the Process, i.e. the states of the controllers (see
+// Process.State) and the counters of iterations and wall clock time: the
+// resumed process then continues with the same controller as if it was never
+// stopped. The checkpoint of the end of a solving process (see the function
+// Checkpoint of the Solver) holds no Process state, as the process is resumed
+// with a new controller (e.g. a StopAtTime with a later time), that starts
+// from the state of the checkpoint as for a new solving process.
+//
+// The stochastic solvers (packages sde and ssa) also save the state of their
+// random generator (Seed and Draws) and the values of their method that can
+// not be computed from the state X (History), so that a resumed process draws
+// the same random numbers as the original one. The DDE solver and the
+// fractional solvers of the package fde, whose state includes the whole past
+// of the solution, can not be resumed from a checkpoint.
+type Checkpoint struct {
+	Version    int           `json:"version"`           // version of the checkpoint format
+	Method     string        `json:"method"`            // name of the solving method
+	T          float64       `json:"t"`                 // time of the state
+	X          []float64     `json:"x"`                 // state vector at time T
+	H          float64       `json:"h"`                 // step size
+	Iterations uint64        `json:"iterations"`        // number of iterations done to reach T
+	Records    uint64        `json:"records"`           // number of states recorded up to T (included)
+	Mode       int           `json:"mode"`              // active mode of a hybrid system (since version 2)
+	Process    *ProcessState `json:"process,omitempty"` // state of the process, if any (since version 3)
+	Seed       int64         `json:"seed,omitempty"`    // seed of the random generator (since version 3)
+	Draws      uint64        `json:"draws,omitempty"`   // number of values drawn from the random generator (since version 3)
+	History    [][]float64   `json:"history,omitempty"` // values specific to the method (since version 3)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface and checks the version
+// of the checkpoint.
+func (cp *Checkpoint) UnmarshalJSON(data []byte) error {
+	type checkpoint Checkpoint // avoid the recursive call of UnmarshalJSON
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	if c.Version < 1 || c.Version > CheckpointVersion {
+		return fmt.Errorf("ERR: the checkpoint version %d is not supported", c.Version)
+	}
+	*cp = Checkpoint(c)
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The float
+// values are stored with their exact bit representation.
+func (cp Checkpoint) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	values := []interface{}{
+		checkpointMagic,
+		uint32(CheckpointVersion),
+		uint32(len(cp.Method)),
+		[]byte(cp.Method),
+		math.Float64bits(cp.T),
+		math.Float64bits(cp.H),
+		cp.Iterations,
+		cp.Records,
+		int64(cp.Mode),
+		floatBits(cp.X),
+	}
+	if p := cp.Process; p != nil {
+		keys := make([]string, 0, len(p.States))
+		for key := range p.States {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // the same checkpoint gives the same data
+		values = append(values, uint8(1), math.Float64bits(p.T0), p.Iteration, int64(p.Elapsed), uint64(len(keys)))
+		for _, key := range keys {
+			values = append(values, uint32(len(key)), []byte(key), floatBits(p.States[key]))
+		}
+	} else {
+		values = append(values, uint8(0))
+	}
+	values = append(values, cp.Seed, cp.Draws, uint64(len(cp.History)))
+	for _, h := range cp.History {
+		values = append(values, floatBits(h))
+	}
+	for _, v := range values {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// floatBits returns the binary encoding of a vector: its size followed by the
+// bits of its values.
+func floatBits(X []float64) []uint64 {
+	bits := make([]uint64, len(X)+1)
+	bits[0] = uint64(len(X))
+	for i, x := range X {
+		bits[i+1] = math.Float64bits(x)
+	}
+	return bits
+}
+
+// errTruncated is the error of a binary checkpoint shorter than the sizes it
+// specifies
+var errTruncated = errors.New("ERR: the binary checkpoint is truncated")
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. The
+// sizes read from the data are checked against the size of the remaining
+// data before any allocation.
+func (cp *Checkpoint) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewReader(data)
+	read := func(v interface{}) error {
+		return binary.Read(buf, binary.LittleEndian, v)
+	}
+	readBytes := func() ([]byte, error) {
+		var size uint32
+		if err := read(&size); err != nil {
+			return nil, err
+		}
+		if uint64(size) > uint64(buf.Len()) {
+			return nil, errTruncated
+		}
+		b := make([]byte, size)
+		return b, read(b)
+	}
+	readFloats := func() ([]float64, error) {
+		var n uint64
+		if err := read(&n); err != nil {
+			return nil, err
+		}
+		if n > uint64(buf.Len())/8 {
+			return nil, errTruncated
+		}
+		bits := make([]uint64, n)
+		if err := read(bits); err != nil {
+			return nil, err
+		}
+		X := make([]float64, n)
+		for i := range bits {
+			X[i] = math.Float64frombits(bits[i])
+		}
+		return X, nil
+	}
+
+	var magic [4]byte
+	if err := read(&magic); err != nil || magic != checkpointMagic {
+		return errors.New("ERR: the data is not a binary checkpoint")
+	}
+	var version uint32
+	if err := read(&version); err != nil {
+		return err
+	}
+	if version < 1 || version > CheckpointVersion {
+		return fmt.Errorf("ERR: the checkpoint version %d is not supported", version)
+	}
+	method, err := readBytes()
+	if err != nil {
+		return err
+	}
+	var t, h uint64
+	var mode int64
+	c := Checkpoint{Version: int(version), Method: string(method)}
+	fields := []interface{}{&t, &h, &c.Iterations, &c.Records, &mode}
+	if version < 2 {
+		fields = []interface{}{&t, &h, &c.Iterations, &c.Records}
+	}
+	for _, v := range fields {
+		if err := read(v); err != nil {
+			return err
+		}
+	}
+	c.T = math.Float64frombits(t)
+	c.H = math.Float64frombits(h)
+	c.Mode = int(mode)
+	if c.X, err = readFloats(); err != nil {
+		return err
+	}
+	if version < 3 {
+		*cp = c
+		return nil
+	}
+
+	var flag uint8
+	if err := read(&flag); err != nil {
+		return err
+	}
+	if flag != 0 {
+		var t0 uint64
+		var elapsed int64
+		var n uint64
+		p := ProcessState{}
+		for _, v := range []interface{}{&t0, &p.Iteration, &elapsed, &n} {
+			if err := read(v); err != nil {
+				return err
+			}
+		}
+		if n > uint64(buf.Len())/12 { // a state takes 12 bytes at least
+			return errTruncated
+		}
+		p.T0 = math.Float64frombits(t0)
+		p.Elapsed = time.Duration(elapsed)
+		p.States = make(map[string][]float64, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := readBytes()
+			if err != nil {
+				return err
+			}
+			if p.States[string(key)], err = readFloats(); err != nil {
+				return err
+			}
+		}
+		c.Process = &p
+	}
+	var n uint64
+	for _, v := range []interface{}{&c.Seed, &c.Draws, &n} {
+		if err := read(v); err != nil {
+			return err
+		}
+	}
+	if n > uint64(buf.Len())/8 { // a vector takes 8 bytes at least
+		return errTruncated
+	}
+	if n > 0 {
+		c.History = make([][]float64, n)
+	}
+	for i := range c.History {
+		if c.History[i], err = readFloats(); err != nil {
+			return err
+		}
+	}
+	*cp = c
+	return nil
+}
+
+// Save writes the checkpoint in the file located at path. The checkpoint
+// is written in JSON format if the file extension is ".json", and in binary
+// format otherwise. Note that the JSON format can not save non finite values.
+func (cp Checkpoint) Save(path string) error {
+	var data []byte
+	var err error
+	if filepath.Ext(path) == ".json" {
+		data, err = json.MarshalIndent(cp, "", "  ")
+	} else {
+		data, err = cp.MarshalBinary()
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadCheckpoint reads a checkpoint from the file located at path. The
+// format is determined by the file extension as for the Save function.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cp, err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &cp)
+	} else {
+		err = cp.UnmarshalBinary(data)
+	}
+	return cp, err
+}
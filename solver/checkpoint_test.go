@@ -0,0 +1,140 @@
+package solver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// resumeRun solves the exponential system from a checkpoint created by
+// CheckpointEvery at the given iteration, with a new controller created by
+// the function controller, after a round trip of the checkpoint through the
+// binary and JSON formats. It returns the final time and the checkpoint.
+func resumeRun(t *testing.T, iteration uint64, controller func() ProcessController) (float64, Checkpoint) {
+	t.Helper()
+	var cp *Checkpoint
+	handler := func(c Checkpoint) error {
+		if cp == nil {
+			cp = &c
+		}
+		return nil
+	}
+	algo := NewRK4Solver()
+	_, err := algo.SolveInPlace(exponential, 0, []float64{1}, 0.1, controller(), nil, CheckpointEvery(iteration, handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp == nil || cp.Process == nil {
+		t.Fatal("no checkpoint with the state of the process")
+	}
+
+	data, err := cp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Checkpoint
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(decoded); err != nil {
+		t.Fatal(err)
+	}
+	var restored Checkpoint
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, *cp) {
+		t.Fatalf("the checkpoint %+v is restored as %+v", *cp, restored)
+	}
+
+	if _, err := algo.SolveInPlace(exponential, 0, nil, 0, controller(), nil, ResumeFrom(restored)); err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := algo.Result()
+	return tr, restored
+}
+
+// TestCheckpointDebounce checks that the count of a Debounce controller is
+// saved in the checkpoints: the process resumed after two of the three
+// consecutive requests stops at the next request.
+func TestCheckpointDebounce(t *testing.T) {
+	controller := func() ProcessController {
+		return Debounce(3, Controller(func(t float64, X []float64) (bool, error) {
+			return t >= 0.45, nil
+		}))
+	}
+	tr, cp := resumeRun(t, 6, controller)
+	if math.Abs(cp.T-0.6) > 1e-12 {
+		t.Fatalf("the checkpoint is at t=%g instead of 0.6", cp.T)
+	}
+	if math.Abs(tr-0.6) > 1e-12 {
+		t.Errorf("the resumed process stops at t=%g instead of 0.6", tr)
+	}
+}
+
+// TestCheckpointSteadyState checks that the time window of a
+// SteadyStateDetector is saved in the checkpoints: a new detector resumed
+// from a checkpoint detects the steady state at the same time as the original
+// detector.
+func TestCheckpointSteadyState(t *testing.T) {
+	decay := func(t float64, X []float64, dXdt []float64) error {
+		dXdt[0] = -X[0]
+		return nil
+	}
+	detect := func(opts ...Option) *SteadyStateDetector {
+		detector := NewSteadyStateDetector(1e-2, 1e-2, 1)
+		algo := NewRK4Solver()
+		if _, err := algo.SolveInPlace(decay, 0, []float64{1}, 0.1, detector, nil, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if !detector.Detected {
+			t.Fatal("no steady state detected")
+		}
+		return detector
+	}
+	reference := detect()
+
+	// The checkpoint is created within the time window of the detection
+	var cp Checkpoint
+	detect(CheckpointEvery(uint64(reference.T/0.1)+3, func(c Checkpoint) error {
+		if cp.Process == nil {
+			cp = c
+		}
+		return nil
+	}))
+	if cp.Process == nil {
+		t.Fatal("no checkpoint with the state of the process")
+	}
+	resumed := detect(ResumeFrom(cp))
+	if resumed.T != reference.T || !reflect.DeepEqual(resumed.X, reference.X) {
+		t.Errorf("the steady state (%g,%v) is detected as (%g,%v) after the resume", reference.T, reference.X, resumed.T, resumed.X)
+	}
+}
+
+// TestCheckpointBinarySizes checks that the sizes of a binary checkpoint are
+// bounded by the size of the data before any allocation.
+func TestCheckpointBinarySizes(t *testing.T) {
+	var buf bytes.Buffer
+	for _, v := range []interface{}{checkpointMagic, uint32(CheckpointVersion), uint32(math.MaxUint32)} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var cp Checkpoint
+	if err := cp.UnmarshalBinary(buf.Bytes()); err != errTruncated {
+		t.Errorf("the method size 2^32-1 gives the error %v", err)
+	}
+
+	data, err := Checkpoint{Method: "rk4", X: []float64{1, 2}, History: [][]float64{{3}}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		if err := cp.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("the checkpoint truncated to %d bytes is decoded", n)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package solver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StopReason specifies why a solving process stopped
+type StopReason struct {
+	T          float64 // time of the state that triggers the stop
+	Controller string  // name of the controller that stops the process (if any)
+	Reason     string  // description of the reason of the stop
+	Err        error   // error of an abnormal stop, nil for a normal stop
+}
+
+// String returns a description of the StopReason
+func (reason StopReason) String() string {
+	s := fmt.Sprintf("t=%g: %s", reason.T, reason.Reason)
+	if reason.Controller != "" {
+		s += fmt.Sprintf(" (controller %s)", reason.Controller)
+	}
+	if reason.Err != nil {
+		s += fmt.Sprintf(": %v", reason.Err)
+	}
+	return s
+}
+
+// Named creates a controller that behaves as the controller c, and whose stop
+// requests are reported with the given name.
+func Named(name string, c ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		stop, err := p.Call(0, c, t, X)
+		if err != nil {
+			return true, err
+		}
+		if stop {
+			p.reason.Controller = name
+		}
+		return stop, nil
+	}
+}
+
+// Or creates a controller that requests a stop when at least one of the
+// controllers requests a stop. The controllers are executed in the specified
+// order, until the first one that requests a stop. The controllers with no
+// name are reported with their index in the list.
+func Or(controllers ...ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		for i, c := range controllers {
+			p.Explain("", "")
+			stop, err := p.Call(i, c, t, X)
+			if err != nil {
+				return true, err
+			}
+			if stop {
+				if p.reason.Controller == "" {
+					p.reason.Controller = fmt.Sprintf("#%d", i)
+				}
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// And creates a controller that requests a stop when all the controllers
+// request a stop at the same time. All the controllers are executed at each
+// call (e.g. to count the consecutive requests of a Debounce controller). The
+// reason of the stop lists the controllers by their name (or by their reason
+// if they have no name).
+func And(controllers ...ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		var conditions []string
+		for i, c := range controllers {
+			p.Explain("", "")
+			stop, err := p.Call(i, c, t, X)
+			if err != nil {
+				return true, err
+			}
+			if !stop {
+				continue
+			}
+			switch {
+			case p.reason.Controller != "":
+				conditions = append(conditions, p.reason.Controller)
+			case p.reason.Reason != "":
+				conditions = append(conditions, p.reason.Reason)
+			default:
+				conditions = append(conditions, "stop requested")
+			}
+		}
+		if len(controllers) == 0 || len(conditions) < len(controllers) {
+			return false, nil
+		}
+		p.Explain("", "all the conditions are satisfied: "+strings.Join(conditions, ", "))
+		return true, nil
+	}
+}
+
+// Not creates a controller that requests a stop when the controller c does not
+// request a stop (the errors of c are transmitted as is). The initial state is
+// transmitted to c but can not stop the process.
+func Not(c ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		stop, err := p.Call(0, c, t, X)
+		if err != nil {
+			return true, err
+		}
+		if stop || p.Iteration == 0 {
+			return false, nil
+		}
+		p.Explain("", "the condition is no longer satisfied")
+		return true, nil
+	}
+}
+
+// AfterN creates a controller that ignores the stop requests of the
+// controller c during the first n iterations of the Process, e.g. to skip the
+// transient regime of the solution. The controller c is executed at each call,
+// and its errors are transmitted as is.
+func AfterN(n uint64, c ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		stop, err := p.Call(0, c, t, X)
+		if err != nil {
+			return true, err
+		}
+		return stop && p.Iteration >= n, nil
+	}
+}
+
+// Debounce creates a controller that requests a stop when the controller c
+// requests a stop on n consecutive calls, e.g. to ignore a condition that is
+// transiently satisfied because of the noise of the solution. The consecutive
+// requests are counted in the state of the Process (see Process.State), so
+// that the count restarts with each solving process and is saved in its
+// checkpoints. The errors of c are transmitted as is.
+func Debounce(n uint64, c ProcessController) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		count := p.State(1)
+		stop, err := p.Call(0, c, t, X)
+		if err != nil {
+			return true, err
+		}
+		if !stop {
+			count[0] = 0
+			return false, nil
+		}
+		count[0]++
+		if uint64(count[0]) < n {
+			return false, nil
+		}
+		reason := p.reason.Reason
+		if reason == "" {
+			reason = "stop requested"
+		}
+		p.reason.Reason = fmt.Sprintf("%s (%d consecutive times)", reason, n)
+		return true, nil
+	}
+}
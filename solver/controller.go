@@ -1,32 +1,212 @@
 package solver
 
 import (
+	"fmt"
 	"math"
+	"strconv"
+	"time"
 )
 
 // Controller defines a function that handles the execution of the solving
 // process. A controller is a function that decides when the process should
 // stop. A solving process can stop in normal condition (e.g. when time reach a
 // given threshold) or because an abnormal behavior occurs (e.g. divergence of
-// the solution outside of an predefined X domain). The Controller is called by the solver
-// at each iteration to know if the solving process should stop. To indicate a
-// normal stop, the Controller should return (bool,error)=(true,nil), bool=true
-// meaning "yes, stop the process". To indicate an abnormal condition, the
-// controller should return (bool,error)=(_,error), error specifying the details
-// concerning the abnormal condition. In this case, the solving process stops
-// returning this error whatever the bool value is.
+// the solution outside of an predefined X domain, see the guard controllers
+// StopOnNonFinite, StopOutsideBox and StopOnNormExceeding). The Controller is
+// called by the solver with the initial state (t0,X0) before the first
+// iteration, and then at each iteration to know if the solving process should
+// stop. To indicate a normal stop, the Controller should return
+// (bool,error)=(true,nil), bool=true meaning "yes, stop the process". To
+// indicate an abnormal condition, the controller should return
+// (bool,error)=(_,error), error specifying the details concerning the abnormal
+// condition. In this case, the solving process stops returning this error
+// whatever the bool value is. The error is wrapped in an Error of kind
+// ErrFunction that specifies the state of the process, unless it is already an
+// Error. As for a Recorder, the slice X is owned by the solver and the
+// controller should not keep a reference to it.
+//
+// A Controller implements the interface ProcessController, and then can be
+// given to the solvers and to the combinators (see Or, And, etc.) as is.
 type Controller func(t float64, X []float64) (bool, error)
 
+// Control implements the ProcessController interface. The information on the
+// process is ignored by a Controller.
+func (c Controller) Control(p *Process, t float64, X []float64) (bool, error) {
+	return c(t, X)
+}
+
+// ProcessController is the interface of the controllers given to the solvers.
+// The function Control has the same contract as a Controller, and receives in
+// addition the Process that calls it, that specifies the initial time, the
+// direction of time and the number of iterations of the solving process. A
+// controller that depends on these values (e.g. StopAtTime) should read them
+// from the Process instead of keeping an internal state, so that it can be
+// reused for several solving processes (possibly concurrent). The Process can
+// also be used to explain a normal stop (see Process.Explain).
+type ProcessController interface {
+	Control(p *Process, t float64, X []float64) (bool, error)
+}
+
+// ProcessControllerFunc is a function that implements the ProcessController
+// interface.
+type ProcessControllerFunc func(p *Process, t float64, X []float64) (bool, error)
+
+// Control implements the ProcessController interface
+func (c ProcessControllerFunc) Control(p *Process, t float64, X []float64) (bool, error) {
+	return c(p, t, X)
+}
+
+// Process specifies a solving process to the controllers. A Process is
+// created by the solver at the beginning of each solving process (see
+// NewProcess), and each state given to the controller is requested with the
+// function Request.
+//
+// The Process also holds the state of the stateful controllers (see State),
+// so that the controllers can be shared by several solving processes, and
+// that their state is saved in the checkpoints of the process (see Save).
+type Process struct {
+	T0        float64   // initial time of the process
+	Direction float64   // direction of time, +1 (forward) or -1 (backward)
+	Iteration uint64    // number of iterations done to reach the requested state
+	Start     time.Time // wall clock time of the beginning of the process
+
+	reason StopReason           // reason of the stop requested by the controller
+	path   []byte               // position of the called controller in the tree of controllers
+	states map[string][]float64 // states of the controllers, by position
+}
+
+// ProcessState is the state of a Process saved in a Checkpoint: the counter
+// of iterations, the elapsed wall clock time and the states of the
+// controllers.
+type ProcessState struct {
+	T0        float64              `json:"t0"`               // initial time of the process
+	Iteration uint64               `json:"iteration"`        // number of requests done
+	Elapsed   time.Duration        `json:"elapsed"`          // wall clock duration of the process
+	States    map[string][]float64 `json:"states,omitempty"` // states of the controllers, by position
+}
+
+// NewProcess creates the Process of a solving process that starts at time t0
+// with a step size h, whose sign gives the direction of time. This function is
+// intended for the implementations of the solvers.
+func NewProcess(t0 float64, h float64) *Process {
+	direction := 1.0
+	if h < 0 {
+		direction = -1.0
+	}
+	return &Process{T0: t0, Direction: direction, Start: time.Now()}
+}
+
+// RestoreProcess creates the Process of a solving process resumed from the
+// saved state (see Save), with a step size h. The process then continues as
+// if it was never stopped: the next request is the iteration that follows the
+// saved one, and the controllers find their saved states. This function is
+// intended for the implementations of the solvers.
+func RestoreProcess(state ProcessState, h float64) *Process {
+	p := NewProcess(state.T0, h)
+	p.Iteration = state.Iteration
+	p.Start = p.Start.Add(-state.Elapsed)
+	if len(state.States) > 0 {
+		p.states = make(map[string][]float64, len(state.States))
+		for key, values := range state.States {
+			p.states[key] = append([]float64(nil), values...)
+		}
+	}
+	return p
+}
+
+// Save returns a copy of the state of the Process, to be saved in a
+// checkpoint (see RestoreProcess).
+func (p *Process) Save() ProcessState {
+	state := ProcessState{T0: p.T0, Iteration: p.Iteration, Elapsed: time.Since(p.Start)}
+	if len(p.states) > 0 {
+		state.States = make(map[string][]float64, len(p.states))
+		for key, values := range p.states {
+			state.States[key] = append([]float64(nil), values...)
+		}
+	}
+	return state
+}
+
+// Request calls the controller c with the state (t,X) of the process, and
+// returns true if c requests a stop, and the error of c in case of an
+// abnormal stop. The state of the first request is the iteration 0 (the
+// initial state), and the iteration counter is incremented at each request.
+// The reason of a normal stop is then given by the function Reason.
+func (p *Process) Request(c ProcessController, t float64, X []float64) (bool, error) {
+	p.reason = StopReason{T: t}
+	p.path = p.path[:0]
+	stop, err := c.Control(p, t, X)
+	p.Iteration++
+	if err != nil {
+		return true, err
+	}
+	if stop && p.reason.Reason == "" {
+		p.reason.Reason = "stop requested by the controller"
+	}
+	return stop, nil
+}
+
+// Explain can be used by a controller to specify the reason of the stop it
+// requests, with the name of the controller (that can be empty).
+func (p *Process) Explain(controller string, reason string) {
+	p.reason.Controller = controller
+	p.reason.Reason = reason
+}
+
+// Reason returns the reason of the stop requested at the last Request
+func (p *Process) Reason() StopReason {
+	return p.reason
+}
+
+// Call calls the controller c, that is the child number index of the calling
+// controller. The combinators call their children with Call, so that each
+// controller has a position in the tree of controllers, that identifies its
+// state (see State).
+func (p *Process) Call(index int, c ProcessController, t float64, X []float64) (bool, error) {
+	n := len(p.path)
+	p.path = strconv.AppendInt(p.path, int64(index), 10)
+	p.path = append(p.path, '.')
+	stop, err := c.Control(p, t, X)
+	p.path = p.path[:n]
+	return stop, err
+}
+
+// State returns the state of the calling controller, i.e. n values kept by the
+// Process for the position of the controller in the tree of controllers (see
+// Call), that are zero at the first call. A controller that depends on the
+// previous states of the process (e.g. Debounce or SteadyStateDetector) should
+// keep its values in the Process instead of an internal state, so that they
+// are specific to the process and saved in its checkpoints.
+func (p *Process) State(n int) []float64 {
+	if values, ok := p.states[string(p.path)]; ok && len(values) == n {
+		return values
+	}
+	if p.states == nil {
+		p.states = make(map[string][]float64)
+	}
+	values := make([]float64, n)
+	p.states[string(p.path)] = values
+	return values
+}
+
+// timeTolerance is the tolerance used to compare two time values
+const timeTolerance = 1e-8
+
 // StopAtTime implements a default stop Controller that stops the solving process
 // when the time exceed the maximum value tmax. In this implementation, the time
 // tmax is included and the process stops, i.e. the time tmax is considered as a
 // good value, and then it will be the result time of the Solve function.
-func StopAtTime(tmax float64) Controller {
-	controller := func(t float64, X []float64) (bool, error) {
-		delta := math.Abs(t - tmax)
-		if delta < 1e-8 || t <= tmax {
+//
+// The controller works in both time directions, given by the Process: for a
+// backward integration (negative step size), the process stops when the time
+// goes below tmax. As the controller needs the Process, it is a
+// ProcessControllerFunc and not a Controller.
+func StopAtTime(tmax float64) ProcessControllerFunc {
+	controller := func(p *Process, t float64, X []float64) (bool, error) {
+		if (t-tmax)*p.Direction < timeTolerance {
 			return false, nil
 		}
+		p.Explain("StopAtTime", fmt.Sprintf("the time reaches tmax=%g", tmax))
 		return true, nil
 	}
 	return controller
@@ -35,18 +215,174 @@ func StopAtTime(tmax float64) Controller {
 // MultiController creates a controller that aggregates a list of controllers.
 // The created controller executes the input controllers in the specified order
 // and return true if a controller return true (meaning that the solving process
-// should be stopped).
-func MultiController(controllers ...Controller) Controller {
+// should be stopped). It is equivalent to the combinator Or.
+//
+// The controllers are of type ProcessController: a slice of Controller given
+// with the syntax controllers... should be declared as a slice of
+// ProcessController.
+func MultiController(controllers ...ProcessController) ProcessControllerFunc {
+	return Or(controllers...)
+}
+
+// StopOnNonFinite creates a guard controller that stops the solving process
+// with an error of kind ErrNonFinite when a component of the state is NaN or
+// Inf. The result of the solving process is then the last finite state.
+func StopOnNonFinite() Controller {
 	return func(t float64, X []float64) (bool, error) {
-		for _, c := range controllers {
-			ok, err := c(t, X)
-			if err != nil {
-				return true, err
+		for i, x := range X {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				err := fmt.Errorf("the component %d is %g", i, x)
+				return true, newError(ErrNonFinite, err, t, X, 0)
 			}
-			if ok {
-				return true, nil
+		}
+		return false, nil
+	}
+}
+
+// StopOutsideBox creates a guard controller that stops the solving process
+// with an error of kind ErrOutOfBounds when a component X[i] of the state is
+// outside the interval [min[i],max[i]]. The bounds can be infinite to leave a
+// component unbounded. An error of kind ErrDimensionMismatch is returned if
+// the bounds and the state have not the same size.
+func StopOutsideBox(min, max []float64) Controller {
+	return func(t float64, X []float64) (bool, error) {
+		if len(min) != len(X) || len(max) != len(X) {
+			err := fmt.Errorf("the box has %d/%d bounds for a state of size %d", len(min), len(max), len(X))
+			return true, newError(ErrDimensionMismatch, err, t, X, 0)
+		}
+		for i, x := range X {
+			if !(x >= min[i] && x <= max[i]) {
+				err := fmt.Errorf("the component %d (%g) is outside [%g,%g]", i, x, min[i], max[i])
+				return true, newError(ErrOutOfBounds, err, t, X, 0)
 			}
 		}
 		return false, nil
 	}
 }
+
+// StopOnNormExceeding creates a guard controller that stops the solving
+// process with an error of kind ErrDivergence when the euclidean norm of the
+// state exceeds the given limit (or is not a number).
+func StopOnNormExceeding(limit float64) Controller {
+	return func(t float64, X []float64) (bool, error) {
+		norm := 0.0
+		for _, x := range X {
+			norm = math.Hypot(norm, x)
+		}
+		if !(norm <= limit) {
+			err := fmt.Errorf("the norm of the state (%g) exceeds %g", norm, limit)
+			return true, newError(ErrDivergence, err, t, X, 0)
+		}
+		return false, nil
+	}
+}
+
+// StopAtMaxIterations creates a controller that stops the solving process
+// (with no error) after n iterations, i.e. the result of the process is the
+// state of the iteration n (the following iteration is the one that triggers
+// the stop, as for StopAtTime). The iterations are counted from the beginning
+// of the Process. See also the option MaxSteps to consider the maximal number
+// of iterations as a failure.
+func StopAtMaxIterations(n uint64) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		if p.Iteration <= n {
+			return false, nil
+		}
+		p.Explain("StopAtMaxIterations", fmt.Sprintf("%d iterations done", n))
+		return true, nil
+	}
+}
+
+// StopAfterWallClock creates a controller that stops the solving process (with
+// no error) when its duration exceeds the given duration d. The duration is
+// measured from the beginning of the Process. See also the function
+// SolveContext, that interrupts the process with an error when the deadline of
+// the context is exceeded.
+func StopAfterWallClock(d time.Duration) ProcessControllerFunc {
+	return func(p *Process, t float64, X []float64) (bool, error) {
+		elapsed := time.Since(p.Start)
+		if elapsed < d {
+			return false, nil
+		}
+		p.Explain("StopAfterWallClock", fmt.Sprintf("the duration %v exceeds %v", elapsed, d))
+		return true, nil
+	}
+}
+
+// SteadyStateDetector detects that a solving process has reached a steady
+// state (an equilibrium of the system). The steady state is detected when the
+// norm of the rate dX/dt stays below the RateTolerance and the change of the
+// state stays below the ChangeTolerance during a time window. The rate is
+// estimated by the change of the state over the last step, and the norms are
+// the maximum of the absolute values of the components.
+//
+// The SteadyStateDetector is a ProcessController that stops the solving process
+// (with no error) at the detection. The detected equilibrium and the time it was
+// reached are then given by the fields Detected, T and X. As these results are
+// specific to one solving process, a detector should not be shared by
+// concurrent solving processes. The state of the detection (the current time
+// window) is kept by the Process, and then saved in its checkpoints.
+type SteadyStateDetector struct {
+	RateTolerance   float64   // tolerance on the norm of dX/dt
+	ChangeTolerance float64   // tolerance on the change of the state during the window
+	Window          float64   // duration of the time window
+	Detected        bool      // true if a steady state is detected
+	T               float64   // time at which the steady state is reached
+	X               []float64 // state of equilibrium (the state at the detection)
+}
+
+// NewSteadyStateDetector creates a SteadyStateDetector with the given
+// tolerances and time window.
+func NewSteadyStateDetector(rateTolerance, changeTolerance, window float64) *SteadyStateDetector {
+	return &SteadyStateDetector{
+		RateTolerance:   rateTolerance,
+		ChangeTolerance: changeTolerance,
+		Window:          math.Abs(window),
+	}
+}
+
+// Control implements the ProcessController interface. The detector is
+// initialized at the initial state of each Process.
+func (detector *SteadyStateDetector) Control(p *Process, t float64, X []float64) (bool, error) {
+	// The state holds the time and the state of the previous call (tm, xm) and
+	// of the beginning of the current window (tref, xref).
+	n := len(X)
+	state := p.State(2 + 2*n)
+	tm, tref := &state[0], &state[1]
+	xm, xref := state[2:2+n], state[2+n:]
+	if p.Iteration == 0 {
+		detector.Detected = false
+		*tm, *tref = t, t
+		copy(xm, X)
+		copy(xref, X)
+		return false, nil
+	}
+
+	rate, change := 0.0, 0.0
+	for i, x := range X {
+		rate = math.Max(rate, math.Abs(x-xm[i]))
+		change = math.Max(change, math.Abs(x-xref[i]))
+	}
+	if dt := math.Abs(t - *tm); dt > 0 {
+		rate /= dt
+	}
+	*tm = t
+	copy(xm, X)
+
+	// The window restarts from the current state when a criterion fails
+	if !(rate <= detector.RateTolerance && change <= detector.ChangeTolerance) {
+		*tref = t
+		copy(xref, X)
+		return false, nil
+	}
+	if math.Abs(t-*tref) < detector.Window {
+		return false, nil
+	}
+
+	detector.Detected = true
+	detector.T = *tref
+	detector.X = make([]float64, len(X))
+	copy(detector.X, X)
+	p.Explain("SteadyStateDetector", fmt.Sprintf("steady state reached at t=%g", detector.T))
+	return true, nil
+}
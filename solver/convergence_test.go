@@ -0,0 +1,72 @@
+package solver
+
+import (
+	"math"
+	"testing"
+)
+
+// riccati is the equation x'=-x^2, whose solution for x(0)=1 is x=1/(1+t)
+func riccati(t float64, X []float64, dXdt []float64) error {
+	dXdt[0] = -X[0] * X[0]
+	return nil
+}
+
+// checkOrder solves the problem f from (0,X0) to T with the fixed step sizes
+// T/n for the given numbers of steps n, and checks that the order observed on
+// the first component of the state, compared to the exact value x, is the
+// order p of the method (within the tolerance tol).
+func checkOrder(t *testing.T, name string, s Solver, f InPlaceFunction, X0 []float64, T, x float64, steps []int, p, tol float64) {
+	t.Helper()
+	errs := make([]float64, len(steps))
+	for i, n := range steps {
+		if _, err := s.SolveInPlace(f, 0, X0, T/float64(n), StopAtTime(T), nil); err != nil {
+			t.Fatal(err)
+		}
+		tr, X := s.Result()
+		if math.Abs(tr-T) > 1e-9 {
+			t.Fatalf("%s: the process ends at t=%g instead of t=%g", name, tr, T)
+		}
+		errs[i] = math.Abs(X[0] - x)
+	}
+	for i := 1; i < len(steps); i++ {
+		order := math.Log2(errs[i-1] / errs[i])
+		if math.Abs(order-p) > tol {
+			t.Errorf("%s: the observed order is %.2f instead of %g between %d and %d steps (errors %g and %g)",
+				name, order, p, steps[i-1], steps[i], errs[i-1], errs[i])
+		}
+	}
+}
+
+// TestRK4Convergence checks the order 4 of the RK4 method
+func TestRK4Convergence(t *testing.T) {
+	checkOrder(t, "rk4", NewRK4Solver(), riccati, []float64{1}, 2, 1.0/3, []int{16, 32, 64}, 4, 0.25)
+}
+
+// TestSDIRKConvergence checks the orders of the implicit Euler and SDIRK2
+// methods
+func TestSDIRKConvergence(t *testing.T) {
+	steps := []int{8, 16, 32, 64}
+	checkOrder(t, "implicit euler", NewImplicitEulerSolver(), riccati, []float64{1}, 2, 1.0/3, steps, 1, 0.1)
+	checkOrder(t, "sdirk2", NewSDIRK2Solver(), riccati, []float64{1}, 2, 1.0/3, steps, 2, 0.1)
+}
+
+// oscillator is the harmonic oscillator d2x/dt2=-x, whose solution for x(0)=1
+// and dx/dt(0)=0 is x=cos(t)
+var oscillator = SecondOrderFunction(func(t float64, X, V, A []float64) error {
+	A[0] = -X[0]
+	return nil
+})
+
+// TestRadau5Convergence checks the order 5 of the Radau5 method
+func TestRadau5Convergence(t *testing.T) {
+	steps := []int{8, 16, 32, 64}
+	checkOrder(t, "radau5", NewRadau5Solver(), oscillator.FirstOrder(), []float64{1, 0}, 4, math.Cos(4), steps, 5, 0.3)
+}
+
+// TestRKNConvergence checks the orders of the RKN4 and DPRKN6 methods
+func TestRKNConvergence(t *testing.T) {
+	steps := []int{8, 16, 32, 64}
+	f := oscillator.FirstOrder()
+	checkOrder(t, "rkn4", NewRKN4Solver(), f, []float64{1, 0}, 4, math.Cos(4), []int{16, 32, 64}, 4, 0.3)
+	checkOrder(t, "dprkn6", NewDPRKN6Solver(), f, []float64{1, 0}, 4, math.Cos(4), steps, 6, 0.1)
+}
@@ -0,0 +1,145 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gboulant/dingo-ode/linalg"
+)
+
+// DAEFunction defines the function f of the differential equations of a
+// DAEProblem: the rate dX/dt = f(t,X,Z) is written in the slice dXdt.
+type DAEFunction func(t float64, X, Z []float64, dXdt []float64) error
+
+// AlgebraicFunction defines the function g of the algebraic equations of a
+// DAEProblem: the residual g(t,X,Z) is written in the slice R (of the size of
+// Z).
+type AlgebraicFunction func(t float64, X, Z []float64, R []float64) error
+
+// DAEProblem defines a differential algebraic equation (DAE) in the
+// semi-explicit form:
+//
+//	dX/dt = f(t,X,Z)
+//	    0 = g(t,X,Z)
+//
+// where X are the differential components and Z the algebraic components
+// (e.g. the pressures of the nodes of a hydraulic network). The DAE should be
+// of index 1, i.e. the Jacobian matrix ∂g/∂Z should be invertible, so that the
+// algebraic components are defined by the differential ones.
+//
+// The DAEProblem is solved by an ImplicitSolver as a MassMatrixProblem whose
+// state is the concatenation (X,Z) and whose mass matrix is diag(I,0). The
+// controllers, the recorders and the events then receive the state (X,Z), and
+// the differential and algebraic components of a TimeSeries can be separated
+// with the function Components.
+type DAEProblem struct {
+	F DAEFunction       // the rate function f(t,X,Z)
+	G AlgebraicFunction // the algebraic function g(t,X,Z)
+	// Jacobian is the optional Jacobian matrix of (f,g) relatively to (X,Z).
+	// If nil, the Jacobian matrix is approximated by finite differences.
+	Jacobian JacobianFunction
+	// Pattern is the optional sparsity pattern of the Jacobian matrix of (f,g)
+	// relatively to (X,Z) (see MassMatrixProblem).
+	Pattern *linalg.Pattern
+}
+
+// MassMatrixProblem returns the MassMatrixProblem equivalent to the DAE for nx
+// differential components, i.e. the problem of state (X,Z) and of mass matrix
+// diag(I,0).
+func (p DAEProblem) MassMatrixProblem(nx int) MassMatrixProblem {
+	return MassMatrixProblem{
+		F: func(t float64, Y []float64, dYdt []float64) error {
+			if err := p.F(t, Y[:nx], Y[nx:], dYdt[:nx]); err != nil {
+				return err
+			}
+			return p.G(t, Y[:nx], Y[nx:], dYdt[nx:])
+		},
+		M: func(t float64, Y []float64, M *linalg.Matrix) error {
+			for i := 0; i < nx; i++ {
+				M.Set(i, i, 1)
+			}
+			return nil
+		},
+		ConstantMass: true,
+		Jacobian:     p.Jacobian,
+		Pattern:      p.Pattern,
+	}
+}
+
+// ConsistentInitialization computes the algebraic components Z that satisfy
+// the algebraic equations g(t0,X0,Z)=0 for the given differential components
+// X0, using the Newton iterations from the initial guess Z0 (the Jacobian
+// matrix ∂g/∂Z is approximated by finite differences). The tolerance and the
+// maximal number of iterations are defined by the option Newton. An error of
+// kind ErrNewtonDivergence is returned if the iterations do not converge.
+func (p DAEProblem) ConsistentInitialization(t0 float64, X0, Z0 []float64, opts ...Option) ([]float64, error) {
+	if p.F == nil || p.G == nil {
+		err := errors.New("the functions f and g of the DAE should be defined")
+		return nil, newError(ErrInvalidInput, err, t0, X0, 0)
+	}
+	config := newSettings(opts...)
+	n := len(Z0)
+	Z := make([]float64, n)
+	copy(Z, Z0)
+	R := make([]float64, n)
+	Rd := make([]float64, n)
+	var J linalg.Matrix
+	var lu linalg.LU
+	J.Resize(n, n)
+	for iteration := 0; iteration < config.newtonIterations; iteration++ {
+		if err := p.G(t0, X0, Z, R); err != nil {
+			return nil, newError(ErrFunction, err, t0, Z, 0)
+		}
+		for col := 0; col < n; col++ {
+			delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(Z[col]))
+			z := Z[col]
+			Z[col] = z + delta
+			if err := p.G(t0, X0, Z, Rd); err != nil {
+				return nil, newError(ErrFunction, err, t0, Z, 0)
+			}
+			for row := 0; row < n; row++ {
+				J.Set(row, col, (Rd[row]-R[row])/delta)
+			}
+			Z[col] = z
+		}
+		if err := lu.Factorize(&J); err != nil {
+			return nil, newError(ErrNewtonDivergence, err, t0, Z, 0)
+		}
+		lu.Solve(R)
+		norm := 0.0 // norm of the correction relatively to the tolerance
+		for i := range Z {
+			Z[i] -= R[i]
+			norm = math.Max(norm, math.Abs(R[i])/(config.newtonTolerance*(1+math.Abs(Z[i]))))
+		}
+		if norm <= 1 {
+			return Z, nil
+		}
+	}
+	err := fmt.Errorf("no consistent initialization after %d iterations", config.newtonIterations)
+	return nil, newError(ErrNewtonDivergence, err, t0, Z, 0)
+}
+
+// SolveDAE implements the ImplicitSolver interface
+func (solver *implicitSolver) SolveDAE(p DAEProblem, t0 float64, X0, Z0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveDAEContext(context.Background(), p, t0, X0, Z0, h, c, r, opts...)
+}
+
+// SolveDAEContext implements the ImplicitSolver interface. The algebraic
+// components are initialized by ConsistentInitialization, unless the solving
+// process is resumed from a checkpoint (the state of the checkpoint is then
+// already consistent).
+func (solver *implicitSolver) SolveDAEContext(ctx context.Context, p DAEProblem, t0 float64, X0, Z0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	Z := Z0
+	if newSettings(opts...).resume == nil {
+		var err error
+		if Z, err = p.ConsistentInitialization(t0, X0, Z0, opts...); err != nil {
+			return 0, err
+		}
+	}
+	Y0 := make([]float64, len(X0)+len(Z))
+	copy(Y0, X0)
+	copy(Y0[len(X0):], Z)
+	return solver.SolveMassMatrixContext(ctx, p.MassMatrixProblem(len(X0)), t0, Y0, h, c, r, opts...)
+}
@@ -0,0 +1,382 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DelayFunction defines the rate function of a delay differential equation
+// (DDE), i.e. an equation where the rate dX/dt at time t depends on the past
+// states X(t-τ):
+//
+//	dX/dt = f(t, X(t), X(t-τ1), X(t-τ2), ...)
+//
+// The past states are given by the History of the solving process. The rate
+// is written in the slice dXdt.
+type DelayFunction func(t float64, X []float64, past *History, dXdt []float64) error
+
+// HistoryFunction defines the initial history of a DDE, i.e. the state
+// X(t)=φ(t) for the times t before the initial time t0. The state is written
+// in the slice X.
+type HistoryFunction func(t float64, X []float64)
+
+// DelayFunctionOf returns the delay τ(t,X) of a state dependent delay
+type DelayFunctionOf func(t float64, X []float64) float64
+
+// defaultDiscontinuityOrder is the default order of the tracked discontinuities
+const defaultDiscontinuityOrder = 5
+
+// DelayProblem defines a delay differential equation with its initial
+// history. The initial history and the initial state usually do not match
+// (i.e. φ(t0)≠X0 or dφ/dt(t0)≠dX/dt(t0)): the solution then has a
+// discontinuity in its derivatives at t0, that propagates to the times t where
+// the delayed time t-τ crosses a discontinuity. The solver lands exactly on
+// these times (tracked up to the given order) to keep the accuracy of the
+// method: the times are computed in advance for the constant delays, and
+// detected as events for the state dependent delays.
+type DelayProblem struct {
+	F       DelayFunction   // the rate function f
+	History HistoryFunction // the initial history φ(t) for t<t0
+	// Delays are the constant delays of the problem, used to track the
+	// discontinuities.
+	Delays []float64
+	// StateDelays are the state dependent delays τ(t,X) of the problem, used
+	// to track the discontinuities. The delays should be positive. The
+	// discontinuities are detected by internal events (whose reset map
+	// changes nothing), that are not reported by the functions Events and
+	// Transitions of the solver.
+	StateDelays []DelayFunctionOf
+	// DiscontinuityOrder is the number of propagations of the initial
+	// discontinuity that are tracked (the discontinuity of the level k is in
+	// the derivative of order k). The default value is 5 (the solution is then
+	// smooth enough for the RK4 method).
+	DiscontinuityOrder int
+}
+
+// History gives the states of a DDE at the past times. The history is the
+// initial history function before the initial time t0, and the dense output
+// of the accepted steps after t0. The times after the last accepted step
+// (i.e. in the current step, for a delay shorter than the step size) are
+// extrapolated from the last step. The History is owned by the solver: it is
+// valid until the next solving process.
+type History struct {
+	initial HistoryFunction
+	t0      float64
+	x0      []float64
+	t       []float64 // times of the ends of the steps
+	data    []float64 // X0, F0, X1 and F1 of the steps (4n values per step)
+	dense   DenseOutput
+	work    []float64
+}
+
+// reset initializes the history for a solving process starting at (t0,X0)
+func (history *History) reset(initial HistoryFunction, t0 float64, X0 []float64) {
+	history.initial = initial
+	history.t0 = t0
+	history.x0 = resize(history.x0, len(X0))
+	copy(history.x0, X0)
+	history.t = history.t[:0]
+	history.data = history.data[:0]
+	history.work = resize(history.work, len(X0))
+}
+
+// append adds the step of the dense output, ending at the time t1 (that can
+// be before the end of the dense output if the step is interrupted by an
+// event).
+func (history *History) append(dense *DenseOutput, t1 float64) {
+	n := len(history.x0)
+	if len(history.t) == 0 {
+		history.t = append(history.t, dense.T0)
+	}
+	history.t = append(history.t, t1)
+	history.data = append(history.data, dense.X0...)
+	history.data = append(history.data, dense.F0...)
+	if t1 == dense.T1 {
+		history.data = append(history.data, dense.X1...)
+		history.data = append(history.data, dense.F1...)
+		return
+	}
+	k := len(history.data)
+	history.data = append(history.data, make([]float64, 2*n)...)
+	dense.Eval(t1, history.data[k:k+n])
+	dense.slope(t1, history.data[k+n:k+2*n])
+}
+
+// step returns the dense output of the step that contains the time t, or nil
+// if t is before the first step.
+func (history *History) step(t float64) *DenseOutput {
+	steps := len(history.t) - 1
+	if steps <= 0 || t < history.t[0] {
+		return nil
+	}
+	k := sort.SearchFloat64s(history.t[1:], t)
+	if k == steps {
+		k = steps - 1 // extrapolation from the last step
+	}
+	n := len(history.x0)
+	data := history.data[4*n*k : 4*n*(k+1)]
+	history.dense.set(history.t[k], data[:n], data[n:2*n], history.t[k+1], data[2*n:3*n], data[3*n:])
+	return &history.dense
+}
+
+// Eval writes in X the state at the time t
+func (history *History) Eval(t float64, X []float64) {
+	if t < history.t0 {
+		history.initial(t, X)
+		return
+	}
+	dense := history.step(t)
+	if dense == nil {
+		copy(X, history.x0)
+		return
+	}
+	dense.Eval(t, X)
+}
+
+// Component returns the component i of the state at the time t
+func (history *History) Component(i int, t float64) float64 {
+	if t < history.t0 {
+		history.initial(t, history.work)
+		return history.work[i]
+	}
+	dense := history.step(t)
+	if dense == nil {
+		return history.x0[i]
+	}
+	h := dense.T1 - dense.T0
+	s := (t - dense.T0) / h
+	h00 := (1 + 2*s) * (1 - s) * (1 - s)
+	h10 := s * (1 - s) * (1 - s)
+	h01 := s * s * (3 - 2*s)
+	h11 := s * s * (s - 1)
+	return h00*dense.X0[i] + h10*h*dense.F0[i] + h01*dense.X1[i] + h11*h*dense.F1[i]
+}
+
+// discontinuity is a discontinuity of the solution of a DDE
+type discontinuity struct {
+	t     float64
+	level int // order of the derivative that is discontinuous
+}
+
+// DelaySolver solves the delay differential equations with a Solver of the
+// package (e.g. the RK4 solver), whose steps are stored in the History. The
+// Solver functions of the DelaySolver solve the ordinary differential
+// equations as the underlying solver.
+type DelaySolver struct {
+	Solver
+	history         History
+	discontinuities []discontinuity
+
+	// events is the number of events of the user, that precede in the list
+	// of the events the internal events tracking the discontinuities of the
+	// state dependent delays (if tracked is true).
+	events  int
+	tracked bool
+}
+
+// NewDelaySolver creates a DelaySolver based on the solver s
+func NewDelaySolver(s Solver) *DelaySolver {
+	return &DelaySolver{Solver: s}
+}
+
+// History returns the history of the last solving process
+func (solver *DelaySolver) History() *History {
+	return &solver.history
+}
+
+// Discontinuities returns the times of the discontinuities tracked during the
+// last solving process, in the time order (the discontinuities of the constant
+// delays are computed in advance, and can be after the end of the process).
+func (solver *DelaySolver) Discontinuities() []float64 {
+	times := make([]float64, len(solver.discontinuities))
+	for i, d := range solver.discontinuities {
+		times[i] = d.t
+	}
+	return times
+}
+
+// Events returns the occurrences of the events of the user during the last
+// solving process. The internal events that track the discontinuities of the
+// state dependent delays are not reported (see Discontinuities).
+func (solver *DelaySolver) Events() []EventOccurrence {
+	occurrences := solver.Solver.Events()
+	if !solver.tracked {
+		return occurrences
+	}
+	filtered := make([]EventOccurrence, 0, len(occurrences))
+	for _, o := range occurrences {
+		if o.Index < solver.events {
+			filtered = append(filtered, o)
+		}
+	}
+	return filtered
+}
+
+// Transitions returns the transitions of the last solving process, without the
+// ones of the internal events that track the discontinuities (see Events).
+func (solver *DelaySolver) Transitions() []Transition {
+	transitions := solver.Solver.Transitions()
+	if !solver.tracked {
+		return transitions
+	}
+	filtered := make([]Transition, 0, len(transitions))
+	for _, tr := range transitions {
+		if tr.Event < solver.events {
+			filtered = append(filtered, tr)
+		}
+	}
+	return filtered
+}
+
+// SolveDelay solves the DDE p from the initial state (t0,X0), with the
+// controller c and the recorder r as the Solve function of a Solver. The step
+// size h should be positive (no backward integration), and it should be lower
+// than the delays for a good accuracy (otherwise the delayed states are
+// extrapolated). The process can not be checkpointed nor resumed from a
+// checkpoint (the history is not part of the checkpoint), and the alternative modes of a
+// hybrid system are not supported.
+func (solver *DelaySolver) SolveDelay(p DelayProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveDelayContext(context.Background(), p, t0, X0, h, c, r, opts...)
+}
+
+// SolveDelayContext is the same as SolveDelay, but the solving process is
+// aborted when the context is canceled or when its deadline is exceeded.
+func (solver *DelaySolver) SolveDelayContext(ctx context.Context, p DelayProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	if p.F == nil || p.History == nil {
+		err := errors.New("the function f and the history of the DDE should be defined")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
+	if !(h > 0) {
+		err := errors.New("the step size of a DDE should be positive")
+		return 0, newError(ErrStepSizeTooSmall, err, t0, X0, h)
+	}
+	config := newSettings(opts...)
+	if config.resume != nil || config.checkpointInterval > 0 {
+		err := errors.New("the solving process of a DDE can not be checkpointed nor resumed from a checkpoint")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
+	if len(config.modes) > 0 {
+		err := errors.New("the modes are not supported for a DDE")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
+	if c == nil && len(config.outputTimes) == 0 {
+		err := errors.New("the controller c is not defined")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
+	order := p.DiscontinuityOrder
+	if order <= 0 {
+		order = defaultDiscontinuityOrder
+	}
+
+	// The discontinuities of the constant delays are the times t0+sum(k_i*τ_i)
+	// with sum(k_i) <= order
+	solver.discontinuities = []discontinuity{{t: t0, level: 0}}
+	for _, tau := range p.Delays {
+		if !(tau > 0) {
+			err := fmt.Errorf("the delay %g should be positive", tau)
+			return 0, newError(ErrInvalidInput, err, t0, X0, h)
+		}
+		for _, d := range solver.discontinuities {
+			for t, level := d.t+tau, d.level+1; level <= order; t, level = t+tau, level+1 {
+				solver.discontinuities = append(solver.discontinuities, discontinuity{t: t, level: level})
+			}
+		}
+	}
+	solver.discontinuities = mergeDiscontinuities(solver.discontinuities)
+	breakpoints := make([]float64, 0, len(solver.discontinuities))
+	for _, d := range solver.discontinuities {
+		breakpoints = append(breakpoints, d.t)
+	}
+	opts = append(opts, Breakpoints(breakpoints...))
+
+	// The discontinuities of the state dependent delays are the times where
+	// the delayed time t-τ(t,X) crosses a discontinuity, detected as events.
+	events := config.events[:len(config.events):len(config.events)]
+	solver.events, solver.tracked = len(events), len(p.StateDelays) > 0
+	for _, tau := range p.StateDelays {
+		events = append(events, solver.tracking(tau, order))
+	}
+	if len(p.StateDelays) > 0 {
+		opts = append(opts, DetectEvents(events...))
+	}
+
+	solver.history.reset(p.History, t0, X0)
+	f := func(t float64, X []float64, dXdt []float64) error {
+		return p.F(t, X, &solver.history, dXdt)
+	}
+	controller := func(p *Process, t float64, X []float64) (bool, error) {
+		if p.Iteration > 0 {
+			solver.history.append(solver.DenseOutput(), t)
+		}
+		if c == nil {
+			return false, nil
+		}
+		return c.Control(p, t, X)
+	}
+	return solver.Solver.SolveInPlaceContext(ctx, f, t0, X0, h, ProcessControllerFunc(controller), r, opts...)
+}
+
+// tracking creates the event that detects the times where the delayed time
+// t-τ(t,X) crosses the next discontinuity of a level lower than order. The
+// discontinuity found is inserted in the list of the discontinuities.
+func (solver *DelaySolver) tracking(tau DelayFunctionOf, order int) Event {
+	crossed := math.Inf(-1) // time of the last discontinuity crossed
+	target := func() (discontinuity, bool) {
+		for _, d := range solver.discontinuities {
+			if d.t > crossed && d.level < order {
+				return d, true
+			}
+		}
+		return discontinuity{}, false
+	}
+	return Event{
+		Name:      "discontinuity",
+		Direction: 1,
+		Land:      true,
+		G: func(t float64, X []float64) float64 {
+			d, ok := target()
+			if !ok {
+				return -1
+			}
+			return t - tau(t, X) - d.t
+		},
+		Reset: func(t float64, X []float64, mode int) (int, error) {
+			if d, ok := target(); ok {
+				crossed = d.t
+				solver.insert(discontinuity{t: t, level: d.level + 1})
+			}
+			return mode, nil
+		},
+	}
+}
+
+// insert inserts the discontinuity d in the list of the discontinuities,
+// keeping the list sorted by time.
+func (solver *DelaySolver) insert(d discontinuity) {
+	list := solver.discontinuities
+	k := sort.Search(len(list), func(i int) bool { return list[i].t > d.t })
+	list = append(list, discontinuity{})
+	copy(list[k+1:], list[k:])
+	list[k] = d
+	solver.discontinuities = list
+}
+
+// mergeDiscontinuities sorts the discontinuities by time, and merges the ones
+// whose times are equal (keeping the lowest level).
+func mergeDiscontinuities(list []discontinuity) []discontinuity {
+	sort.Slice(list, func(i, j int) bool { return list[i].t < list[j].t })
+	merged := list[:0]
+	for _, d := range list {
+		if k := len(merged) - 1; k >= 0 && math.Abs(d.t-merged[k].t) < timeTolerance {
+			if d.level < merged[k].level {
+				merged[k].level = d.level
+			}
+			continue
+		}
+		merged = append(merged, d)
+	}
+	return merged
+}
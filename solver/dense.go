@@ -0,0 +1,71 @@
+package solver
+
+// DenseOutput is a continuous approximation of the solution over a step
+// [T0,T1] of a solving process. The approximation is the cubic Hermite
+// polynomial defined by the states X0, X1 and the slopes F0=f(T0,X0),
+// F1=f(T1,X1) at both ends of the step. It is third order accurate for the RK
+// methods, and then consistent with the accuracy of the RK2 and RK4 methods.
+//
+// The slices of a DenseOutput are owned by the solver: the DenseOutput is valid
+// until the next step of the solving process.
+type DenseOutput struct {
+	T0, T1 float64
+	X0, X1 []float64
+	F0, F1 []float64
+
+	x1, f1 []float64 // buffers of the end of a step cut by an event or a breakpoint
+}
+
+// Eval writes in X the approximation of the solution at time t. The time t is
+// expected to be in the step [T0,T1] (extrapolation otherwise).
+func (dense *DenseOutput) Eval(t float64, X []float64) {
+	h := dense.T1 - dense.T0
+	if h == 0 {
+		copy(X, dense.X1)
+		return
+	}
+	s := (t - dense.T0) / h
+	h00 := (1 + 2*s) * (1 - s) * (1 - s)
+	h10 := s * (1 - s) * (1 - s)
+	h01 := s * s * (3 - 2*s)
+	h11 := s * s * (s - 1)
+	for i := 0; i < len(X); i++ {
+		X[i] = h00*dense.X0[i] + h10*h*dense.F0[i] + h01*dense.X1[i] + h11*h*dense.F1[i]
+	}
+}
+
+// set defines the step of the DenseOutput
+func (dense *DenseOutput) set(t0 float64, X0, F0 []float64, t1 float64, X1, F1 []float64) {
+	dense.T0, dense.X0, dense.F0 = t0, X0, F0
+	dense.T1, dense.X1, dense.F1 = t1, X1, F1
+}
+
+// cut ends the step of the DenseOutput at time t1, with the state X1 and the
+// slope F1. The vectors are copied, as the state and the slope of the solver
+// are then changed by the reset of an event or by the restart at a
+// breakpoint.
+func (dense *DenseOutput) cut(t1 float64, X1, F1 []float64) {
+	dense.x1 = resize(dense.x1, len(X1))
+	dense.f1 = resize(dense.f1, len(F1))
+	copy(dense.x1, X1)
+	copy(dense.f1, F1)
+	dense.T1, dense.X1, dense.F1 = t1, dense.x1, dense.f1
+}
+
+// slope writes in F the derivative of the approximation of the solution at
+// time t.
+func (dense *DenseOutput) slope(t float64, F []float64) {
+	h := dense.T1 - dense.T0
+	if h == 0 {
+		copy(F, dense.F1)
+		return
+	}
+	s := (t - dense.T0) / h
+	d00 := 6 * s * (s - 1) / h
+	d10 := (1 - s) * (1 - 3*s)
+	d01 := -d00
+	d11 := s * (3*s - 2)
+	for i := 0; i < len(F); i++ {
+		F[i] = d00*dense.X0[i] + d10*dense.F0[i] + d01*dense.X1[i] + d11*dense.F1[i]
+	}
+}
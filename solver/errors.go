@@ -0,0 +1,64 @@
+package solver
+
+import (
+	"errors"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+)
+
+// The kinds of the errors that can stop a solving process. A solving process
+// that fails returns an Error whose kind can be checked with errors.Is, for
+// example errors.Is(err, ErrNonFinite).
+var (
+	// ErrStepSizeTooSmall is the kind of error returned when the step size is
+	// too small to make the time progress.
+	ErrStepSizeTooSmall = errors.New("ERR: the step size is too small")
+	// ErrNonFinite is the kind of error returned when a non finite value (NaN
+	// or Inf) is detected in the state of the system.
+	ErrNonFinite = errors.New("ERR: non finite value in the state")
+	// ErrMaxSteps is the kind of error returned when the maximal number of
+	// steps is reached before the end of the solving process (see MaxSteps).
+	ErrMaxSteps = errors.New("ERR: the maximal number of steps is reached")
+	// ErrNewtonDivergence is the kind of error returned by the implicit
+	// methods when the Newton iterations do not converge.
+	ErrNewtonDivergence = errors.New("ERR: the Newton iterations do not converge")
+	// ErrDimensionMismatch is the kind of error returned when the dimension of
+	// a vector does not match the dimension of the state of the system.
+	ErrDimensionMismatch = errors.New("ERR: dimension mismatch")
+	// ErrFunction is the kind of error returned when a function given by the
+	// user (rate function, reset map, controller) returns an error. This error
+	// is wrapped by the Error and can be retrieved with errors.Is or errors.As.
+	ErrFunction = errors.New("ERR: a user function returns an error")
+	// ErrOutOfBounds is the kind of error returned when the state leaves the
+	// domain of validity of the system (see StopOutsideBox).
+	ErrOutOfBounds = errors.New("ERR: the state is out of bounds")
+	// ErrDivergence is the kind of error returned when the norm of the state
+	// exceeds a limit (see StopOnNormExceeding).
+	ErrDivergence = errors.New("ERR: the solution diverges")
+	// ErrZeno is the kind of error returned when a Zeno behavior of a hybrid
+	// system is detected (see ZenoLimit).
+	ErrZeno = errors.New("ERR: Zeno behavior detected")
+	// ErrInterrupted is the kind of error returned when the solving process is
+	// interrupted by its context. The error of the context is wrapped by the
+	// Error.
+	ErrInterrupted = errors.New("ERR: the solving process is interrupted")
+	// ErrInvalidInput is the kind of error returned when the arguments or the
+	// options of a solving process are not valid (e.g. an undefined function,
+	// a checkpoint of another method, or a mode that is not defined).
+	ErrInvalidInput = errors.New("ERR: invalid input")
+)
+
+// Error is the error returned by the solvers and the controllers when a solving
+// process fails. It specifies the kind of the error (Kind), the state of the
+// solving process at the failure (T, X and the step size H, 0 if unknown), and
+// the underlying error if any (Err, e.g. the error returned by the rate
+// function). The kind of the error can be checked with errors.Is, and the
+// underlying error is returned by errors.Unwrap.
+type Error = core.Error
+
+// newError creates an Error of the given kind for the state (t,X) and the step
+// size h, with a copy of X. If err is already an Error, it is returned
+// unchanged, except for the step size that is completed if unknown.
+func newError(kind error, err error, t float64, X []float64, h float64) error {
+	return core.NewError(kind, err, t, X, h)
+}
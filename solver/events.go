@@ -0,0 +1,257 @@
+package solver
+
+import (
+	"math"
+)
+
+// EventFunction defines the function g(t,X) of an Event. The event occurs when
+// g crosses zero.
+type EventFunction func(t float64, X []float64) float64
+
+// Event defines a condition g(t,X)=0 monitored by the solver during the solving
+// process (for example the crossing of a surface by the trajectory). The solver
+// checks the sign of g at the end of each step and, when g changes sign, it
+// locates precisely the time of the crossing using a root finding (Brent
+// method) on the dense output of the step.
+type Event struct {
+	Name string        // name of the event (optional, for information only)
+	G    EventFunction // the event occurs when g(t,X) crosses zero
+	// Direction restricts the detection to the crossings where g is increasing
+	// (Direction>0) or decreasing (Direction<0). All the crossings are detected
+	// if Direction=0.
+	Direction int
+	// Terminal specifies that the solving process should stop when the event
+	// occurs. The final state of the process is then the state at the event.
+	Terminal bool
+	// Reset is an optional action executed when the event occurs (e.g. the
+	// bounce of a ball or the switch of a relay). The integration is then
+	// restarted from the time of the event with the state and the mode
+	// modified by the reset map. The state after the reset is recorded in
+	// addition to the state before the reset, unless the reset map keeps the
+	// state and the mode unchanged.
+	Reset ResetMap
+	// Land specifies that the step interrupted by the event (a terminal event
+	// or an event with a reset map) is computed again from its beginning to
+	// end exactly at the time of the event, instead of taking the state given
+	// by the dense output. It should be used when the rate function is not
+	// smooth across the event (e.g. the discontinuities of a DDE), because
+	// the dense output of the step is then inaccurate.
+	Land bool
+}
+
+// ResetMap defines the action of an Event on the system. The function can
+// modify the state X in place, and returns the index of the mode to activate,
+// i.e. the index of the rate function to be used from the event (see the
+// option Modes). It should return the current mode to keep the same rate
+// function.
+type ResetMap func(t float64, X []float64, mode int) (int, error)
+
+// Transition registers a reset of the system by an event
+type Transition struct {
+	T     float64 // time of the reset
+	Event int     // index of the event that resets the system
+	From  int     // mode before the reset
+	To    int     // mode after the reset
+}
+
+// EventOccurrence registers an occurrence of an event during a solving process
+type EventOccurrence struct {
+	Index int       // index of the event in the list given to DetectEvents
+	Name  string    // name of the event
+	T     float64   // time of the event
+	X     []float64 // state at the time of the event
+}
+
+// eventHit is a crossing of an event function detected in a step
+type eventHit struct {
+	index int
+	t     float64
+}
+
+// eventMonitor monitors the events during a solving process
+type eventMonitor struct {
+	events      []Event
+	gm, gn      []float64 // values of the event functions at the beginning and the end of the step
+	hits        []eventHit
+	xe          []float64 // workspace for the interpolated states
+	before      float64   // value of the function of the interrupting event before the crossing
+	occurrences []EventOccurrence
+}
+
+// newEventMonitor creates an eventMonitor for the given events, or nil if there
+// is no event.
+func newEventMonitor(events []Event) *eventMonitor {
+	if len(events) == 0 {
+		return nil
+	}
+	return &eventMonitor{
+		events: events,
+		gm:     make([]float64, len(events)),
+		gn:     make([]float64, len(events)),
+		hits:   make([]eventHit, 0, len(events)),
+	}
+}
+
+// start initializes the values of the event functions at the initial state
+func (monitor *eventMonitor) start(t0 float64, X0 []float64) {
+	monitor.xe = resize(monitor.xe, len(X0))
+	for i, event := range monitor.events {
+		monitor.gm[i] = event.G(t0, X0)
+	}
+}
+
+// restart initializes the values of the event functions at the state of a
+// restart, after the interruption of a step by the event index. The function of
+// this event is considered on its side before the crossing (if it is not
+// already), so that the event can be detected again in the next step, even if
+// the reset map leaves the state exactly on the surface g=0.
+func (monitor *eventMonitor) restart(t float64, X []float64, index int) {
+	monitor.start(t, X)
+	g := monitor.gm[index]
+	if g == 0 || math.Signbit(g) != math.Signbit(monitor.before) {
+		monitor.gm[index] = math.Copysign(math.SmallestNonzeroFloat64, monitor.before)
+	}
+}
+
+// crossing returns true if the event function crosses zero from gm to gn in
+// the direction of the event
+func (event Event) crossing(gm, gn float64) bool {
+	increasing := gm < 0 && gn >= 0
+	decreasing := gm > 0 && gn <= 0
+	switch {
+	case event.Direction > 0:
+		return increasing
+	case event.Direction < 0:
+		return decreasing
+	default:
+		return increasing || decreasing
+	}
+}
+
+// step checks the events on the step of the dense output. The occurrences of
+// the events are registered in the time order, until the first event that
+// interrupts the step, i.e. a terminal event or an event with a reset map. The
+// function then returns the time of this event, the state at this time, and
+// the index of the event. The index is -1 if the step is not interrupted.
+func (monitor *eventMonitor) step(dense *DenseOutput) (float64, []float64, int) {
+	hits := monitor.hits[:0]
+	for i, event := range monitor.events {
+		monitor.gn[i] = event.G(dense.T1, dense.X1)
+		if !event.crossing(monitor.gm[i], monitor.gn[i]) {
+			continue
+		}
+		g := func(t float64) float64 {
+			dense.Eval(t, monitor.xe)
+			return event.G(t, monitor.xe)
+		}
+		tol := 1e-10 * math.Abs(dense.T1-dense.T0)
+		t := findRoot(g, dense.T0, dense.T1, monitor.gm[i], monitor.gn[i], tol)
+
+		// Insertion in the time order (in the direction of integration)
+		hit := eventHit{index: i, t: t}
+		hits = append(hits, hit)
+		for k := len(hits) - 1; k > 0 && (hits[k-1].t-t)*(dense.T1-dense.T0) > 0; k-- {
+			hits[k], hits[k-1] = hits[k-1], hits[k]
+		}
+	}
+
+	for _, hit := range hits {
+		event := monitor.events[hit.index]
+		X := make([]float64, len(dense.X1))
+		dense.Eval(hit.t, X)
+		monitor.occurrences = append(monitor.occurrences, EventOccurrence{
+			Index: hit.index,
+			Name:  event.Name,
+			T:     hit.t,
+			X:     X,
+		})
+		if event.Terminal || event.Reset != nil {
+			monitor.before = monitor.gm[hit.index]
+			copy(monitor.xe, X)
+			return hit.t, monitor.xe, hit.index
+		}
+	}
+	copy(monitor.gm, monitor.gn)
+	return dense.T1, dense.X1, -1
+}
+
+// land replaces the state of the last occurrence by the state X computed by a
+// step that lands on the time of the event (see Event.Land).
+func (monitor *eventMonitor) land(X []float64) {
+	copy(monitor.occurrences[len(monitor.occurrences)-1].X, X)
+}
+
+// findRoot returns a root of the function g in the interval [a,b] using the
+// Brent method, where ga=g(a) and gb=g(b) have opposite signs (or gb=0). The
+// root is located with an absolute tolerance tol, and the returned value is
+// the end of the final bracket that has the same sign as g(b), so that the
+// crossing is effective at the returned value.
+func findRoot(g func(t float64) float64, a, b, ga, gb, tol float64) float64 {
+	const eps = 2.220446049250313e-16
+	if gb == 0 {
+		return b
+	}
+	positive := gb > 0
+	fa, fb := ga, gb
+	c, fc := b, fb
+	var d, e float64
+	for iter := 0; iter < 100; iter++ {
+		if (fb > 0 && fc > 0) || (fb < 0 && fc < 0) {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+		tol1 := 2*eps*math.Abs(b) + 0.5*tol
+		xm := 0.5 * (c - b)
+		if math.Abs(xm) <= tol1 || fb == 0 {
+			if fb == 0 || (fb > 0) == positive {
+				return b
+			}
+			return c
+		}
+		if math.Abs(e) >= tol1 && math.Abs(fa) > math.Abs(fb) {
+			// Attempt an inverse quadratic interpolation
+			var p, q float64
+			s := fb / fa
+			if a == c {
+				p = 2 * xm * s
+				q = 1 - s
+			} else {
+				q = fa / fc
+				r := fb / fc
+				p = s * (2*xm*q*(q-r) - (b-a)*(r-1))
+				q = (q - 1) * (r - 1) * (s - 1)
+			}
+			if p > 0 {
+				q = -q
+			}
+			p = math.Abs(p)
+			min1 := 3*xm*q - math.Abs(tol1*q)
+			min2 := math.Abs(e * q)
+			if 2*p < math.Min(min1, min2) {
+				e = d
+				d = p / q
+			} else {
+				// Interpolation failed, use a bisection
+				d = xm
+				e = d
+			}
+		} else {
+			// Bounds decreasing too slowly, use a bisection
+			d = xm
+			e = d
+		}
+		a, fa = b, fb
+		if math.Abs(d) > tol1 {
+			b += d
+		} else {
+			b += math.Copysign(tol1, xm)
+		}
+		fb = g(b)
+	}
+	return b
+}
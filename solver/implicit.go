@@ -0,0 +1,122 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gboulant/dingo-ode/linalg"
+)
+
+// JacobianFunction defines the function that computes the Jacobian matrix
+// J=∂f/∂X of the rate function f at (t,X). The matrix J is given by the solver
+// with the size n x n (where n is the size of X) and filled with zeros.
+type JacobianFunction func(t float64, X []float64, J *linalg.Matrix) error
+
+// MassMatrixFunction defines the function that computes the mass matrix
+// M(t,X) of a MassMatrixProblem. The matrix M is given by the solver with the
+// size n x n (where n is the size of X) and filled with zeros.
+type MassMatrixFunction func(t float64, X []float64, M *linalg.Matrix) error
+
+// MassMatrixProblem defines an ODE system in the mass matrix form:
+//
+//	M(t,X).dX/dt = f(t,X)
+//
+// which is the natural form of many mechanical and electrical models. The mass
+// matrix M can be singular: the zero rows of M then define algebraic equations
+// 0=f_i(t,X), i.e. the problem is a differential algebraic equation (DAE) that
+// should be of index 1. In this case, the initial state X0 should be
+// consistent, i.e. it should satisfy the algebraic equations.
+type MassMatrixProblem struct {
+	F InPlaceFunction    // the rate function f(t,X)
+	M MassMatrixFunction // the mass matrix M(t,X), nil for the identity matrix
+	// ConstantMass specifies that M does not depend on t and X, and then that
+	// it can be evaluated only once.
+	ConstantMass bool
+	// Jacobian is the optional Jacobian matrix of f, used by the Newton
+	// iterations of the implicit methods. If nil, the Jacobian matrix is
+	// approximated by finite differences.
+	Jacobian JacobianFunction
+	// Pattern is the optional sparsity pattern of the Jacobian matrix of f. If
+	// defined, the iteration matrix of the Newton iterations is factorized as
+	// a band matrix, with the bandwidths of the pattern (see
+	// linalg.Pattern.Bandwidths), and the finite differences evaluate f once
+	// per group of columns that do not share a row of the band. This is
+	// efficient for the narrow bands (e.g. the 1D problems of the method of
+	// lines), but not for the patterns with a few distant values (e.g. the
+	// periodic conditions or the 2D grids). The mass matrix should also fit
+	// in the band.
+	Pattern *linalg.Pattern
+}
+
+// ImplicitSolver is a Solver based on an implicit method, i.e. a method where
+// the state at the end of a step is defined by an equation solved with Newton
+// iterations. The implicit methods are suitable for the stiff problems, and
+// they can also solve the problems defined with a mass matrix.
+type ImplicitSolver interface {
+	Solver
+	// SolveMassMatrix is the same as the Solve function of the Solver, but for
+	// a problem defined with a mass matrix.
+	SolveMassMatrix(p MassMatrixProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveMassMatrixContext is the same as SolveMassMatrix, but the solving
+	// process is aborted when the context is canceled or when its deadline is
+	// exceeded.
+	SolveMassMatrixContext(ctx context.Context, p MassMatrixProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveDAE solves the semi-explicit DAE p from the differential components
+	// X0 at t0. The algebraic components are initialized from the guess Z0 so
+	// that the initial state (X0,Z) is consistent. The state of the solving
+	// process (e.g. the result and the recorded states) is the state (X,Z).
+	SolveDAE(p DAEProblem, t0 float64, X0, Z0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveDAEContext is the same as SolveDAE, but the solving process is
+	// aborted when the context is canceled or when its deadline is exceeded.
+	SolveDAEContext(ctx context.Context, p DAEProblem, t0 float64, X0, Z0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+}
+
+// implicitSolver implements the interface ImplicitSolver with the standard
+// Solve implementation of the StandardSolver and the workspace of an implicit
+// method.
+type implicitSolver struct {
+	StandardSolver
+	workspace *sdirkWorkspace
+}
+
+// Solve implements the Solver interface for the implicitSolver
+func (solver *implicitSolver) Solve(f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveContext(context.Background(), f, t0, X0, h, c, r, opts...)
+}
+
+// SolveContext implements the Solver interface for the implicitSolver
+func (solver *implicitSolver) SolveContext(ctx context.Context, f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	if f == nil {
+		return 0, newError(ErrInvalidInput, errors.New("the function f is not defined"), t0, X0, h)
+	}
+	return solver.SolveInPlaceContext(ctx, f.InPlace(), t0, X0, h, c, r, opts...)
+}
+
+// SolveInPlace implements the Solver interface for the implicitSolver
+func (solver *implicitSolver) SolveInPlace(f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveInPlaceContext(context.Background(), f, t0, X0, h, c, r, opts...)
+}
+
+// SolveInPlaceContext implements the Solver interface for the implicitSolver
+func (solver *implicitSolver) SolveInPlaceContext(ctx context.Context, f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveMassMatrixContext(ctx, MassMatrixProblem{F: f}, t0, X0, h, c, r, opts...)
+}
+
+// SolveMassMatrix implements the ImplicitSolver interface
+func (solver *implicitSolver) SolveMassMatrix(p MassMatrixProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveMassMatrixContext(context.Background(), p, t0, X0, h, c, r, opts...)
+}
+
+// SolveMassMatrixContext implements the ImplicitSolver interface. The rate
+// function f and the functions of the modes of a hybrid system share the same
+// mass matrix and Jacobian function.
+func (solver *implicitSolver) SolveMassMatrixContext(ctx context.Context, p MassMatrixProblem, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	if p.Pattern != nil && (p.Pattern.Rows != len(X0) || p.Pattern.Cols != len(X0)) {
+		err := fmt.Errorf("the pattern of the Jacobian matrix is %dx%d for a state of size %d", p.Pattern.Rows, p.Pattern.Cols, len(X0))
+		return 0, newError(ErrDimensionMismatch, err, t0, X0, h)
+	}
+	config := newSettings(opts...)
+	solver.workspace.setup(p, config, &solver.stats)
+	return solver.StandardSolver.SolveInPlaceContext(ctx, p.F, t0, X0, h, c, r, opts...)
+}
@@ -1,19 +1,14 @@
 package solver
 
-func eulerIteration(f Function, tn float64, Xn []float64, h float64) ([]float64, error) {
-	Xs := make([]float64, len(Xn))
-	slope, err := f(tn, Xn)
-	if err != nil {
-		return nil, err
-	}
+func eulerIteration(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error {
 	for i := 0; i < len(Xn); i++ {
-		Xs[i] = Xn[i] + h*slope[i]
+		Xs[i] = Xn[i] + h*Fn[i]
 	}
-	return Xs, nil
+	return nil
 }
 
 // NewEulerSolver returns a Solver that implements the Euler algorithm
 func NewEulerSolver() Solver {
-	solver := StandardSolver{iteration: eulerIteration}
+	solver := StandardSolver{method: "euler", iteration: eulerIteration}
 	return &solver
 }
@@ -0,0 +1,238 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gboulant/dingo-ode/linalg"
+)
+
+// radauWorkspace holds the vectors and the matrices used by the iteration of
+// a Radau IIA method, i.e. a fully implicit Runge-Kutta method that is
+// stiffly accurate. The s stages are coupled: the slopes K=(K_1,...,K_s) are
+// the solution of the s equations M(t_i,Y_i).K_i = f(t_i,Y_i), with Y_i = Xn +
+// h.sum_j(a_ij.K_j), solved with simplified Newton iterations whose iteration
+// matrix of size s.n is made of the blocks δ_ij.M - h.a_ij.J_i. The Jacobian
+// matrices J_i are first all evaluated at the beginning of the step, and then
+// at the states Y_i of the stages when the convergence is too slow (i.e. the
+// iterations become the exact Newton iterations). The workspace reuses the
+// mass matrix and the slope of the SDIRK workspace (the full matrix a is
+// stored in the tableau).
+//
+// If the problem defines the pattern of J, the unknowns of the iteration
+// matrix are ordered by component and then by stage (the index of K_i[l] is
+// l.s+i), so that the iteration matrix is a band matrix whose bandwidths are
+// s.kl+s-1 and s.ku+s-1.
+type radauWorkspace struct {
+	sdirkWorkspace
+	js  []linalg.Matrix // Jacobian matrices of the stages
+	g   linalg.Matrix   // iteration matrix of the coupled stages
+	lu  linalg.LU       // factorization of the iteration matrix
+	gb  linalg.Band     // iteration matrix in band storage
+	blu linalg.BandLU   // factorization of the band iteration matrix
+	r   []float64       // residuals of the stages
+	rb  []float64       // residuals ordered by component for the band matrix
+}
+
+// resize adapts the size of the workspace to a state of size n
+func (w *radauWorkspace) resize(n int) {
+	w.sdirkWorkspace.resize(n)
+	w.r = resize(w.r, len(w.tableau.c)*n)
+	w.rb = resize(w.rb, len(w.tableau.c)*n)
+	if len(w.js) != len(w.tableau.c) {
+		w.js = make([]linalg.Matrix, len(w.tableau.c))
+	}
+}
+
+// prepare computes and factorizes the iteration matrix of the coupled stages.
+// If exact is false, the Jacobian matrices of all the stages and the mass
+// matrix are evaluated at (tn,Xn). Otherwise, they are evaluated at the
+// current states of the stages.
+func (w *radauWorkspace) prepare(f InPlaceFunction, tn float64, Xn []float64, h float64, exact bool) error {
+	n, s := len(Xn), len(w.tableau.c)
+	t, Y := tn, Xn
+	for i := 0; i < s; i++ {
+		if exact {
+			t, Y = tn+w.tableau.c[i]*h, w.y
+			w.stage(i, Xn, h, Y)
+		}
+		if exact || i == 0 {
+			if err := w.evalJacobian(f, t, Y); err != nil {
+				return err
+			}
+		}
+		w.js[i].Copy(&w.j)
+	}
+	if err := w.evalMass(t, Y); err != nil {
+		return err
+	}
+
+	w.stats.LUFactorizations++
+	if w.pattern != nil {
+		w.gb.Resize(s*n, s*w.kl+s-1, s*w.ku+s-1)
+		for i := 0; i < s; i++ {
+			for j := 0; j < s; j++ {
+				coef := h * w.tableau.a[i][j]
+				for col := 0; col < n; col++ {
+					lo, hi := w.band(col, n)
+					for row := lo; row <= hi; row++ {
+						v := -coef * w.js[i].At(row, col)
+						if i == j {
+							v += w.m.At(row, col)
+						}
+						w.gb.Set(row*s+i, col*s+j, v)
+					}
+				}
+			}
+		}
+		if err := w.blu.Factorize(&w.gb); err != nil {
+			return newError(ErrNewtonDivergence, err, tn, Xn, h)
+		}
+		return nil
+	}
+	w.g.Resize(s*n, s*n)
+	for i := 0; i < s; i++ {
+		for j := 0; j < s; j++ {
+			coef := h * w.tableau.a[i][j]
+			for row := 0; row < n; row++ {
+				for col := 0; col < n; col++ {
+					v := -coef * w.js[i].At(row, col)
+					if i == j {
+						v += w.m.At(row, col)
+					}
+					w.g.Set(i*n+row, j*n+col, v)
+				}
+			}
+		}
+	}
+	if err := w.lu.Factorize(&w.g); err != nil {
+		return newError(ErrNewtonDivergence, err, tn, Xn, h)
+	}
+	return nil
+}
+
+// solve solves the system G.x = r with the factorized iteration matrix G of
+// the coupled stages. The residuals r are overwritten by the solution x.
+func (w *radauWorkspace) solve(r []float64) {
+	if w.pattern == nil {
+		w.lu.Solve(r)
+		return
+	}
+	s := len(w.tableau.c)
+	n := len(r) / s
+	for i := 0; i < s; i++ {
+		for l := 0; l < n; l++ {
+			w.rb[l*s+i] = r[i*n+l]
+		}
+	}
+	w.blu.Solve(w.rb)
+	for i := 0; i < s; i++ {
+		for l := 0; l < n; l++ {
+			r[i*n+l] = w.rb[l*s+i]
+		}
+	}
+}
+
+// stage computes in Y the state Xn + h.sum_j(a_ij.K_j) of the stage i
+func (w *radauWorkspace) stage(i int, Xn []float64, h float64, Y []float64) {
+	for l := range Y {
+		s := Xn[l]
+		for j, k := range w.k {
+			s += h * w.tableau.a[i][j] * k[l]
+		}
+		Y[l] = s
+	}
+}
+
+// iteration implements the Iteration of the Radau IIA method
+func (w *radauWorkspace) iteration(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error {
+	n := len(Xn)
+	w.resize(n)
+	tableau := w.tableau
+	last := len(tableau.c) - 1
+	// The initial guess of the slopes is the slope at the beginning of the step
+	for i := range w.k {
+		copy(w.k[i], Fn)
+	}
+	if err := w.prepare(f, tn, Xn, h, false); err != nil {
+		return err
+	}
+	Y := w.y
+	previous := math.Inf(1) // norm of the previous correction
+	for iteration := 0; ; iteration++ {
+		if iteration == w.maxIterations {
+			err := fmt.Errorf("no convergence after %d iterations", w.maxIterations)
+			return newError(ErrNewtonDivergence, err, tn, Xn, h)
+		}
+
+		// Residuals M(t_i,Y_i).K_i - f(t_i,Y_i) of the stages
+		for i, c := range tableau.c {
+			t := tn + c*h
+			R := w.r[i*n : (i+1)*n]
+			w.stage(i, Xn, h, Y)
+			if err := f(t, Y, R); err != nil {
+				return err
+			}
+			if w.mass == nil {
+				for l := range R {
+					R[l] = w.k[i][l] - R[l]
+				}
+				continue
+			}
+			if err := w.evalMass(t, Y); err != nil {
+				return err
+			}
+			w.m.MulVec(w.k[i], w.fd)
+			for l := range R {
+				R[l] = w.fd[l] - R[l]
+			}
+		}
+		w.solve(w.r)
+
+		norm := 0.0 // norm of the correction relatively to the tolerance
+		for i, k := range w.k {
+			for l := range k {
+				dk := w.r[i*n+l]
+				k[l] -= dk
+				norm = math.Max(norm, math.Abs(h*dk)/(w.tolerance*(1+math.Abs(Xn[l]))))
+			}
+		}
+		if norm <= 1 {
+			break
+		}
+		if norm > 0.1*previous {
+			if err := w.prepare(f, tn, Xn, h, true); err != nil {
+				return err
+			}
+		}
+		previous = norm
+	}
+
+	// The method is stiffly accurate: the state at the end of the step is the
+	// state of the last stage, and the slope is the slope of the last stage.
+	w.stage(last, Xn, h, Xs)
+	w.cached, w.tcached = true, tn+h
+	return nil
+}
+
+// NewRadau5Solver returns an ImplicitSolver that implements the 3 stages
+// Radau IIA method. The method is of order 5, L-stable and stiffly accurate,
+// and it is well suited for the stiff problems and the DAE of index 1 (the
+// algebraic components are also of order 5).
+func NewRadau5Solver() ImplicitSolver {
+	s6 := math.Sqrt(6)
+	tableau := sdirkTableau{
+		a: [][]float64{
+			{(88 - 7*s6) / 360, (296 - 169*s6) / 1800, (-2 + 3*s6) / 225},
+			{(296 + 169*s6) / 1800, (88 + 7*s6) / 360, (-2 - 3*s6) / 225},
+			{(16 - s6) / 36, (16 + s6) / 36, 1.0 / 9},
+		},
+		c: []float64{(4 - s6) / 10, (4 + s6) / 10, 1},
+	}
+	w := &radauWorkspace{sdirkWorkspace: sdirkWorkspace{tableau: tableau}}
+	solver := implicitSolver{
+		StandardSolver: StandardSolver{method: "radau5", iteration: w.iteration, slope: w.slope},
+		workspace:      &w.sdirkWorkspace,
+	}
+	return &solver
+}
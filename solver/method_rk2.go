@@ -1,31 +1,35 @@
 package solver
 
-func rk2Iteration(f Function, tn float64, Xn []float64, h float64) ([]float64, error) {
+// rk2Workspace holds the vectors used by the RK2 iteration
+type rk2Workspace struct {
+	slope []float64
+	xm    []float64
+}
+
+func (w *rk2Workspace) iteration(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error {
+	w.slope = resize(w.slope, len(Xn))
+	w.xm = resize(w.xm, len(Xn))
+
 	// Step 1
-	Xm := make([]float64, len(Xn))
-	slope, err := f(tn, Xn)
-	if err != nil {
-		return nil, err
-	}
 	for i := 0; i < len(Xn); i++ {
-		Xm[i] = Xn[i] + h*slope[i]/2
+		w.xm[i] = Xn[i] + h*Fn[i]/2
 	}
 
 	// Step 2
-	Xs := make([]float64, len(Xn))
-	slope, err = f(tn+h/2, Xm)
+	err := f(tn+h/2, w.xm, w.slope)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	for i := 0; i < len(Xn); i++ {
-		Xs[i] = Xn[i] + h*slope[i]
+		Xs[i] = Xn[i] + h*w.slope[i]
 	}
 
-	return Xs, nil
+	return nil
 }
 
 // NewRK2Solver returns a Solver that implements the Euler algorithm
 func NewRK2Solver() Solver {
-	solver := StandardSolver{iteration: rk2Iteration}
+	w := rk2Workspace{}
+	solver := StandardSolver{method: "rk2", iteration: w.iteration}
 	return &solver
 }
@@ -1,67 +1,74 @@
 package solver
 
-func rk4Iteration(f Function, tn float64, Xn []float64, h float64) ([]float64, error) {
-	// Step 1: k1 = h*f(tn, Xn)
-	slope, err := f(tn, Xn)
-	if err != nil {
-		return nil, err
-	}
-	k1 := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
-		k1[i] = h * slope[i]
+// rk4Workspace holds the vectors used by the RK4 iteration
+type rk4Workspace struct {
+	slope          []float64
+	k1, k2, k3, k4 []float64
+	xm             []float64
+}
+
+func (w *rk4Workspace) iteration(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error {
+	n := len(Xn)
+	w.slope = resize(w.slope, n)
+	w.k1 = resize(w.k1, n)
+	w.k2 = resize(w.k2, n)
+	w.k3 = resize(w.k3, n)
+	w.k4 = resize(w.k4, n)
+	w.xm = resize(w.xm, n)
+	slope, k1, k2, k3, k4, Xm := w.slope, w.k1, w.k2, w.k3, w.k4, w.xm
+
+	// Step 1: k1 = h*f(tn, Xn), where f(tn, Xn) is given by Fn
+	for i := 0; i < n; i++ {
+		k1[i] = h * Fn[i]
 	}
 
 	// Step 2: k2 = h*f(tn+h/2, Xn+k1/2). We define Xm as the mediate point Xn+k1/2.
-	Xm := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		Xm[i] = Xn[i] + k1[i]/2
 	}
-	slope, err = f(tn+h/2, Xm)
+	err := f(tn+h/2, Xm, slope)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	k2 := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		k2[i] = h * slope[i]
 	}
 
 	// Step 3: k3 = h*f(tn+h/2, Xn+k2/2). We define Xm as the mediate point Xn+k2/2.
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		Xm[i] = Xn[i] + k2[i]/2
 	}
-	slope, err = f(tn+h/2, Xm)
+	err = f(tn+h/2, Xm, slope)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	k3 := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		k3[i] = h * slope[i]
 	}
 
 	// Step 4: k4 = h*f(tn+h, Xn+k3). We define Xm as the mediate point Xn+k3.
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		Xm[i] = Xn[i] + k3[i]
 	}
-	slope, err = f(tn+h, Xm)
+	err = f(tn+h, Xm, slope)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	k4 := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		k4[i] = h * slope[i]
 	}
 
 	// Computing the weigth average final value Xn+1 (denoted to as Xs below)
-	Xs := make([]float64, len(Xn))
-	for i := 0; i < len(Xn); i++ {
+	for i := 0; i < n; i++ {
 		Xs[i] = Xn[i] + (k1[i]+2*k2[i]+2*k3[i]+k4[i])/6
 	}
 
-	return Xs, nil
+	return nil
 }
 
 // NewRK4Solver returns a Solver that implements the Euler algorithm
 func NewRK4Solver() Solver {
-	solver := StandardSolver{iteration: rk4Iteration}
+	w := rk4Workspace{}
+	solver := StandardSolver{method: "rk4", iteration: w.iteration}
 	return &solver
 }
@@ -0,0 +1,167 @@
+package solver
+
+import (
+	"errors"
+)
+
+// rknWorkspace holds the vectors used by the Runge-Kutta-Nyström iterations.
+// The iterations are applied to the first order system Y=(X,V) given by the
+// function FirstOrder of a SecondOrderFunction, whose slope (V,g) is used to
+// evaluate the acceleration g.
+type rknWorkspace struct {
+	y, slope []float64
+	k        [][]float64 // accelerations of the stages
+}
+
+// setup allocates the workspace for a system of n positions and the given
+// number of stages, and checks that the slope Fn at the beginning of the step
+// has the structure of a second order system (dX/dt=V).
+func (w *rknWorkspace) setup(Yn, Fn []float64, stages int) (int, error) {
+	n := len(Yn) / 2
+	if 2*n != len(Yn) || !equal(Fn[:n], Yn[n:]) {
+		return 0, errors.New("the Nyström methods require the first order system of a SecondOrderFunction")
+	}
+	w.y = resize(w.y, 2*n)
+	w.slope = resize(w.slope, 2*n)
+	if len(w.k) != stages {
+		w.k = make([][]float64, stages)
+	}
+	for i := range w.k {
+		w.k[i] = resize(w.k[i], n)
+	}
+	copy(w.k[0], Fn[n:])
+	return n, nil
+}
+
+// acceleration evaluates in k the acceleration g at the state w.y, using the
+// first order function f
+func (w *rknWorkspace) acceleration(f InPlaceFunction, t float64, k []float64) error {
+	if err := f(t, w.y, w.slope); err != nil {
+		return err
+	}
+	copy(k, w.slope[len(k):])
+	return nil
+}
+
+// rkn4Iteration implements the iteration of the Runge-Kutta-Nyström method of
+// order 4 for the general second order systems X''=g(t,X,V):
+//
+//	k1 = g(tn, Xn, Vn)
+//	k2 = g(tn+h/2, Xn+h/2*Vn+h²/8*k1, Vn+h/2*k1)
+//	k3 = g(tn+h/2, Xn+h/2*Vn+h²/8*k1, Vn+h/2*k2)
+//	k4 = g(tn+h, Xn+h*Vn+h²/2*k3, Vn+h*k3)
+//	Xs = Xn + h*Vn + h²/6*(k1+k2+k3)
+//	Vs = Vn + h/6*(k1+2*k2+2*k3+k4)
+func (w *rknWorkspace) rkn4Iteration(f InPlaceFunction, tn float64, Yn []float64, Fn []float64, h float64, Ys []float64) error {
+	n, err := w.setup(Yn, Fn, 4)
+	if err != nil {
+		return err
+	}
+	Xn, Vn := Yn[:n], Yn[n:]
+	k1, k2, k3, k4 := w.k[0], w.k[1], w.k[2], w.k[3]
+	X, V := w.y[:n], w.y[n:]
+
+	for i := 0; i < n; i++ {
+		X[i] = Xn[i] + h/2*Vn[i] + h*h/8*k1[i]
+		V[i] = Vn[i] + h/2*k1[i]
+	}
+	if err := w.acceleration(f, tn+h/2, k2); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		V[i] = Vn[i] + h/2*k2[i]
+	}
+	if err := w.acceleration(f, tn+h/2, k3); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		X[i] = Xn[i] + h*Vn[i] + h*h/2*k3[i]
+		V[i] = Vn[i] + h*k3[i]
+	}
+	if err := w.acceleration(f, tn+h, k4); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		Ys[i] = Xn[i] + h*Vn[i] + h*h/6*(k1[i]+k2[i]+k3[i])
+		Ys[n+i] = Vn[i] + h/6*(k1[i]+2*k2[i]+2*k3[i]+k4[i])
+	}
+	return nil
+}
+
+// Coefficients of the Runge-Kutta-Nyström method of order 6 of Dormand, El
+// Mikkawy and Prince (1987): the nodes c, the matrix a of the positions of the
+// stages, and the weights b and bp of the positions and the velocities.
+var (
+	dprkn6C = [6]float64{0, 1. / 10, 3. / 10, 7. / 10, 17. / 25, 1}
+	dprkn6A = [6][5]float64{
+		{},
+		{1. / 200},
+		{-1. / 2200, 1. / 22},
+		{637. / 6600, -7. / 110, 7. / 33},
+		{225437. / 1968750, -30073. / 281250, 65569. / 281250, -9367. / 984375},
+		{151. / 2142, 5. / 116, 385. / 1368, 55. / 168, -6250. / 28101},
+	}
+	dprkn6B  = [6]float64{151. / 2142, 5. / 116, 385. / 1368, 55. / 168, -6250. / 28101, 0}
+	dprkn6BP = [6]float64{151. / 2142, 25. / 522, 275. / 684, 275. / 252, -78125. / 112404, 1. / 12}
+)
+
+// dprkn6Iteration implements the iteration of the Runge-Kutta-Nyström method
+// DPRKN6 for the special second order systems X''=g(t,X), whose acceleration
+// does not depend on the velocity. The stages are:
+//
+//	k_i = g(tn+c_i*h, Xn+c_i*h*Vn+h²*sum(a_ij*k_j))
+//	Xs  = Xn + h*Vn + h²*sum(b_i*k_i)
+//	Vs  = Vn + h*sum(bp_i*k_i)
+func (w *rknWorkspace) dprkn6Iteration(f InPlaceFunction, tn float64, Yn []float64, Fn []float64, h float64, Ys []float64) error {
+	n, err := w.setup(Yn, Fn, 6)
+	if err != nil {
+		return err
+	}
+	Xn, Vn := Yn[:n], Yn[n:]
+	X, V := w.y[:n], w.y[n:]
+	copy(V, Vn) // the acceleration does not depend on the velocity
+	for s := 1; s < 6; s++ {
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < s; j++ {
+				sum += dprkn6A[s][j] * w.k[j][i]
+			}
+			X[i] = Xn[i] + dprkn6C[s]*h*Vn[i] + h*h*sum
+		}
+		if err := w.acceleration(f, tn+dprkn6C[s]*h, w.k[s]); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < n; i++ {
+		sx, sv := 0.0, 0.0
+		for s := 0; s < 6; s++ {
+			sx += dprkn6B[s] * w.k[s][i]
+			sv += dprkn6BP[s] * w.k[s][i]
+		}
+		Ys[i] = Xn[i] + h*Vn[i] + h*h*sx
+		Ys[n+i] = Vn[i] + h*sv
+	}
+	return nil
+}
+
+// NewRKN4Solver returns a Solver that implements the Runge-Kutta-Nyström
+// method of order 4 for the general second order systems X''=g(t,X,V). The
+// solver should be used with the first order system given by the function
+// FirstOrder of a SecondOrderFunction, whose state is Y=(X,V).
+func NewRKN4Solver() Solver {
+	w := rknWorkspace{}
+	solver := StandardSolver{method: "rkn4", iteration: w.rkn4Iteration}
+	return &solver
+}
+
+// NewDPRKN6Solver returns a Solver that implements the Runge-Kutta-Nyström
+// method of order 6 of Dormand and Prince for the special second order systems
+// X''=g(t,X), whose acceleration does not depend on the velocity V (e.g. the
+// conservative mechanical systems). The solver should be used with the first
+// order system given by the function FirstOrder of a SecondOrderFunction,
+// whose state is Y=(X,V).
+func NewDPRKN6Solver() Solver {
+	w := rknWorkspace{}
+	solver := StandardSolver{method: "dprkn6", iteration: w.dprkn6Iteration}
+	return &solver
+}
@@ -0,0 +1,385 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gboulant/dingo-ode/linalg"
+)
+
+// sdirkTableau defines the coefficients of a singly diagonally implicit
+// Runge-Kutta method (SDIRK) that is stiffly accurate, i.e. the diagonal
+// values of the matrix a are all equal to gamma, and the weights of the stages
+// are the last row of a (the state at the end of the step is the last stage).
+// The tableau also stores the full matrix a of the Radau IIA methods, for
+// which gamma is not used.
+type sdirkTableau struct {
+	a     [][]float64
+	c     []float64
+	gamma float64
+}
+
+// sdirkWorkspace holds the vectors and the matrices used by the SDIRK
+// iteration. The stages are computed for the problems in the mass matrix form
+// M(t,X).dX/dt = f(t,X): the slope K of a stage at Y = B + h.gamma.K (where B
+// depends on the previous stages) is the solution of M(t,Y).K = f(t,Y), solved
+// with simplified Newton iterations whose iteration matrix is M - h.gamma.J,
+// with J the Jacobian matrix of f at the beginning of the step. If the problem
+// defines the pattern of J, the iteration matrix is a band matrix.
+type sdirkWorkspace struct {
+	tableau       sdirkTableau
+	mass          MassMatrixFunction
+	constantMass  bool
+	jacobian      JacobianFunction
+	pattern       *linalg.Pattern // pattern of J (nil for a dense iteration matrix)
+	kl, ku        int             // bandwidths of the pattern
+	tolerance     float64
+	maxIterations int
+	stats         *Stats
+
+	m, j, g   linalg.Matrix // mass matrix, Jacobian matrix and iteration matrix
+	lu        linalg.LU     // factorization of the iteration matrix
+	gb        linalg.Band   // iteration matrix in band storage
+	blu       linalg.BandLU // factorization of the band iteration matrix
+	massReady bool          // true if the constant mass matrix is evaluated
+	k         [][]float64   // slopes of the stages
+	b, y, r   []float64     // base point, state and residual of a stage
+	x0, f0    []float64     // state and rate of the Jacobian matrix
+	fd        []float64     // workspace for the finite differences
+	cached    bool          // true if the slope at the end of the last step is k[s-1]
+	tcached   float64       // time of the end of the last step
+}
+
+// setup prepares the workspace for the solving of the problem p
+func (w *sdirkWorkspace) setup(p MassMatrixProblem, config settings, stats *Stats) {
+	w.mass = p.M
+	w.constantMass = p.ConstantMass
+	w.jacobian = p.Jacobian
+	w.pattern = p.Pattern
+	if w.pattern != nil {
+		w.kl, w.ku = w.pattern.Bandwidths()
+	}
+	w.tolerance = config.newtonTolerance
+	w.maxIterations = config.newtonIterations
+	w.stats = stats
+	w.massReady = false
+	w.cached = false
+}
+
+// resize adapts the size of the workspace to a state of size n
+func (w *sdirkWorkspace) resize(n int) {
+	if len(w.k) != len(w.tableau.c) {
+		w.k = make([][]float64, len(w.tableau.c))
+	}
+	for i := range w.k {
+		w.k[i] = resize(w.k[i], n)
+	}
+	w.b = resize(w.b, n)
+	w.y = resize(w.y, n)
+	w.r = resize(w.r, n)
+	w.x0 = resize(w.x0, n)
+	w.f0 = resize(w.f0, n)
+	w.fd = resize(w.fd, n)
+	if w.m.Rows != n {
+		w.massReady = false
+	}
+}
+
+// evalMass computes the mass matrix at (t,X), unless it is constant and
+// already computed.
+func (w *sdirkWorkspace) evalMass(t float64, X []float64) error {
+	if w.massReady && (w.mass == nil || w.constantMass) {
+		return nil
+	}
+	n := len(X)
+	w.m.Resize(n, n)
+	if w.mass == nil {
+		for i := 0; i < n; i++ {
+			w.m.Set(i, i, 1)
+		}
+	} else if err := w.mass(t, X, &w.m); err != nil {
+		return err
+	}
+	if w.pattern != nil {
+		for row := 0; row < n; row++ {
+			for col := 0; col < n; col++ {
+				if (col-row < -w.kl || col-row > w.ku) && w.m.At(row, col) != 0 {
+					err := fmt.Errorf("the value (%d,%d) of the mass matrix is outside the band of the Jacobian pattern", row, col)
+					return newError(ErrDimensionMismatch, err, t, X, 0)
+				}
+			}
+		}
+	}
+	w.massReady = true
+	return nil
+}
+
+// evalJacobian computes the Jacobian matrix of f at (t,X), using the Jacobian
+// function of the problem or finite differences.
+func (w *sdirkWorkspace) evalJacobian(f InPlaceFunction, t float64, X []float64) error {
+	n := len(X)
+	w.j.Resize(n, n)
+	w.stats.JacobianEvaluations++
+	if w.jacobian != nil {
+		return w.jacobian(t, X, &w.j)
+	}
+
+	f0, Y := w.f0, w.x0
+	if err := f(t, X, f0); err != nil {
+		return err
+	}
+	copy(Y, X)
+	if w.pattern != nil {
+		return w.evalBandJacobian(f, t, X, Y)
+	}
+	for col := 0; col < n; col++ {
+		delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(Y[col]))
+		x := Y[col]
+		Y[col] = x + delta
+		if err := f(t, Y, w.fd); err != nil {
+			return err
+		}
+		for row := 0; row < n; row++ {
+			w.j.Set(row, col, (w.fd[row]-f0[row])/delta)
+		}
+		Y[col] = x
+	}
+	return nil
+}
+
+// evalBandJacobian computes the Jacobian matrix of f at (t,X) by finite
+// differences, where Y is a copy of X and f0 the rate at (t,X). The columns
+// col, col+width, col+2*width, ... (width=kl+ku+1) change distinct rows of
+// the band, and then they are perturbed together.
+func (w *sdirkWorkspace) evalBandJacobian(f InPlaceFunction, t float64, X, Y []float64) error {
+	n, f0 := len(X), w.f0
+	width := w.kl + w.ku + 1
+	for first := 0; first < width && first < n; first++ {
+		for col := first; col < n; col += width {
+			Y[col] += math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(X[col]))
+		}
+		if err := f(t, Y, w.fd); err != nil {
+			return err
+		}
+		for col := first; col < n; col += width {
+			delta := Y[col] - X[col]
+			lo, hi := w.band(col, n)
+			for row := lo; row <= hi; row++ {
+				w.j.Set(row, col, (w.fd[row]-f0[row])/delta)
+			}
+			Y[col] = X[col]
+		}
+	}
+	return nil
+}
+
+// band returns the first and the last rows of the band in the column col of a
+// matrix of size n
+func (w *sdirkWorkspace) band(col, n int) (int, int) {
+	lo, hi := col-w.ku, col+w.kl
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > n-1 {
+		hi = n - 1
+	}
+	return lo, hi
+}
+
+// prepare computes and factorizes the iteration matrix M - coef.J at (t,X)
+func (w *sdirkWorkspace) prepare(f InPlaceFunction, t float64, X []float64, coef float64) error {
+	if err := w.evalJacobian(f, t, X); err != nil {
+		return err
+	}
+	if err := w.evalMass(t, X); err != nil {
+		return err
+	}
+	n := len(X)
+	w.stats.LUFactorizations++
+	if w.pattern != nil {
+		w.gb.Resize(n, w.kl, w.ku)
+		for col := 0; col < n; col++ {
+			lo, hi := w.band(col, n)
+			for row := lo; row <= hi; row++ {
+				w.gb.Set(row, col, w.m.At(row, col)-coef*w.j.At(row, col))
+			}
+		}
+		if err := w.blu.Factorize(&w.gb); err != nil {
+			return newError(ErrNewtonDivergence, err, t, X, 0)
+		}
+		return nil
+	}
+	w.g.Resize(n, n)
+	for i := range w.g.Data {
+		w.g.Data[i] = w.m.Data[i] - coef*w.j.Data[i]
+	}
+	if err := w.lu.Factorize(&w.g); err != nil {
+		return newError(ErrNewtonDivergence, err, t, X, 0)
+	}
+	return nil
+}
+
+// solve solves the system G.x = R with the factorized iteration matrix G. The
+// vector R is overwritten by the solution x.
+func (w *sdirkWorkspace) solve(R []float64) {
+	if w.pattern != nil {
+		w.blu.Solve(R)
+		return
+	}
+	w.lu.Solve(R)
+}
+
+// newton solves M(t,Y).K = f(t,Y) with Y = B + coef.K, using the simplified
+// Newton iterations with the factorized iteration matrix. The iteration matrix
+// is updated at the current Y when the convergence is too slow. The slice K
+// contains the initial guess and is overwritten by the solution.
+func (w *sdirkWorkspace) newton(f InPlaceFunction, t float64, B []float64, coef float64, K []float64) error {
+	Y, R := w.y, w.r
+	previous := math.Inf(1) // norm of the previous correction
+	for iteration := 0; iteration < w.maxIterations; iteration++ {
+		for i := range Y {
+			Y[i] = B[i] + coef*K[i]
+		}
+		if err := f(t, Y, R); err != nil {
+			return err
+		}
+
+		// Residual M(t,Y).K - f(t,Y), and Newton correction -G^-1.R
+		if w.mass == nil {
+			for i := range R {
+				R[i] = K[i] - R[i]
+			}
+		} else {
+			if err := w.evalMass(t, Y); err != nil {
+				return err
+			}
+			w.m.MulVec(K, w.fd)
+			for i := range R {
+				R[i] = w.fd[i] - R[i]
+			}
+		}
+		w.solve(R)
+
+		norm := 0.0 // norm of the correction relatively to the tolerance
+		for i := range K {
+			K[i] -= R[i]
+			norm = math.Max(norm, math.Abs(coef*R[i])/(w.tolerance*(1+math.Abs(Y[i]))))
+		}
+		if norm <= 1 {
+			return nil
+		}
+		if norm > 0.1*previous {
+			for i := range Y {
+				Y[i] = B[i] + coef*K[i]
+			}
+			if err := w.prepare(f, t, Y, coef); err != nil {
+				return err
+			}
+		}
+		previous = norm
+	}
+	err := fmt.Errorf("no convergence after %d iterations", w.maxIterations)
+	return newError(ErrNewtonDivergence, err, t, Y, 0)
+}
+
+// iteration implements the Iteration of the SDIRK method
+func (w *sdirkWorkspace) iteration(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error {
+	n := len(Xn)
+	w.resize(n)
+	tableau := w.tableau
+	coef := h * tableau.gamma
+	if err := w.prepare(f, tn, Xn, coef); err != nil {
+		return err
+	}
+
+	for i := range tableau.c {
+		for l := 0; l < n; l++ {
+			s := Xn[l]
+			for j := 0; j < i; j++ {
+				s += h * tableau.a[i][j] * w.k[j][l]
+			}
+			w.b[l] = s
+		}
+		// The initial guess is the slope of the previous stage
+		if i == 0 {
+			copy(w.k[i], Fn)
+		} else {
+			copy(w.k[i], w.k[i-1])
+		}
+		if err := w.newton(f, tn+tableau.c[i]*h, w.b, coef, w.k[i]); err != nil {
+			return err
+		}
+	}
+
+	// The method is stiffly accurate: the state at the end of the step is the
+	// state of the last stage, and the slope is the slope of the last stage.
+	last := w.k[len(w.k)-1]
+	for l := 0; l < n; l++ {
+		Xs[l] = w.b[l] + coef*last[l]
+	}
+	w.cached, w.tcached = true, tn+h
+	return nil
+}
+
+// slope computes the slope dX/dt at (t,X). The slope at the end of a step is
+// the slope of the last stage. Elsewhere, the slope is the solution of
+// M(t,X).dX/dt = f(t,X), computed as the slope of an implicit Euler step of
+// very small size, so that the slope of the algebraic components of a DAE is
+// also defined (the derivative of the algebraic equations).
+func (w *sdirkWorkspace) slope(f InPlaceFunction, t float64, X, dXdt []float64) error {
+	if w.cached && t == w.tcached {
+		w.cached = false
+		copy(dXdt, w.k[len(w.k)-1])
+		return nil
+	}
+	w.cached = false
+	if w.mass == nil {
+		return f(t, X, dXdt)
+	}
+
+	w.resize(len(X))
+	delta := math.Sqrt(2.220446049250313e-16) * math.Max(1, math.Abs(t))
+	if err := w.prepare(f, t, X, delta); err != nil {
+		return err
+	}
+	for i := range dXdt {
+		dXdt[i] = 0
+	}
+	copy(w.b, X)
+	return w.newton(f, t+delta, w.b, delta, dXdt)
+}
+
+// newImplicitSolver creates an ImplicitSolver for the SDIRK method defined by
+// the given tableau.
+func newImplicitSolver(method string, tableau sdirkTableau) ImplicitSolver {
+	w := &sdirkWorkspace{tableau: tableau}
+	solver := implicitSolver{
+		StandardSolver: StandardSolver{method: method, iteration: w.iteration, slope: w.slope},
+		workspace:      w,
+	}
+	return &solver
+}
+
+// NewImplicitEulerSolver returns an ImplicitSolver that implements the
+// implicit (backward) Euler method. The method is of order 1 and L-stable.
+func NewImplicitEulerSolver() ImplicitSolver {
+	tableau := sdirkTableau{
+		a:     [][]float64{{1}},
+		c:     []float64{1},
+		gamma: 1,
+	}
+	return newImplicitSolver("implicit-euler", tableau)
+}
+
+// NewSDIRK2Solver returns an ImplicitSolver that implements the 2 stages SDIRK
+// method of Alexander. The method is of order 2, L-stable and stiffly
+// accurate.
+func NewSDIRK2Solver() ImplicitSolver {
+	gamma := 1 - 1/math.Sqrt2
+	tableau := sdirkTableau{
+		a:     [][]float64{{gamma, 0}, {1 - gamma, gamma}},
+		c:     []float64{gamma, 1},
+		gamma: gamma,
+	}
+	return newImplicitSolver("sdirk2", tableau)
+}
@@ -0,0 +1,38 @@
+package solver
+
+// SecondOrderFunction defines the function g of a second order ODE system,
+// i.e. the function that implements the acceleration of the system:
+//
+//	d2X/dt2 = g(t,X,V), where V = dX/dt
+//
+// which is the natural form of the mechanical models. The acceleration is
+// written in the slice A (of same length as X). The function should not keep
+// a reference to X, V or A, whose memory is reused by the solver.
+type SecondOrderFunction func(t float64, X, V, A []float64) error
+
+// FirstOrder returns the InPlaceFunction of the equivalent first order system,
+// whose state Y=(X,V) is the concatenation of the positions X and the
+// velocities V (see SecondOrderState):
+//
+//	dX/dt = V
+//	dV/dt = g(t,X,V)
+//
+// The first order system can be solved with any Solver. The Nyström solvers
+// (see NewRKN4Solver and NewDPRKN6Solver) exploit this structure to be more
+// accurate with fewer evaluations of g.
+func (g SecondOrderFunction) FirstOrder() InPlaceFunction {
+	return func(t float64, Y []float64, dYdt []float64) error {
+		n := len(Y) / 2
+		copy(dYdt[:n], Y[n:])
+		return g(t, Y[:n], Y[n:], dYdt[n:])
+	}
+}
+
+// SecondOrderState returns the state Y=(X,V) of the first order system
+// equivalent to a second order system, from the positions X and the
+// velocities V.
+func SecondOrderState(X, V []float64) []float64 {
+	Y := make([]float64, 0, len(X)+len(V))
+	Y = append(Y, X...)
+	return append(Y, V...)
+}
@@ -0,0 +1,187 @@
+package solver
+
+import "math"
+
+// DefaultCheckInterval is the default number of iterations between two checks
+// of the context of a solving process (see CheckEvery).
+const DefaultCheckInterval uint64 = 100
+
+// defaultNewtonTolerance and defaultNewtonIterations are the default
+// parameters of the Newton iterations of the implicit methods (see Newton).
+const (
+	defaultNewtonTolerance  = 1e-10
+	defaultNewtonIterations = 10
+)
+
+// defaultZenoResets is the default maximal number of resets in a time window
+// of the size of the step size (see ZenoLimit).
+const defaultZenoResets = 100
+
+// settings gathers the optional parameters of a solving process. The settings
+// are initialized with default values and then modified by the Option functions
+// given to the Solve function.
+type settings struct {
+	checkInterval      uint64
+	resume             *Checkpoint
+	checkpointInterval uint64
+	checkpointHandler  func(cp Checkpoint) error
+	outputTimes        []float64
+	events             []Event
+	modes              []InPlaceFunction
+	zenoResets         int
+	zenoWindow         float64
+	breakpoints        []float64
+	maxSteps           uint64
+	newtonTolerance    float64
+	newtonIterations   int
+}
+
+// Option defines a function that modifies the settings of a solving process.
+// The options can be given as additional arguments of the Solve function of a
+// Solver to customize the solving process.
+type Option func(s *settings)
+
+// newSettings returns the default settings modified by the given options
+func newSettings(opts ...Option) settings {
+	s := settings{
+		checkInterval:    DefaultCheckInterval,
+		zenoResets:       defaultZenoResets,
+		newtonTolerance:  defaultNewtonTolerance,
+		newtonIterations: defaultNewtonIterations,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// CheckEvery specifies the number of iterations between two checks of the
+// context given to SolveContext (cancellation or deadline). A value of 1 checks
+// the context at each iteration, which gives the fastest reaction to a
+// cancellation at the price of a small overhead for cheap functions. A value
+// of 0 is considered as 1.
+func CheckEvery(n uint64) Option {
+	return func(s *settings) {
+		if n == 0 {
+			n = 1
+		}
+		s.checkInterval = n
+	}
+}
+
+// ResumeFrom specifies that the solving process should be resumed from the
+// given checkpoint. The initial conditions (t0,X0) and the step size h given to
+// the Solve function are then replaced by the values of the checkpoint, and
+// the initial state is not recorded again (it was recorded before the
+// checkpoint). If the recorder implements the RecorderSeeker interface, it is
+// positioned at the checkpoint before the first iteration. If the checkpoint
+// holds the state of the Process (see CheckpointEvery), the controllers
+// continue from their saved states, and the controller is not requested again
+// at the state of the checkpoint.
+func ResumeFrom(cp Checkpoint) Option {
+	return func(s *settings) {
+		s.resume = &cp
+	}
+}
+
+// CheckpointEvery specifies that a checkpoint of the solving process should be
+// created every n iterations and given to the handler function (that could save
+// it in a file for example). The checkpoints hold the state of the Process,
+// i.e. of the controllers (see Checkpoint). If the handler returns an error,
+// the solving process stops and returns this error. The handler must be
+// defined (the Solve function returns an error otherwise).
+func CheckpointEvery(n uint64, handler func(cp Checkpoint) error) Option {
+	return func(s *settings) {
+		s.checkpointInterval = n
+		s.checkpointHandler = handler
+	}
+}
+
+// OutputTimes specifies the times at which the states should be recorded,
+// instead of the times of the solver iterations. The states at the output times
+// are computed using the dense output of the solver, and the last step is
+// shortened so that the solving process ends exactly on the last output time.
+// The output times should be strictly monotonic in the direction of
+// integration. With this option, the controller can be nil: the solving
+// process then stops at the last output time.
+func OutputTimes(times ...float64) Option {
+	return func(s *settings) {
+		s.outputTimes = times
+	}
+}
+
+// DetectEvents specifies the events to be monitored during the solving process.
+// The occurrences of the events can be retrieved at the end of the process
+// with the Events function of the Solver.
+func DetectEvents(events ...Event) Option {
+	return func(s *settings) {
+		s.events = events
+	}
+}
+
+// Modes specifies the alternative rate functions of a hybrid system, whose
+// active function is switched by the reset maps of the events (see Event). The
+// function f given to the Solve function is the mode 0 (the initial mode), and
+// the functions given to Modes are the modes 1, 2, etc.
+func Modes(functions ...Function) Option {
+	return func(s *settings) {
+		s.modes = make([]InPlaceFunction, len(functions))
+		for i, f := range functions {
+			s.modes[i] = f.InPlace()
+		}
+	}
+}
+
+// ModesInPlace is the same as Modes, but for rate functions defined as
+// InPlaceFunction.
+func ModesInPlace(functions ...InPlaceFunction) Option {
+	return func(s *settings) {
+		s.modes = functions
+	}
+}
+
+// ZenoLimit specifies the protection against a Zeno behavior of a hybrid
+// system, i.e. an infinite number of resets in a finite time (e.g. a bouncing
+// ball with a restitution coefficient lower than 1). The solving process stops
+// with an error if more than n resets occur within a time window of the given
+// size. By default, n=100 and the window is the step size.
+func ZenoLimit(n int, window float64) Option {
+	return func(s *settings) {
+		s.zenoResets = n
+		s.zenoWindow = math.Abs(window)
+	}
+}
+
+// Breakpoints specifies the times on which the solver must land exactly, for
+// example the times of the discontinuities of the rate function (a forcing
+// term switched on or off). The step that would straddle a breakpoint is
+// shortened to end on the breakpoint, and the integration is restarted from
+// the breakpoint (new evaluation of the rate function, and dense output
+// starting on the breakpoint). The times can be given in any order, and the
+// breakpoints of several options are merged.
+func Breakpoints(times ...float64) Option {
+	return func(s *settings) {
+		s.breakpoints = append(s.breakpoints, times...)
+	}
+}
+
+// MaxSteps specifies the maximal number of steps of the solving process. The
+// process stops with an error of kind ErrMaxSteps if the controller has not
+// stopped it before. A value of 0 (the default) means no limit.
+func MaxSteps(n uint64) Option {
+	return func(s *settings) {
+		s.maxSteps = n
+	}
+}
+
+// Newton specifies the parameters of the Newton iterations of the implicit
+// methods: the iterations stop when the correction of each component x of the
+// state is lower than tolerance*(1+|x|), and the solving process fails with an
+// error of kind ErrNewtonDivergence if the convergence is not reached after
+// maxIterations iterations. By default, tolerance=1e-10 and maxIterations=10.
+func Newton(tolerance float64, maxIterations int) Option {
+	return func(s *settings) {
+		s.newtonTolerance = tolerance
+		s.newtonIterations = maxIterations
+	}
+}
@@ -0,0 +1,147 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// outputGrid handles the recording of the states at the output times specified
+// by the option OutputTimes. The states at the output times are computed using
+// the dense output of the steps.
+type outputGrid struct {
+	times     []float64
+	next      int     // index of the next output time to record
+	direction float64 // +1 forward, -1 backward
+}
+
+// newOutputGrid creates an outputGrid for a solving process that starts at t0
+// with a step size h. The output times before t0 (t0 included) are considered
+// as already recorded. A nil outputGrid is returned if there is no output
+// times.
+func newOutputGrid(times []float64, t0 float64, h float64) (*outputGrid, error) {
+	if len(times) == 0 {
+		return nil, nil
+	}
+	grid := outputGrid{times: times, direction: math.Copysign(1, h)}
+	for i := 1; i < len(times); i++ {
+		if (times[i]-times[i-1])*grid.direction <= 0 {
+			err := fmt.Errorf("the output times should be strictly monotonic in the direction of integration (%g then %g)", times[i-1], times[i])
+			return nil, newError(ErrInvalidInput, err, t0, nil, h)
+		}
+	}
+	for grid.next < len(times) && (times[grid.next]-t0)*grid.direction < timeTolerance {
+		grid.next++
+	}
+	if grid.done() {
+		err := fmt.Errorf("all the output times are before the initial time %g", t0)
+		return nil, newError(ErrInvalidInput, err, t0, nil, h)
+	}
+	return &grid, nil
+}
+
+// startsAt returns true if the first output time is the time t0
+func (grid *outputGrid) startsAt(t0 float64) bool {
+	return math.Abs(grid.times[0]-t0) < timeTolerance
+}
+
+// done returns true if all the output times have been recorded
+func (grid *outputGrid) done() bool {
+	return grid.next >= len(grid.times)
+}
+
+// last returns the last output time
+func (grid *outputGrid) last() float64 {
+	return grid.times[len(grid.times)-1]
+}
+
+// record records the states at the output times that are in the step of the
+// dense output, up to the time tend, using X as workspace, and returns the
+// number of records.
+func (grid *outputGrid) record(r Recorder, dense *DenseOutput, tend float64, X []float64) uint64 {
+	var records uint64 = 0
+	for !grid.done() && (grid.times[grid.next]-tend)*grid.direction < timeTolerance {
+		t := grid.times[grid.next]
+		if math.Abs(t-dense.T1) < timeTolerance {
+			r.Record(t, dense.X1)
+		} else {
+			dense.Eval(t, X)
+			r.Record(t, X)
+		}
+		grid.next++
+		records++
+	}
+	return records
+}
+
+// breakpoints handles the times on which the solver must land exactly (see the
+// option Breakpoints).
+type breakpoints struct {
+	times     []float64
+	next      int             // index of the next breakpoint
+	direction float64         // +1 forward, -1 backward
+	f         InPlaceFunction // rate function of the step that lands on the next breakpoint
+	left      InPlaceFunction // the function leftLimit
+}
+
+// newBreakpoints creates the breakpoints for a solving process that starts at
+// t0 with a step size h. The times are sorted in the direction of integration,
+// and the times before t0 (t0 included) are ignored. A nil value is returned
+// if there is no breakpoint after t0.
+func newBreakpoints(times []float64, t0 float64, h float64) *breakpoints {
+	bps := breakpoints{direction: math.Copysign(1, h)}
+	for _, t := range times {
+		if (t-t0)*bps.direction >= timeTolerance {
+			bps.times = append(bps.times, t)
+		}
+	}
+	if len(bps.times) == 0 {
+		return nil
+	}
+	sort.Slice(bps.times, func(i, j int) bool {
+		return bps.times[i]*bps.direction < bps.times[j]*bps.direction
+	})
+	bps.left = bps.leftLimit
+	return &bps
+}
+
+// target returns the next breakpoint, and false if there is no more breakpoint
+func (bps *breakpoints) target() (float64, bool) {
+	if bps == nil || bps.next >= len(bps.times) {
+		return 0, false
+	}
+	return bps.times[bps.next], true
+}
+
+// before returns the rate function to be used in the step that lands on the
+// next breakpoint: the function f is evaluated before the breakpoint (in the
+// direction of integration), i.e. with its value before the discontinuity,
+// even for the stages of the step that are on the breakpoint.
+func (bps *breakpoints) before(f InPlaceFunction) InPlaceFunction {
+	bps.f = f
+	return bps.left
+}
+
+// leftLimit evaluates the function f with the times beyond the next breakpoint
+// replaced by the floating point number that precedes the breakpoint
+func (bps *breakpoints) leftLimit(t float64, X, dXdt []float64) error {
+	tb := bps.times[bps.next]
+	if (t-tb)*bps.direction >= 0 {
+		t = math.Nextafter(tb, tb-bps.direction)
+	}
+	return bps.f(t, X, dXdt)
+}
+
+// reached returns true if the time t is on a breakpoint. The breakpoints up to
+// the time t are then considered as passed.
+func (bps *breakpoints) reached(t float64) bool {
+	if bps == nil {
+		return false
+	}
+	onBreakpoint := false
+	for bps.next < len(bps.times) && (bps.times[bps.next]-t)*bps.direction < timeTolerance {
+		onBreakpoint = onBreakpoint || math.Abs(bps.times[bps.next]-t) < timeTolerance
+		bps.next++
+	}
+	return onBreakpoint
+}
@@ -5,10 +5,24 @@ import "fmt"
 // Recorder is the interface to be implemented by the data recorders. A recorder is
 // a dataset that can be used by a Solver to record the iteration states of the
 // solving process, i.e. the sequence of values (tn,Xn).
+//
+// The slice X given to the Record function is owned by the solver, and its
+// memory is reused for the next iterations. A recorder that keeps the values
+// must then copy them (as does the RecorderTimeSeries), and should never keep
+// a reference to X.
 type Recorder interface {
 	Record(t float64, X []float64)
 }
 
+// RecorderSeeker is an optional interface for the recorders that can be
+// positioned when a solving process is resumed from a checkpoint. The Seek
+// function is called with the number of records made up to the checkpoint, so
+// that the recorder can discard the records made after the checkpoint.
+type RecorderSeeker interface {
+	Recorder
+	Seek(records uint64) error
+}
+
 // RecorderNone defines a Recorder that records nothing (default if no recorder
 // is specified when calling the Solve function of a Solver, i.e. if recorder=nil)
 type RecorderNone struct{}
@@ -32,8 +46,19 @@ type RecorderTimeSeries struct {
 }
 
 // Record implements the Recorder interface so that the TimeSeries can be used
-// as a Recorder of a Solve process.
+// as a Recorder of a Solve process. The state X is copied in the TimeSeries.
 func (recorder *RecorderTimeSeries) Record(t float64, X []float64) {
-	data := TimeData{t, X}
+	state := make([]float64, len(X))
+	copy(state, X)
+	data := TimeData{t, state}
 	recorder.Series = append(recorder.Series, data)
 }
+
+// Seek implements the RecorderSeeker interface. The records of the TimeSeries
+// beyond the given number of records are discarded.
+func (recorder *RecorderTimeSeries) Seek(records uint64) error {
+	if uint64(len(recorder.Series)) > records {
+		recorder.Series = recorder.Series[:records]
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package solver
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gboulant/dingo-ode/internal/core"
 )
 
 // Function defines the function F of an ODE system, i.e. the function that
@@ -14,76 +20,543 @@ import (
 // realized (division by zero, square root of a negative number, etc).
 type Function func(t float64, X []float64) (dXdt []float64, err error)
 
+// InPlaceFunction is the allocation-free variant of a Function: the time
+// derivative dX/dt = F(t,X) is written in the slice dXdt (of same length as X)
+// given by the solver instead of being returned in a new slice. The function
+// should not keep a reference to X or dXdt, whose memory is reused by the
+// solver for the next iterations.
+type InPlaceFunction func(t float64, X []float64, dXdt []float64) error
+
+// InPlace returns an InPlaceFunction that evaluates the Function f and copies
+// the result in the slice dXdt. An error of kind ErrDimensionMismatch is
+// returned if the slice returned by f has not the expected length.
+func (f Function) InPlace() InPlaceFunction {
+	return func(t float64, X []float64, dXdt []float64) error {
+		slope, err := f(t, X)
+		if err != nil {
+			return err
+		}
+		if len(slope) != len(dXdt) {
+			err = fmt.Errorf("the function f returns %d values for a state of size %d", len(slope), len(dXdt))
+			return newError(ErrDimensionMismatch, err, t, X, 0)
+		}
+		copy(dXdt, slope)
+		return nil
+	}
+}
+
 // Solver is the interface to be implemented by the ODE solvers. An ODE solver
 // tries to solve the problem defined by (1) a 1-degree dynamic system dX/dt=f(X,t)
 // where X is a set of variables that characterize the state of the system
 // (coordinates in the phase diagram) and (2) the initial conditions defined by
-// (t0, X0).
+// (t0, X0). The solvers integrate backward in time when the step size h is
+// negative.
 type Solver interface {
 	// Solve solves the system defined by the Function f, from initial
 	// conditions (t0,X0), with a step size of h, and stopping the process when
 	// the stop handler return true. The Solve function returns the number of
-	// iterations and a non nil error if that occurs.
-	Solve(f Function, t0 float64, X0 []float64, h float64, c Controller, r Recorder) (uint64, error)
-	// Result returns the values of t and X obtained at the end of the solving process
+	// iterations and a non nil error if that occurs. The errors of a failing
+	// solving process are of type *Error, whose kind can be checked with
+	// errors.Is and that gives the state of the process at the failure.
+	Solve(f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveContext is the same as Solve, but the solving process is aborted
+	// when the context is canceled or when its deadline is exceeded. In this
+	// case, the returned error wraps the context error, and the Result function
+	// returns the last state computed before the interruption.
+	SolveContext(ctx context.Context, f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveInPlace is the same as Solve, but for a system defined by an
+	// InPlaceFunction. Used with a recorder and a controller that do not
+	// allocate memory, the iterations are free of memory allocations.
+	SolveInPlace(f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// SolveInPlaceContext is the same as SolveContext, but for a system
+	// defined by an InPlaceFunction.
+	SolveInPlaceContext(ctx context.Context, f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error)
+	// Result returns the values of t and X obtained at the end of the solving
+	// process. The vector X is a copy that can be freely kept by the caller.
 	Result() (t float64, X []float64)
+	// Stats returns the statistics of the last solving process
+	Stats() Stats
+	// Events returns the occurrences of the events detected during the last
+	// solving process (see the option DetectEvents), in the time order.
+	Events() []EventOccurrence
+	// Transitions returns the log of the resets of the system by the events
+	// during the last solving process, with the modes before and after each
+	// reset (see Event and the option Modes).
+	Transitions() []Transition
+	// StopReason returns the reason why the last solving process stopped: the
+	// controller that requested the stop, a terminal event, the last output
+	// time, or the error of a failure.
+	StopReason() StopReason
+	// DenseOutput returns the continuous approximation of the solution over
+	// the last step of the last solving process.
+	DenseOutput() *DenseOutput
+	// Checkpoint returns a checkpoint of the state reached at the end of the
+	// last solving process (see Result), that can be used to resume the process
+	// later on (see the option ResumeFrom).
+	Checkpoint() Checkpoint
 }
 
-// Iteration defines a function that implements an iteration step of a standard solver
-type Iteration func(f Function, tn float64, Xn []float64, h float64) ([]float64, error)
+// Iteration defines a function that implements an iteration step of a
+// standard solver. The iteration computes in Xs the state at time tn+h from the
+// state Xn at time tn, where Fn is the slope dX/dt at (tn,Xn) already evaluated
+// by the solver (i.e. f(tn,Xn) for an explicit method). The slices Xn, Fn and
+// Xs are owned by the solver and have the same length.
+type Iteration func(f InPlaceFunction, tn float64, Xn []float64, Fn []float64, h float64, Xs []float64) error
 
 // StandardSolver implements the interface Solver with a standard Solve
 // implementation. The StandardSolver apply an Iteration function (to be
-// defined) at each step of the Solve function.
+// defined) at each step of the Solve function. The state vectors used during
+// the iterations are allocated once and reused from one Solve to the other.
 type StandardSolver struct {
-	t         float64
-	X         []float64
-	iteration Iteration
+	t           float64
+	X           []float64
+	h           float64
+	iterations  uint64
+	records     uint64
+	method      string
+	iteration   Iteration
+	slope       func(f InPlaceFunction, t float64, X, dXdt []float64) error
+	stats       Stats
+	mode        int
+	dense       DenseOutput
+	events      []EventOccurrence
+	transitions []Transition
+	reason      StopReason
+	xm, xn      []float64 // workspace for the states at the beginning and the end of a step
+	fm, fn      []float64 // workspace for the slopes at the beginning and the end of a step
+	xout        []float64 // workspace for the states interpolated at the output times
 }
 
 // Solve implements the Solver interface for the StandarSolver
-func (solver *StandardSolver) Solve(f Function, t0 float64, X0 []float64, h float64, c Controller, r Recorder) (uint64, error) {
+func (solver *StandardSolver) Solve(f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveContext(context.Background(), f, t0, X0, h, c, r, opts...)
+}
+
+// SolveContext implements the Solver interface for the StandarSolver. The
+// context is checked every n iterations, where n can be specified using the
+// option CheckEvery.
+func (solver *StandardSolver) SolveContext(ctx context.Context, f Function, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
 	if f == nil {
-		return 0, errors.New("ERR: the function f is not defined")
+		return 0, newError(ErrInvalidInput, errors.New("the function f is not defined"), t0, X0, h)
+	}
+	return solver.SolveInPlaceContext(ctx, f.InPlace(), t0, X0, h, c, r, opts...)
+}
+
+// SolveInPlace implements the Solver interface for the StandarSolver
+func (solver *StandardSolver) SolveInPlace(f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (uint64, error) {
+	return solver.SolveInPlaceContext(context.Background(), f, t0, X0, h, c, r, opts...)
+}
+
+// SolveInPlaceContext implements the Solver interface for the StandarSolver
+func (solver *StandardSolver) SolveInPlaceContext(ctx context.Context, f InPlaceFunction, t0 float64, X0 []float64, h float64, c ProcessController, r Recorder, opts ...Option) (_ uint64, err error) {
+	// The reason of the stop is the failure if the process returns an error
+	solver.reason = StopReason{T: t0}
+	defer func() {
+		if err != nil {
+			solver.reason = StopReason{T: solver.reason.T, Reason: "the solving process fails", Err: err}
+			var e *Error
+			if errors.As(err, &e) {
+				solver.reason.T = e.T
+			}
+		}
+	}()
+
+	if f == nil {
+		return 0, newError(ErrInvalidInput, errors.New("the function f is not defined"), t0, X0, h)
 	}
 	if r == nil {
 		r = &RecorderNone{} // Record no intermediate iteration
 	}
+	config := newSettings(opts...)
+	if config.resume == nil {
+		if h == 0 {
+			err := errors.New("the step size h should not be zero")
+			return 0, newError(ErrStepSizeTooSmall, err, t0, X0, h)
+		}
+		if !isFinite(X0) || !isFinite([]float64{t0, h}) {
+			err := errors.New("the initial conditions should be finite")
+			return 0, newError(ErrNonFinite, err, t0, X0, h)
+		}
+	}
+	if c == nil && len(config.outputTimes) == 0 {
+		err := errors.New("the controller c is not defined")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
+	if config.checkpointInterval > 0 && config.checkpointHandler == nil {
+		err := errors.New("the checkpoint handler is not defined")
+		return 0, newError(ErrInvalidInput, err, t0, X0, h)
+	}
 
-	tm := t0
-	Xm := X0
-	r.Record(tm, Xm)
+	start := time.Now()
+	solver.stats = Stats{}
+	modes := make([]InPlaceFunction, len(config.modes)+1)
+	modes[0] = core.Instrument(&solver.stats, f)
+	for i, mf := range config.modes {
+		modes[i+1] = core.Instrument(&solver.stats, mf)
+	}
 
 	var nbIterations uint64 = 0
+	var nbRecords uint64 = 0
+	mode := 0
 
-	for {
-		Xn, err := solver.iteration(f, tm, Xm, h)
-		if err != nil {
-			return nbIterations, err
+	if cp := config.resume; cp != nil {
+		if cp.Method != solver.method {
+			err := fmt.Errorf("the checkpoint was created with the method %s instead of %s", cp.Method, solver.method)
+			return 0, newError(ErrInvalidInput, err, cp.T, cp.X, cp.H)
+		}
+		t0, X0, h = cp.T, cp.X, cp.H
+		nbIterations, nbRecords, mode = cp.Iterations, cp.Records, cp.Mode
+		if mode < 0 || mode >= len(modes) {
+			err := fmt.Errorf("the checkpoint mode %d is not defined", mode)
+			return 0, newError(ErrInvalidInput, err, cp.T, cp.X, cp.H)
+		}
+		if seeker, ok := r.(RecorderSeeker); ok {
+			if err := seeker.Seek(nbRecords); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	grid, err := newOutputGrid(config.outputTimes, t0, h)
+	if err != nil {
+		return 0, err
+	}
+	bps := newBreakpoints(config.breakpoints, t0, h)
+	monitor := newEventMonitor(config.events)
+	solver.events = nil
+	solver.transitions = nil
+	zenoWindow := config.zenoWindow
+	if zenoWindow == 0 {
+		zenoWindow = math.Abs(h)
+	}
+	zenoStart, zenoCount := t0, 0
+	f = modes[mode]
+
+	n := len(X0)
+	solver.xm = resize(solver.xm, n)
+	solver.xn = resize(solver.xn, n)
+	solver.fm = resize(solver.fm, n)
+	solver.fn = resize(solver.fn, n)
+	solver.xout = resize(solver.xout, n)
+	copy(solver.xm, X0)
+
+	tm := t0
+	Xm, Fm := solver.xm, solver.fm
+	Xn, Fn := solver.xn, solver.fn
+
+	// The result is updated on every exit of the loop, so that the last
+	// state computed is available even if the process is interrupted.
+	defer func() {
+		solver.t = tm
+		solver.X = resize(solver.X, len(Xm))
+		copy(solver.X, Xm)
+		solver.h = h
+		solver.iterations = nbIterations
+		solver.records = nbRecords
+		solver.mode = mode
+		solver.stats.WallTime = time.Since(start)
+		if monitor != nil {
+			solver.events = monitor.occurrences
 		}
-		tn := tm + h
-		r.Record(tn, Xn)
+	}()
+
+	if config.resume == nil && (grid == nil || grid.startsAt(t0)) {
+		r.Record(tm, Xm)
+		nbRecords++
+	}
 
-		stop, err := c(tn, Xn)
+	// The Process of a checkpoint created during the solving process has
+	// already requested the controller at the checkpoint state.
+	process := NewProcess(t0, h)
+	if cp := config.resume; cp != nil && cp.Process != nil {
+		process = RestoreProcess(*cp.Process, h)
+	} else if c != nil {
+		stop, err := process.Request(c, tm, Xm)
 		if err != nil {
-			return nbIterations, err
+			return nbIterations, newError(ErrFunction, err, tm, Xm, h)
 		}
 		if stop {
-			break
+			solver.reason = process.Reason()
+			return nbIterations, nil
+		}
+	}
+
+	// The slope at the beginning of a step is the slope at the end of the
+	// previous step. It is used by the methods and by the dense output.
+	err = solver.evaluate(f, tm, Xm, Fm)
+	if err != nil {
+		return nbIterations, newError(ErrFunction, err, tm, Xm, h)
+	}
+	if monitor != nil {
+		monitor.start(tm, Xm)
+	}
+
+	for {
+		if nbIterations%config.checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nbIterations, newError(ErrInterrupted, err, tm, Xm, h)
+			}
+		}
+		if config.maxSteps > 0 && solver.stats.AcceptedSteps >= config.maxSteps {
+			err := fmt.Errorf("%d steps done", solver.stats.AcceptedSteps)
+			return nbIterations, newError(ErrMaxSteps, err, tm, Xm, h)
+		}
+
+		// The step is shortened to land exactly on the next breakpoint or on
+		// the last output time
+		hs, tn := h, tm+h
+		if tb, ok := bps.target(); ok && (tn-tb)*bps.direction > -timeTolerance {
+			hs, tn = tb-tm, tb
+		}
+		if grid != nil && (tn-grid.last())*grid.direction > -timeTolerance {
+			hs, tn = grid.last()-tm, grid.last()
+		}
+		if tn == tm {
+			return nbIterations, newError(ErrStepSizeTooSmall, nil, tm, Xm, hs)
 		}
+		fs := f
+		if tb, ok := bps.target(); ok && tn == tb {
+			fs = bps.before(f)
+		}
+
+		err = solver.iteration(fs, tm, Xm, Fm, hs, Xn)
+		if err != nil {
+			return nbIterations, newError(ErrFunction, err, tm, Xm, hs)
+		}
+		err = solver.evaluate(fs, tn, Xn, Fn)
+		if err != nil {
+			return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+		}
+		solver.dense.set(tm, Xm, Fm, tn, Xn, Fn)
+
+		// An event can interrupt the step, that then ends at the time of the
+		// event.
+		interrupt := -1
+		te, Xe := tn, Xn
+		if monitor != nil {
+			te, Xe, interrupt = monitor.step(&solver.dense)
+		}
+		if interrupt >= 0 && config.events[interrupt].Land && te != tn {
+			// The step is computed again to land on the event
+			hs = te - tm
+			err = solver.iteration(fs, tm, Xm, Fm, hs, Xn)
+			if err != nil {
+				return nbIterations, newError(ErrFunction, err, tm, Xm, hs)
+			}
+			err = solver.evaluate(fs, te, Xn, Fn)
+			if err != nil {
+				return nbIterations, newError(ErrFunction, err, te, Xn, hs)
+			}
+			solver.dense.set(tm, Xm, Fm, te, Xn, Fn)
+			monitor.land(Xn)
+			tn, Xe = te, Xn
+		}
+		// A step that lands on an event is counted once, with its final size
+		core.Accept(&solver.stats, hs)
+
+		var records uint64 = 0
+		if grid != nil {
+			records += grid.record(r, &solver.dense, te, solver.xout)
+		}
+		if interrupt >= 0 {
+			// The dense output ends at the event, with the slope before the
+			// reset of the event.
+			if tn != te {
+				tn = te
+				copy(Xn, Xe)
+				err = solver.evaluate(fs, tn, Xn, Fn)
+				if err != nil {
+					return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+				}
+			}
+			solver.dense.cut(tn, Xn, Fn)
+		}
+		if grid == nil {
+			r.Record(tn, Xn)
+			records++
+		}
+
+		// The breakpoints are passed whatever the end of the step, as an
+		// event can occur exactly on a breakpoint.
+		reached := bps.reached(tn)
+		if interrupt >= 0 {
+			event := config.events[interrupt]
+			if event.Reset != nil {
+				from := mode
+				copy(solver.xout, Xn)
+				next, err := event.Reset(tn, Xn, mode)
+				if err != nil {
+					return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+				}
+				if next < 0 || next >= len(modes) {
+					err = fmt.Errorf("the mode %d activated by the event %d is not defined", next, interrupt)
+					return nbIterations, newError(ErrInvalidInput, err, tn, Xn, hs)
+				}
+				solver.transitions = append(solver.transitions, Transition{T: tn, Event: interrupt, From: from, To: next})
+				mode = next
+				f = modes[mode]
 
-		Xm = Xn
+				// Protection against the Zeno behavior
+				if math.Abs(tn-zenoStart) > zenoWindow {
+					zenoStart, zenoCount = tn, 0
+				}
+				zenoCount++
+				if zenoCount > config.zenoResets {
+					err = fmt.Errorf("%d resets since t=%g", zenoCount, zenoStart)
+					return nbIterations, newError(ErrZeno, err, tn, Xn, hs)
+				}
+
+				// The state after the reset is also recorded, if the reset
+				// changes the state or the mode
+				if grid == nil && (mode != from || !equal(Xn, solver.xout)) {
+					r.Record(tn, Xn)
+					records++
+				}
+			}
+			if event.Terminal {
+				solver.reason = StopReason{T: tn, Reason: fmt.Sprintf("the terminal event %d (%s) occurs", interrupt, event.Name)}
+				tm, Xm = tn, Xn
+				nbIterations++
+				nbRecords += records
+				return nbIterations, nil
+			}
+
+			// The integration restarts from the state of the event
+			err = solver.evaluate(f, tn, Xn, Fn)
+			if err != nil {
+				return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+			}
+			monitor.restart(tn, Xn, interrupt)
+		} else if reached {
+			// The integration restarts from the breakpoint with the slope
+			// after the discontinuity.
+			solver.dense.cut(tn, Xn, Fn)
+			err = solver.evaluate(f, tn, Xn, Fn)
+			if err != nil {
+				return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+			}
+		}
+
+		if c != nil {
+			stop, err := process.Request(c, tn, Xn)
+			if err != nil {
+				return nbIterations, newError(ErrFunction, err, tn, Xn, hs)
+			}
+			if stop {
+				solver.reason = process.Reason()
+				return nbIterations, nil
+			}
+		}
+
+		Xm, Xn = Xn, Xm
+		Fm, Fn = Fn, Fm
 		tm = tn
 		nbIterations++
-	}
+		nbRecords += records
 
-	solver.t = tm
-	solver.X = Xm
+		if grid != nil && grid.done() {
+			solver.reason = StopReason{T: tm, Reason: "the last output time is reached"}
+			break
+		}
+
+		if config.checkpointInterval > 0 && nbIterations%config.checkpointInterval == 0 {
+			cp := solver.checkpoint(tm, Xm, h, nbIterations, nbRecords, mode)
+			state := process.Save()
+			cp.Process = &state
+			if err := config.checkpointHandler(cp); err != nil {
+				return nbIterations, newError(ErrFunction, err, tm, Xm, h)
+			}
+		}
+	}
 
 	return nbIterations, nil
 }
 
 // Result implements the Solver interface
 func (solver *StandardSolver) Result() (t float64, X []float64) {
-	return solver.t, solver.X
+	X = make([]float64, len(solver.X))
+	copy(X, solver.X)
+	return solver.t, X
+}
+
+// Stats implements the Solver interface
+func (solver *StandardSolver) Stats() Stats {
+	return solver.stats
+}
+
+// Events implements the Solver interface
+func (solver *StandardSolver) Events() []EventOccurrence {
+	return solver.events
+}
+
+// Transitions implements the Solver interface
+func (solver *StandardSolver) Transitions() []Transition {
+	return solver.transitions
+}
+
+// StopReason implements the Solver interface
+func (solver *StandardSolver) StopReason() StopReason {
+	return solver.reason
+}
+
+// DenseOutput implements the Solver interface
+func (solver *StandardSolver) DenseOutput() *DenseOutput {
+	return &solver.dense
+}
+
+// Checkpoint implements the Solver interface
+func (solver *StandardSolver) Checkpoint() Checkpoint {
+	return solver.checkpoint(solver.t, solver.X, solver.h, solver.iterations, solver.records, solver.mode)
+}
+
+// checkpoint creates a checkpoint with a copy of the given state
+func (solver *StandardSolver) checkpoint(t float64, X []float64, h float64, iterations, records uint64, mode int) Checkpoint {
+	state := make([]float64, len(X))
+	copy(state, X)
+	return Checkpoint{
+		Version:    CheckpointVersion,
+		Method:     solver.method,
+		T:          t,
+		X:          state,
+		H:          h,
+		Iterations: iterations,
+		Records:    records,
+		Mode:       mode,
+	}
+}
+
+// evaluate computes in dXdt the slope dX/dt at (t,X) for the rate function f.
+// The slope is f(t,X), unless the method defines its own evaluation of the
+// slope (e.g. the implicit methods for the problems with a mass matrix).
+func (solver *StandardSolver) evaluate(f InPlaceFunction, t float64, X, dXdt []float64) error {
+	if solver.slope == nil {
+		return f(t, X, dXdt)
+	}
+	return solver.slope(f, t, X, dXdt)
+}
+
+// equal returns true if the vectors X and Y are equal
+func equal(X, Y []float64) bool {
+	for i := range X {
+		if X[i] != Y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isFinite returns true if all the values of X are finite (neither NaN nor Inf)
+func isFinite(X []float64) bool {
+	for _, x := range X {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// resize returns a slice of length n, reusing the memory of v if its capacity
+// is sufficient.
+func resize(v []float64, n int) []float64 {
+	return core.Resize(v, n)
 }
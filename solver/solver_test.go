@@ -0,0 +1,160 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// exponential is the rate function of dx/dt = x, whose solution is exp(t)
+func exponential(t float64, X []float64, dXdt []float64) error {
+	dXdt[0] = X[0]
+	return nil
+}
+
+// TestLandedStepStats checks that a step that lands on an event is counted
+// once as an accepted step, and not as a rejected step.
+func TestLandedStepStats(t *testing.T) {
+	event := Event{
+		G:        func(t float64, X []float64) float64 { return t - 0.25 },
+		Terminal: true,
+		Land:     true,
+	}
+	algo := NewRK4Solver()
+	iterations, err := algo.SolveInPlace(exponential, 0, []float64{1}, 0.1, StopAtTime(1), nil, DetectEvents(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := algo.Stats()
+	if stats.AcceptedSteps != iterations || stats.RejectedSteps != 0 {
+		t.Errorf("%d accepted and %d rejected steps for %d iterations", stats.AcceptedSteps, stats.RejectedSteps, iterations)
+	}
+	if math.Abs(stats.MinStep-0.05) > 1e-12 {
+		t.Errorf("the landed step has the size %g instead of 0.05", stats.MinStep)
+	}
+}
+
+// TestDenseOutputReset checks that the dense output of a step interrupted by
+// an event ends at the event with the state before the reset of the event.
+func TestDenseOutputReset(t *testing.T) {
+	event := Event{
+		G:        func(t float64, X []float64) float64 { return X[0] - 1.5 },
+		Terminal: true,
+		Reset: func(t float64, X []float64, mode int) (int, error) {
+			X[0] = 0.5
+			return mode, nil
+		},
+	}
+	for _, land := range []bool{false, true} {
+		event.Land = land
+		algo := NewRK4Solver()
+		_, err := algo.SolveInPlace(exponential, 0, []float64{1}, 1, StopAtTime(2), nil, DetectEvents(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		te, Xe := algo.Result()
+		dense := algo.DenseOutput()
+		if math.Abs(te-math.Log(1.5)) > 1e-2 || dense.T0 != 0 || dense.T1 != te {
+			t.Fatalf("land=%v: the dense output [%g,%g] does not end at the event t=%g", land, dense.T0, dense.T1, te)
+		}
+		if Xe[0] != 0.5 {
+			t.Errorf("land=%v: the result %v is not the state after the reset", land, Xe)
+		}
+		X := make([]float64, 1)
+		dense.Eval(te, X)
+		if math.Abs(X[0]-1.5) > 1e-2 {
+			t.Errorf("land=%v: the dense output at the event is %g instead of 1.5", land, X[0])
+		}
+		dense.Eval(te/2, X)
+		if math.Abs(X[0]-math.Exp(te/2)) > 1e-2 {
+			t.Errorf("land=%v: the dense output at t=%g is %g instead of %g", land, te/2, X[0], math.Exp(te/2))
+		}
+	}
+}
+
+// TestEventOnBreakpoint checks that an event that occurs exactly on a
+// breakpoint passes the breakpoint, so that the next step is not of size
+// zero. The event is a timer that occurs at t=1+X[1], and whose reset
+// increments X[1].
+func TestEventOnBreakpoint(t *testing.T) {
+	f := func(t float64, X []float64, dXdt []float64) error {
+		dXdt[0], dXdt[1] = X[0], 0
+		return nil
+	}
+	event := Event{
+		G: func(t float64, X []float64) float64 { return t - 1 - X[1] },
+		Reset: func(t float64, X []float64, mode int) (int, error) {
+			X[1]++
+			return mode, nil
+		},
+	}
+	algo := NewRK4Solver()
+	_, err := algo.SolveInPlace(f, 0, []float64{1, 0}, 0.1, StopAtTime(1.5), nil, DetectEvents(event), Breakpoints(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, Xr := algo.Result()
+	if math.Abs(tr-1.5) > 1e-9 || math.Abs(Xr[0]-math.Exp(1.5)) > 1e-5 || Xr[1] != 1 {
+		t.Errorf("the result is (%g,%v) instead of (1.5,[%g 1])", tr, Xr, math.Exp(1.5))
+	}
+	if events := algo.Events(); len(events) != 1 || events[0].T != 1 {
+		t.Errorf("the events %v are detected instead of one event at t=1", events)
+	}
+}
+
+// TestInvalidInput checks that the invalid arguments and the invalid modes
+// returned by a reset map are reported as errors of kind ErrInvalidInput.
+func TestInvalidInput(t *testing.T) {
+	algo := NewRK4Solver()
+	if _, err := algo.SolveInPlace(nil, 0, []float64{1}, 0.1, StopAtTime(1), nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("an undefined function gives the error %v", err)
+	}
+	if _, err := algo.SolveInPlace(exponential, 0, []float64{1}, 0.1, nil, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("an undefined controller gives the error %v", err)
+	}
+	cp := Checkpoint{Version: CheckpointVersion, Method: "euler", X: []float64{1}, H: 0.1}
+	if _, err := algo.SolveInPlace(exponential, 0, nil, 0, StopAtTime(1), nil, ResumeFrom(cp)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("a checkpoint of another method gives the error %v", err)
+	}
+	event := Event{
+		G:     func(t float64, X []float64) float64 { return t - 0.5 },
+		Reset: func(t float64, X []float64, mode int) (int, error) { return 1, nil },
+	}
+	_, err := algo.SolveInPlace(exponential, 0, []float64{1}, 0.1, StopAtTime(1), nil, DetectEvents(event))
+	if !errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrFunction) {
+		t.Errorf("an undefined mode gives the error %v", err)
+	}
+}
+
+// TestDelayEvents checks that the internal events tracking the discontinuities
+// of a state dependent delay are not reported with the events of the user.
+func TestDelayEvents(t *testing.T) {
+	delay := func(t float64, X []float64) float64 { return 1 + X[0]*X[0]/2 }
+	problem := DelayProblem{
+		F: func(t float64, X []float64, past *History, dXdt []float64) error {
+			dXdt[0] = -past.Component(0, t-delay(t, X))
+			return nil
+		},
+		History:     func(t float64, X []float64) { X[0] = 1 },
+		StateDelays: []DelayFunctionOf{delay},
+	}
+	event := Event{
+		Name: "half",
+		G:    func(t float64, X []float64) float64 { return X[0] - 0.5 },
+	}
+	algo := NewDelaySolver(NewRK4Solver())
+	_, err := algo.SolveDelay(problem, 0, []float64{1}, 0.05, StopAtTime(4), nil, DetectEvents(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(algo.Discontinuities()); n < 3 {
+		t.Fatalf("%d discontinuities are tracked", n)
+	}
+	events := algo.Events()
+	if len(events) != 1 || events[0].Index != 0 || events[0].Name != "half" {
+		t.Errorf("the events %v are reported instead of the event half", events)
+	}
+	if transitions := algo.Transitions(); len(transitions) != 0 {
+		t.Errorf("the transitions %v of the internal events are reported", transitions)
+	}
+}
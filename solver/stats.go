@@ -0,0 +1,24 @@
+package solver
+
+import "github.com/gboulant/dingo-ode/internal/core"
+
+// Stats gathers the statistics of a solving process. The statistics are reset
+// at the beginning of each call of the Solve function, and can be retrieved
+// with the Stats function of the Solver at the end of the process (or after an
+// interruption). The counters that are meaningless for a given method (e.g. the
+// Jacobian evaluations for an explicit method) stay equal to zero:
+//
+//	FunctionEvaluations uint64        // number of calls of the function f
+//	JacobianEvaluations uint64        // number of evaluations of the Jacobian matrix of f
+//	LUFactorizations    uint64        // number of LU factorizations of the iteration matrix
+//	AcceptedSteps       uint64        // number of steps accepted by the method
+//	RejectedSteps       uint64        // number of steps rejected by the error control
+//	MinStep             float64       // minimal absolute step size of the accepted steps
+//	MaxStep             float64       // maximal absolute step size of the accepted steps
+//	MeanStep            float64       // mean absolute step size of the accepted steps
+//	WallTime            time.Duration // duration of the whole solving process
+//	FunctionTime        time.Duration // time spent inside the function f
+//
+// The function String returns a summary of the statistics, one counter per
+// line.
+type Stats = core.Stats
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 )
 
 // TimeData is an element of a TimeSeries
@@ -55,6 +56,35 @@ func (series *TimeSeries) Clear() {
 	*series = make(TimeSeries, 0)
 }
 
+// Sort sorts the series by increasing time. This is useful for a series
+// recorded by a backward integration (negative step size), whose data are
+// recorded by decreasing time. The order of data with equal times is kept.
+func (series TimeSeries) Sort() {
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].time < series[j].time
+	})
+}
+
+// Reverse reverses the order of the data of the series
+func (series TimeSeries) Reverse() {
+	for i, j := 0, len(series)-1; i < j; i, j = i+1, j-1 {
+		series[i], series[j] = series[j], series[i]
+	}
+}
+
+// Components returns a new TimeSeries made of the components [from,to) of the
+// states of the series, e.g. the differential or the algebraic components of
+// the states of a DAE.
+func (series TimeSeries) Components(from, to int) TimeSeries {
+	components := make(TimeSeries, len(series))
+	for i, data := range series {
+		state := make([]float64, to-from)
+		copy(state, data.state[from:to])
+		components[i] = NewTimeData(data.time, state)
+	}
+	return components
+}
+
 func (series TimeSeries) String() string {
 	s := ""
 	for i := 0; i < len(series); i++ {
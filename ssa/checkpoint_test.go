@@ -0,0 +1,76 @@
+package ssa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// volterra is a discrete Volterra network (prey and predators)
+var volterra = Network{
+	Species: 2,
+	Reactions: []Reaction{
+		{Name: "prey birth", Reactants: []int{0}, Products: []int{0, 0}, Rate: 1},
+		{Name: "predation", Reactants: []int{0, 1}, Products: []int{1}, Rate: 0.01},
+		{Name: "predator birth", Reactants: []int{0, 1}, Products: []int{0, 1, 1}, Rate: 0.01},
+		{Name: "predator death", Reactants: []int{1}, Rate: 1},
+	},
+}
+
+// TestCheckpointResume checks that a simulation resumed from a checkpoint,
+// created during the simulation or at its end, draws the same random numbers
+// as the simulation done in one run, for the three methods, with and without
+// the sampling of the states.
+func TestCheckpointResume(t *testing.T) {
+	X0 := []float64{100, 50}
+	simulators := map[string]Simulator{
+		"direct":        NewDirectSimulator(),
+		"next reaction": NewNextReactionSimulator(),
+		"tau-leaping":   NewTauLeapingSimulator(),
+	}
+	for _, sampling := range []float64{0, 0.1} {
+		for name, sim := range simulators {
+			var reference solver.RecorderTimeSeries
+			if _, err := sim.Simulate(volterra, 0, X0, solver.StopAtTime(2), &reference, Seed(3), SampleEvery(sampling)); err != nil {
+				t.Fatal(err)
+			}
+			tref, Xref := sim.Result()
+			check := func(origin string, recorder solver.RecorderTimeSeries) {
+				tr, Xr := sim.Result()
+				if tr != tref || !reflect.DeepEqual(Xr, Xref) || !reflect.DeepEqual(recorder.Series, reference.Series) {
+					t.Errorf("%s (%s, sampling %g): the resumed simulation ends at (%g,%v) with %d records instead of (%g,%v) with %d records",
+						name, origin, sampling, tr, Xr, len(recorder.Series), tref, Xref, len(reference.Series))
+				}
+			}
+
+			// Checkpoint created during the simulation
+			var cp *solver.Checkpoint
+			handler := func(c solver.Checkpoint) error {
+				if cp == nil {
+					cp = &c
+				}
+				return nil
+			}
+			var recorder solver.RecorderTimeSeries
+			if _, err := sim.Simulate(volterra, 0, X0, solver.StopAtTime(2), &recorder, Seed(3), SampleEvery(sampling), CheckpointEvery(20, handler)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sim.Simulate(volterra, 0, nil, solver.StopAtTime(2), &recorder, ResumeFrom(*cp), SampleEvery(sampling)); err != nil {
+				t.Fatal(err)
+			}
+			check("CheckpointEvery", recorder)
+
+			// Checkpoint of the end of a simulation
+			recorder = solver.RecorderTimeSeries{}
+			if _, err := sim.Simulate(volterra, 0, X0, solver.StopAtTime(1), &recorder, Seed(3), SampleEvery(sampling)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sim.Simulate(volterra, 0, nil, solver.StopAtTime(2), &recorder, ResumeFrom(sim.Checkpoint()), SampleEvery(sampling)); err != nil {
+				t.Fatal(err)
+			}
+			check("Checkpoint", recorder)
+		}
+
+	}
+}
@@ -0,0 +1,72 @@
+package ssa
+
+import (
+	"math"
+	"math/rand"
+)
+
+// exponential returns a random number with the exponential distribution of
+// rate 1.
+func exponential(rng *rand.Rand) float64 {
+	return -math.Log(1 - rng.Float64())
+}
+
+// update computes the propensities of the reactions that depend on the
+// reaction j in the state X
+func (nw *network) update(j int, X []float64) {
+	for _, k := range nw.dependent[j] {
+		nw.a[k] = nw.propensity(k, X)
+	}
+}
+
+// startDirect computes the propensities of all the reactions at the initial
+// state
+func startDirect(nw *network, rng *rand.Rand, config settings, t0 float64, X0 []float64) {
+	for j := range nw.a {
+		nw.a[j] = nw.propensity(j, X0)
+	}
+}
+
+// stepDirect implements a step of the Gillespie direct method: the time to
+// the next reaction is drawn from an exponential distribution of rate
+// a0=sum(a_j), and the reaction j is chosen with the probability a_j/a0. The
+// propensities are then updated only for the reactions that depend on the
+// fired reaction.
+func stepDirect(nw *network, rng *rand.Rand, tm float64, Xm []float64, Xn []float64) (float64, error) {
+	a0 := 0.0
+	for _, a := range nw.a {
+		a0 += a
+	}
+	if a0 <= 0 {
+		return math.Inf(1), nil
+	}
+	tau := exponential(rng) / a0
+	j := choose(nw.a, a0, rng)
+	nw.fire(j, 1, Xn)
+	nw.update(j, Xn)
+	return tm + tau, nil
+}
+
+// choose returns the index j of a reaction chosen with the probability
+// a_j/a0
+func choose(a []float64, a0 float64, rng *rand.Rand) int {
+	u := rng.Float64() * a0
+	last := 0
+	for j, aj := range a {
+		if aj <= 0 {
+			continue
+		}
+		last = j
+		if u < aj {
+			return j
+		}
+		u -= aj
+	}
+	return last // round-off error on the sum a0
+}
+
+// NewDirectSimulator returns a Simulator that implements the direct method
+// of Gillespie, i.e. the exact simulation of each reaction of the network.
+func NewDirectSimulator() Simulator {
+	return &StandardSimulator{method: "direct", start: startDirect, step: stepDirect}
+}
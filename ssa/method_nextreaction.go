@@ -0,0 +1,205 @@
+package ssa
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// nrmWorkspace holds the putative times of the reactions used by the next
+// reaction method, ordered in an indexed binary heap.
+type nrmWorkspace struct {
+	times []float64 // absolute putative time of each reaction
+	heap  []int     // reactions ordered by their putative times
+	pos   []int     // position of each reaction in the heap
+	undo  []change  // changes of the times during the last step
+}
+
+// change is the change of the putative time of a reaction, with its previous
+// value
+type change struct {
+	j    int
+	time float64
+}
+
+// start computes the propensities and the putative times of the reactions at
+// the initial state
+func (w *nrmWorkspace) start(nw *network, rng *rand.Rand, config settings, t0 float64, X0 []float64) {
+	m := len(nw.a)
+	w.times = core.Resize(w.times, m)
+	w.heap = make([]int, m)
+	w.pos = make([]int, m)
+	for j := range nw.a {
+		nw.a[j] = nw.propensity(j, X0)
+		w.times[j] = putative(t0, nw.a[j], rng)
+	}
+	w.init()
+}
+
+// init orders the reactions in the heap by their putative times
+func (w *nrmWorkspace) init() {
+	m := len(w.times)
+	w.heap = make([]int, m)
+	w.pos = make([]int, m)
+	w.undo = w.undo[:0]
+	for j := range w.heap {
+		w.heap[j] = j
+		w.pos[j] = j
+	}
+	for k := m/2 - 1; k >= 0; k-- {
+		w.down(k)
+	}
+}
+
+// state returns a copy of the putative times of the reactions, rewound to the
+// beginning of the last step if rewind is true.
+func (w *nrmWorkspace) state(rewind bool) []float64 {
+	times := make([]float64, len(w.times))
+	copy(times, w.times)
+	if rewind {
+		for k := len(w.undo) - 1; k >= 0; k-- {
+			times[w.undo[k].j] = w.undo[k].time
+		}
+	}
+	return times
+}
+
+// restore computes the propensities at the state X0, and restores the
+// putative times of the reactions given by the function state.
+func (w *nrmWorkspace) restore(nw *network, config settings, t0 float64, X0 []float64, times []float64) error {
+	if len(times) != len(nw.a) {
+		err := errors.New("the checkpoint has not the putative times of the reactions")
+		return core.NewError(solver.ErrInvalidInput, err, t0, X0, 0)
+	}
+	for j := range nw.a {
+		nw.a[j] = nw.propensity(j, X0)
+	}
+	w.times = core.Resize(w.times, len(times))
+	copy(w.times, times)
+	w.init()
+	return nil
+}
+
+// set changes the putative time of the reaction j, and logs the change
+func (w *nrmWorkspace) set(j int, time float64) {
+	w.undo = append(w.undo, change{j, w.times[j]})
+	w.times[j] = time
+}
+
+// putative returns the absolute time of the next occurrence of a reaction of
+// propensity a, drawn from the time t
+func putative(t, a float64, rng *rand.Rand) float64 {
+	if a <= 0 {
+		return math.Inf(1)
+	}
+	return t + exponential(rng)/a
+}
+
+// step implements a step of the next reaction method of Gibson and Bruck:
+// the reaction that fires is the one with the smallest putative time (the top
+// of the heap). The putative times of the reactions that depend on it are then
+// rescaled by the ratio of their old and new propensities, so that only one
+// random number is drawn per reaction.
+func (w *nrmWorkspace) step(nw *network, rng *rand.Rand, tm float64, Xm []float64, Xn []float64) (float64, error) {
+	w.undo = w.undo[:0]
+	j := w.heap[0]
+	tn := w.times[j]
+	if math.IsInf(tn, 1) {
+		return tn, nil
+	}
+	nw.fire(j, 1, Xn)
+	for _, k := range nw.dependent[j] {
+		a := nw.propensity(k, Xn)
+		switch {
+		case k == j:
+			w.set(k, putative(tn, a, rng))
+		case a <= 0:
+			w.set(k, math.Inf(1))
+		case nw.a[k] > 0:
+			w.set(k, tn+nw.a[k]/a*(w.times[k]-tn))
+		default:
+			// The reaction was disabled: a new time is drawn (the
+			// exponential distribution is memoryless).
+			w.set(k, putative(tn, a, rng))
+		}
+		nw.a[k] = a
+		w.fix(w.pos[k])
+	}
+	if !contains(nw.dependent[j], j) {
+		w.set(j, putative(tn, nw.a[j], rng))
+		w.fix(w.pos[j])
+	}
+	return tn, nil
+}
+
+// contains returns true if the value v is in the slice s
+func contains(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// fix restores the order of the heap after the change of the time of the
+// reaction at the position k
+func (w *nrmWorkspace) fix(k int) {
+	if !w.up(k) {
+		w.down(k)
+	}
+}
+
+// up moves the reaction at the position k toward the top of the heap, and
+// returns true if it has been moved
+func (w *nrmWorkspace) up(k int) bool {
+	moved := false
+	for k > 0 {
+		p := (k - 1) / 2
+		if w.times[w.heap[p]] <= w.times[w.heap[k]] {
+			break
+		}
+		w.swap(k, p)
+		k = p
+		moved = true
+	}
+	return moved
+}
+
+// down moves the reaction at the position k toward the bottom of the heap
+func (w *nrmWorkspace) down(k int) {
+	n := len(w.heap)
+	for {
+		c := 2*k + 1
+		if c >= n {
+			return
+		}
+		if c+1 < n && w.times[w.heap[c+1]] < w.times[w.heap[c]] {
+			c++
+		}
+		if w.times[w.heap[k]] <= w.times[w.heap[c]] {
+			return
+		}
+		w.swap(k, c)
+		k = c
+	}
+}
+
+// swap exchanges the reactions at the positions k and l of the heap
+func (w *nrmWorkspace) swap(k, l int) {
+	w.heap[k], w.heap[l] = w.heap[l], w.heap[k]
+	w.pos[w.heap[k]] = k
+	w.pos[w.heap[l]] = l
+}
+
+// NewNextReactionSimulator returns a Simulator that implements the next
+// reaction method of Gibson and Bruck. The method is exact, as the direct
+// method, but it is more efficient for the large networks where each reaction
+// changes the propensities of few other reactions.
+func NewNextReactionSimulator() Simulator {
+	w := nrmWorkspace{}
+	return &StandardSimulator{method: "next-reaction", start: w.start, step: w.step, state: w.state, restore: w.restore}
+}
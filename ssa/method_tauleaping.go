@@ -0,0 +1,136 @@
+package ssa
+
+import (
+	"math"
+	"math/rand"
+)
+
+// tauWorkspace holds the parameters of the tau-leaping method
+type tauWorkspace struct {
+	epsilon float64
+	order   []int // highest order of the reactions consuming each species
+	mult    []int // highest multiplicity of each species in these reactions
+}
+
+// start computes, for each species, the highest order of the reactions that
+// consume it, used by the selection of the leap.
+func (w *tauWorkspace) start(nw *network, rng *rand.Rand, config settings, t0 float64, X0 []float64) {
+	w.epsilon = config.epsilon
+	w.order = make([]int, nw.n)
+	w.mult = make([]int, nw.n)
+	for _, r := range nw.reactions {
+		order := len(r.Reactants)
+		for _, s := range r.Reactants {
+			k := 0
+			for _, p := range r.Reactants {
+				if p == s {
+					k++
+				}
+			}
+			if order > w.order[s] || (order == w.order[s] && k > w.mult[s]) {
+				w.order[s] = order
+				w.mult[s] = k
+			}
+		}
+	}
+}
+
+// g returns the factor g_i of the selection of the leap (Cao et al., 2006),
+// that bounds the relative change of the propensities by the relative change
+// of the population x of the species i.
+func (w *tauWorkspace) g(i int, x float64) float64 {
+	x1 := math.Max(x-1, 1)
+	x2 := math.Max(x-2, 1)
+	switch {
+	case w.order[i] == 1:
+		return 1
+	case w.order[i] == 2 && w.mult[i] == 2:
+		return 2 + 1/x1
+	case w.order[i] == 3 && w.mult[i] == 2:
+		return 1.5 * (2 + 1/x1)
+	case w.order[i] == 3 && w.mult[i] == 3:
+		return 3 + 1/x1 + 2/x2
+	default:
+		return float64(w.order[i])
+	}
+}
+
+// leap returns the largest leap tau for which the relative changes of the
+// propensities are bounded by epsilon
+func (w *tauWorkspace) leap(nw *network, X []float64) float64 {
+	tau := math.Inf(1)
+	for i := 0; i < nw.n; i++ {
+		if w.order[i] == 0 {
+			continue
+		}
+		mu, sigma2 := 0.0, 0.0
+		for j, species := range nw.species {
+			for l, s := range species {
+				if s == i {
+					c := nw.changes[j][l]
+					mu += c * nw.a[j]
+					sigma2 += c * c * nw.a[j]
+				}
+			}
+		}
+		bound := math.Max(w.epsilon*X[i]/w.g(i, X[i]), 1)
+		if mu != 0 {
+			tau = math.Min(tau, bound/math.Abs(mu))
+		}
+		if sigma2 > 0 {
+			tau = math.Min(tau, bound*bound/sigma2)
+		}
+	}
+	return tau
+}
+
+// step implements a step of the tau-leaping method: the number of
+// occurrences of each reaction during the leap tau is drawn from a Poisson
+// distribution of mean a_j*tau, the propensities being considered constant
+// during the leap. The leap is halved when a population becomes negative,
+// and the method falls back to a step of the direct method when the leap is
+// smaller than a few mean times between two reactions.
+func (w *tauWorkspace) step(nw *network, rng *rand.Rand, tm float64, Xm []float64, Xn []float64) (float64, error) {
+	a0 := 0.0
+	for j := range nw.a {
+		nw.a[j] = nw.propensity(j, Xm)
+		a0 += nw.a[j]
+	}
+	if a0 <= 0 {
+		return math.Inf(1), nil
+	}
+	for tau := w.leap(nw, Xm); ; tau /= 2 {
+		if tau < 10/a0 {
+			nw.fire(choose(nw.a, a0, rng), 1, Xn)
+			return tm + exponential(rng)/a0, nil
+		}
+		for j, a := range nw.a {
+			nw.fire(j, poisson(a*tau, rng), Xn)
+		}
+		if !negative(Xn) {
+			return tm + tau, nil
+		}
+		nw.stats.RejectedSteps++
+		copy(Xn, Xm)
+	}
+}
+
+// negative returns true if a population of X is negative
+func negative(X []float64) bool {
+	for _, x := range X {
+		if x < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTauLeapingSimulator returns a Simulator that implements the tau-leaping
+// method with the selection of the leap of Cao, Gillespie and Petzold (2006).
+// The method is approximate (see the option Epsilon), but it simulates many
+// reactions per step, and is then much faster than the exact methods for the
+// large populations.
+func NewTauLeapingSimulator() Simulator {
+	w := tauWorkspace{}
+	return &StandardSimulator{method: "tau-leaping", start: w.start, step: w.step}
+}
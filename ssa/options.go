@@ -0,0 +1,96 @@
+package ssa
+
+import "github.com/gboulant/dingo-ode/solver"
+
+// settings gathers the parameters of a simulation. The parameters are
+// initialized with default values and then modified by the Option functions.
+type settings struct {
+	seed               int64
+	sampling           float64
+	epsilon            float64
+	checkInterval      uint64
+	resume             *solver.Checkpoint
+	checkpointInterval uint64
+	checkpointHandler  func(cp solver.Checkpoint) error
+}
+
+// Default values of the settings
+const (
+	defaultSeed    = 1
+	defaultEpsilon = 0.03
+)
+
+// Option defines a function that modifies the settings of a simulation
+type Option func(s *settings)
+
+// newSettings returns the settings defined by the default values modified by
+// the given options.
+func newSettings(opts ...Option) settings {
+	s := settings{seed: defaultSeed, epsilon: defaultEpsilon, checkInterval: solver.DefaultCheckInterval}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// Seed specifies the seed of the random generator. Two simulations with the
+// same seed are identical. The default seed is 1.
+func Seed(seed int64) Option {
+	return func(s *settings) {
+		s.seed = seed
+	}
+}
+
+// SampleEvery specifies that the states are recorded at the regular times
+// t0+k*dt (the state being constant between two reactions), instead of after
+// each reaction (the default). When the controller stops the simulation at a
+// reaction, it is requested at the sampling times before the reaction, and the
+// sampling stops at the first time it stops too (e.g. at tmax for the
+// controller StopAtTime).
+func SampleEvery(dt float64) Option {
+	return func(s *settings) {
+		s.sampling = dt
+	}
+}
+
+// Epsilon specifies the accuracy of the tau-leaping method, i.e. the maximal
+// relative change of the propensities during a leap. The default value is
+// 0.03.
+func Epsilon(epsilon float64) Option {
+	return func(s *settings) {
+		s.epsilon = epsilon
+	}
+}
+
+// CheckEvery specifies the number of iterations between two checks of the context
+// of a simulation (see solver.CheckEvery). The default value is
+// solver.DefaultCheckInterval, and a value of 0 is considered as 1.
+func CheckEvery(n uint64) Option {
+	return func(s *settings) {
+		if n == 0 {
+			n = 1
+		}
+		s.checkInterval = n
+	}
+}
+
+// ResumeFrom specifies that the simulation should be resumed from the given
+// checkpoint (see solver.ResumeFrom). The initial state and the state of the
+// random generator are replaced by the values of the checkpoint, so that the
+// resumed simulation draws the same random numbers as the original one (the
+// option Seed is then ignored).
+func ResumeFrom(cp solver.Checkpoint) Option {
+	return func(s *settings) {
+		s.resume = &cp
+	}
+}
+
+// CheckpointEvery specifies that a checkpoint of the simulation should be
+// created every n iterations and given to the handler function (see
+// solver.CheckpointEvery).
+func CheckpointEvery(n uint64, handler func(cp solver.Checkpoint) error) Option {
+	return func(s *settings) {
+		s.checkpointInterval = n
+		s.checkpointHandler = handler
+	}
+}
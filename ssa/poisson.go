@@ -0,0 +1,46 @@
+package ssa
+
+import (
+	"math"
+	"math/rand"
+)
+
+// poisson returns a random number with the Poisson distribution of mean lambda.
+// The numbers are generated by the multiplication of uniform numbers for the
+// small means, and by the transformed rejection method PTRS of Hörmann (1993)
+// for the large means.
+func poisson(lambda float64, rng *rand.Rand) float64 {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda < 10 {
+		limit := math.Exp(-lambda)
+		k := 0.0
+		for p := rng.Float64(); p > limit; p *= rng.Float64() {
+			k++
+		}
+		return k
+	}
+	slam := math.Sqrt(lambda)
+	loglam := math.Log(lambda)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := rng.Float64() - 0.5
+		v := rng.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return k
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/(us*us)+b) <= -lambda+k*loglam-lg {
+			return k
+		}
+	}
+}
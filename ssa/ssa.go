@@ -0,0 +1,482 @@
+// Package ssa implements the stochastic simulation algorithms (SSA) of the
+// discrete reaction networks, i.e. the systems whose state is a vector of
+// populations (numbers of individuals or of molecules) that change by the
+// random occurrences of reactions. For the small populations, the continuous
+// ODE models (e.g. the Volterra equations) are no longer valid, and the
+// evolution is a Markov jump process simulated by the Gillespie algorithms.
+//
+// The simulators follow the conventions of the package solver: the process is
+// driven by a solver.Controller and the states are recorded by a
+// solver.Recorder (e.g. a solver.RecorderTimeSeries).
+package ssa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gboulant/dingo-ode/internal/core"
+	"github.com/gboulant/dingo-ode/internal/random"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// PropensityFunction defines the propensity a(X) of a reaction, i.e. the
+// probability per unit of time that the reaction occurs in the state X.
+type PropensityFunction func(X []float64) float64
+
+// Reaction defines a reaction of a network by its stoichiometry: each
+// occurrence of the reaction removes one individual of each species of the
+// Reactants, and adds one individual of each species of the Products (a
+// species is repeated to remove or add several individuals, e.g. {0,0} for
+// 2A). The species are identified by their index in the state vector.
+//
+// The propensity of the reaction follows the law of mass action by default:
+// a(X) = Rate*prod(C(X_s,k_s)), where k_s is the number of individuals of the
+// species s among the reactants, and C the binomial coefficient. A custom
+// Propensity function can be given instead, depending on the species
+// DependsOn (all the species if nil).
+type Reaction struct {
+	Name       string
+	Reactants  []int
+	Products   []int
+	Rate       float64
+	Propensity PropensityFunction
+	DependsOn  []int
+}
+
+// Network defines a reaction network of n species
+type Network struct {
+	Species   int // number of species (size of the state vector)
+	Reactions []Reaction
+}
+
+// network is the compiled form of a Network used by the simulators
+type network struct {
+	n         int
+	reactions []Reaction
+	order     [][2]int    // species and multiplicity of the reactants (mass action)
+	species   [][]int     // species changed by each reaction
+	changes   [][]float64 // changes of the species
+	dependent [][]int     // reactions whose propensity is changed by each reaction
+	a         []float64   // propensities of the reactions
+	stats     *solver.Stats
+}
+
+// compile checks the network and computes its stoichiometry and the graph of
+// the dependencies between the reactions.
+func compile(net Network, stats *solver.Stats) (*network, error) {
+	if net.Species <= 0 || len(net.Reactions) == 0 {
+		err := errors.New("the network should have species and reactions")
+		return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+	}
+	m := len(net.Reactions)
+	nw := network{
+		n:         net.Species,
+		reactions: net.Reactions,
+		species:   make([][]int, m),
+		changes:   make([][]float64, m),
+		dependent: make([][]int, m),
+		a:         make([]float64, m),
+		stats:     stats,
+	}
+	reads := make([]map[int]bool, m) // species read by the propensity of each reaction
+	for j, r := range net.Reactions {
+		change := make(map[int]float64)
+		reads[j] = make(map[int]bool)
+		for _, s := range r.Reactants {
+			if s < 0 || s >= nw.n {
+				err := fmt.Errorf("the species %d of the reaction %d (%s) is not defined", s, j, r.Name)
+				return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+			}
+			change[s]--
+			reads[j][s] = true
+		}
+		for _, s := range r.Products {
+			if s < 0 || s >= nw.n {
+				err := fmt.Errorf("the species %d of the reaction %d (%s) is not defined", s, j, r.Name)
+				return nil, core.NewError(solver.ErrInvalidInput, err, 0, nil, 0)
+			}
+			change[s]++
+		}
+		for s := 0; s < nw.n; s++ {
+			if c := change[s]; c != 0 {
+				nw.species[j] = append(nw.species[j], s)
+				nw.changes[j] = append(nw.changes[j], c)
+			}
+		}
+		if r.Propensity != nil {
+			reads[j] = make(map[int]bool)
+			for _, s := range r.DependsOn {
+				reads[j][s] = true
+			}
+			if r.DependsOn == nil {
+				for s := 0; s < nw.n; s++ {
+					reads[j][s] = true
+				}
+			}
+		}
+	}
+	for j := range net.Reactions {
+		for k := range net.Reactions {
+			for _, s := range nw.species[j] {
+				if reads[k][s] {
+					nw.dependent[j] = append(nw.dependent[j], k)
+					break
+				}
+			}
+		}
+	}
+	return &nw, nil
+}
+
+// propensity computes the propensity of the reaction j in the state X
+func (nw *network) propensity(j int, X []float64) float64 {
+	nw.stats.FunctionEvaluations++
+	r := &nw.reactions[j]
+	if r.Propensity != nil {
+		return r.Propensity(X)
+	}
+	a := r.Rate
+	for i, s := range r.Reactants {
+		// The binomial coefficient C(x,k) is computed as the product of the
+		// terms (x-l)/(l+1), where l counts the previous occurrences of s.
+		l := 0
+		for _, p := range r.Reactants[:i] {
+			if p == s {
+				l++
+			}
+		}
+		a *= math.Max(X[s]-float64(l), 0) / float64(l+1)
+	}
+	return a
+}
+
+// fire applies count occurrences of the reaction j to the state X
+func (nw *network) fire(j int, count float64, X []float64) {
+	for i, s := range nw.species[j] {
+		X[s] += count * nw.changes[j][i]
+	}
+}
+
+// stepFunction defines a function that implements a step of a simulation
+// method: the state Xn at the time of the next reaction (or of the next leap)
+// is computed from the state Xm at time tm. The function returns the time of
+// the step, +Inf if no reaction can occur anymore.
+type stepFunction func(nw *network, rng *rand.Rand, tm float64, Xm []float64, Xn []float64) (float64, error)
+
+// startFunction defines a function that initializes the workspace of a
+// simulation method at the initial state (t0,X0), with the settings of the
+// simulation.
+type startFunction func(nw *network, rng *rand.Rand, config settings, t0 float64, X0 []float64)
+
+// stateFunction defines a function that returns the values of the workspace of
+// a simulation method that can not be computed from the state (e.g. the
+// putative times of the reactions), at the end of the last step, or at its
+// beginning if rewind is true. The values are saved in the checkpoints.
+type stateFunction func(rewind bool) []float64
+
+// restoreFunction defines a function that initializes the workspace of a
+// simulation method at the state (t0,X0) from the values given by its
+// stateFunction. The methods with no restore function are initialized by
+// their start function, that must not draw random numbers then.
+type restoreFunction func(nw *network, config settings, t0 float64, X0 []float64, values []float64) error
+
+// Simulator is the interface implemented by the stochastic simulators
+type Simulator interface {
+	// Simulate simulates the network from the initial state (t0,X0), stopping
+	// the process when the controller c requests it (or when no reaction can
+	// occur anymore), and recording the states with the recorder r. The
+	// random numbers are generated from a seed (see the option Seed), so that
+	// a simulation is reproducible.
+	Simulate(net Network, t0 float64, X0 []float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error)
+	// SimulateContext is the same as Simulate, but the process is aborted
+	// when the context is canceled or when its deadline is exceeded.
+	SimulateContext(ctx context.Context, net Network, t0 float64, X0 []float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error)
+	// Result returns the values of t and X obtained at the end of the
+	// simulation. As for the solvers, when the controller stops the process,
+	// the result is the state before the step that triggers the stop. For a
+	// sampled simulation (see SampleEvery), the result is the state at the
+	// last sampling time accepted by the controller (the state at the time
+	// tmax for the controller StopAtTime and a sampling time step that
+	// divides tmax-t0).
+	Result() (t float64, X []float64)
+	// Stats returns the statistics of the last simulation: the accepted steps
+	// are the reactions (or the leaps), and the function evaluations are the
+	// evaluations of the propensities.
+	Stats() solver.Stats
+	// Extinct returns true if the last simulation stopped because no
+	// reaction could occur anymore (all the propensities are zero).
+	Extinct() bool
+	// Checkpoint returns a checkpoint of the state reached at the end of the
+	// last simulation (see Result), with the state of the random generator,
+	// that can be used to resume the simulation later on (see the option
+	// ResumeFrom). The time of the checkpoint is the time of the step that
+	// leads to this state, that can be before the time of the result for a
+	// sampled simulation.
+	Checkpoint() solver.Checkpoint
+}
+
+// StandardSimulator implements the interface Simulator with a step function
+// (to be defined) applied at each step of the simulation, and an optional
+// start function that initializes the method.
+type StandardSimulator struct {
+	method     string // name of the method, for the checkpoints
+	start      startFunction
+	step       stepFunction
+	state      stateFunction
+	restore    restoreFunction
+	t          float64 // time of the result state (see Result)
+	tstep      float64 // time of the step that leads to the result state
+	X          []float64
+	iterations uint64
+	records    uint64
+	seed       int64     // seed of the random generator
+	draws      uint64    // random numbers drawn to reach the result
+	history    []float64 // next sampling time, and values of the method
+	stats      solver.Stats
+	extinct    bool
+	xm, xn     []float64
+}
+
+// Simulate implements the Simulator interface
+func (sim *StandardSimulator) Simulate(net Network, t0 float64, X0 []float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	return sim.SimulateContext(context.Background(), net, t0, X0, c, r, opts...)
+}
+
+// SimulateContext implements the Simulator interface
+func (sim *StandardSimulator) SimulateContext(ctx context.Context, net Network, t0 float64, X0 []float64, c solver.ProcessController, r solver.Recorder, opts ...Option) (uint64, error) {
+	if c == nil {
+		err := errors.New("the controller c is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, 0)
+	}
+	if r == nil {
+		r = &solver.RecorderNone{}
+	}
+	config := newSettings(opts...)
+	if config.checkpointInterval > 0 && config.checkpointHandler == nil {
+		err := errors.New("the checkpoint handler is not defined")
+		return 0, core.NewError(solver.ErrInvalidInput, err, t0, X0, 0)
+	}
+	cp := config.resume
+	if cp != nil {
+		if cp.Method != sim.method {
+			err := fmt.Errorf("the checkpoint was created with the method %s instead of %s", cp.Method, sim.method)
+			return 0, core.NewError(solver.ErrInvalidInput, err, cp.T, cp.X, 0)
+		}
+		// The history holds the next sampling time, followed by the
+		// values of the method (see stateFunction).
+		if len(cp.History) == 0 || len(cp.History[0]) != 1 {
+			err := errors.New("the checkpoint has no sampling time")
+			return 0, core.NewError(solver.ErrInvalidInput, err, cp.T, cp.X, 0)
+		}
+		t0, X0 = cp.T, cp.X
+	}
+	if len(X0) != net.Species {
+		err := fmt.Errorf("the initial state has %d values for %d species", len(X0), net.Species)
+		return 0, core.NewError(solver.ErrDimensionMismatch, err, t0, X0, 0)
+	}
+	start := time.Now()
+	sim.stats = solver.Stats{}
+	sim.extinct = false
+	nw, err := compile(net, &sim.stats)
+	if err != nil {
+		return 0, err
+	}
+	src := random.New(config.seed)
+	if cp != nil {
+		src = random.Restore(cp.Seed, cp.Draws)
+	}
+	rng := rand.New(src)
+
+	n := len(X0)
+	sim.xm = core.Resize(sim.xm, n)
+	sim.xn = core.Resize(sim.xn, n)
+	tm, Xm, Xn := t0, sim.xm, sim.xn
+	copy(Xm, X0)
+	var iterations, records uint64 = 0, 0
+	next := t0 // next sampling time
+
+	// When the process stops during a step, the final state is the state at
+	// the beginning of the step, and the checkpoint of the final state is
+	// rewound to the beginning of the step.
+	stepping := false
+	var drawsBefore, recordsBefore uint64
+	var nextBefore float64
+	last := math.Inf(-1) // last sampling time accepted by the controller
+	defer func() {
+		sim.t, sim.tstep = math.Max(tm, last), tm
+		sim.X = core.Resize(sim.X, n)
+		copy(sim.X, Xm)
+		sim.seed, sim.draws = src.State()
+		sim.iterations, sim.records = iterations, records
+		sim.history = append(sim.history[:0], next)
+		if stepping {
+			sim.draws, sim.records, sim.history[0] = drawsBefore, recordsBefore, nextBefore
+		}
+		if sim.state != nil {
+			sim.history = append(sim.history, sim.state(stepping)...)
+		}
+		sim.stats.WallTime = time.Since(start)
+	}()
+
+	if cp == nil {
+		if sim.start != nil {
+			sim.start(nw, rng, config, tm, Xm)
+		}
+		r.Record(tm, Xm)
+		records++
+		next += config.sampling
+	} else {
+		var values []float64
+		if len(cp.History) > 1 {
+			values = cp.History[1]
+		}
+		if sim.restore != nil {
+			if err := sim.restore(nw, config, tm, Xm, values); err != nil {
+				return 0, err
+			}
+		} else if sim.start != nil {
+			sim.start(nw, rng, config, tm, Xm)
+		}
+		next = cp.History[0][0]
+		iterations, records = cp.Iterations, cp.Records
+		if seeker, ok := r.(solver.RecorderSeeker); ok {
+			if err := seeker.Seek(records); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// The Process of a checkpoint created during the simulation has already
+	// requested the controller at the checkpoint state.
+	process := solver.NewProcess(t0, 1)
+	if cp != nil && cp.Process != nil {
+		process = solver.RestoreProcess(*cp.Process, 1)
+	} else if stop, err := process.Request(c, tm, Xm); stop || err != nil {
+		if err != nil {
+			err = core.NewError(solver.ErrFunction, err, tm, Xm, 0)
+		}
+		return iterations, err
+	}
+	for {
+		if iterations%config.checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return iterations, core.NewError(solver.ErrInterrupted, err, tm, Xm, 0)
+			}
+		}
+		_, drawsBefore = src.State()
+		recordsBefore, nextBefore = records, next
+		stepping = true
+		copy(Xn, Xm)
+		tn, err := sim.step(nw, rng, tm, Xm, Xn)
+		if err != nil {
+			return iterations, core.NewError(solver.ErrFunction, err, tm, Xm, 0)
+		}
+		if math.IsInf(tn, 1) {
+			sim.extinct = true
+			return iterations, nil
+		}
+		sim.stats.AcceptedSteps++
+
+		// The state is Xm until the time tn of the step. When the controller
+		// stops the process at the step, the sampling times of the step are
+		// recorded until the first one that the controller stops too.
+		if config.sampling == 0 {
+			r.Record(tn, Xn)
+			records++
+		}
+		stop, err := process.Request(c, tn, Xn)
+		if err != nil {
+			return iterations, core.NewError(solver.ErrFunction, err, tn, Xn, 0)
+		}
+		for ; config.sampling > 0 && next < tn; next += config.sampling {
+			if stop {
+				stopped, err := process.Request(c, next, Xm)
+				if err != nil {
+					return iterations, core.NewError(solver.ErrFunction, err, next, Xm, 0)
+				}
+				if stopped {
+					break
+				}
+			}
+			r.Record(next, Xm)
+			records++
+			last = next
+		}
+		if stop {
+			return iterations, nil
+		}
+		tm, Xm, Xn = tn, Xn, Xm
+		iterations++
+		stepping = false
+
+		if config.checkpointInterval > 0 && iterations%config.checkpointInterval == 0 {
+			cp := sim.checkpoint(tm, Xm, iterations, records, src, next)
+			state := process.Save()
+			cp.Process = &state
+			if err := config.checkpointHandler(cp); err != nil {
+				return iterations, core.NewError(solver.ErrFunction, err, tm, Xm, 0)
+			}
+		}
+	}
+}
+
+// checkpoint creates a checkpoint of the simulation at the end of the last
+// step, with a copy of the given state
+func (sim *StandardSimulator) checkpoint(t float64, X []float64, iterations, records uint64, src *random.Source, next float64) solver.Checkpoint {
+	cp := solver.Checkpoint{
+		Version:    solver.CheckpointVersion,
+		Method:     sim.method,
+		T:          t,
+		X:          append([]float64(nil), X...),
+		Iterations: iterations,
+		Records:    records,
+		History:    [][]float64{{next}},
+	}
+	cp.Seed, cp.Draws = src.State()
+	if sim.state != nil {
+		cp.History = append(cp.History, sim.state(false))
+	}
+	return cp
+}
+
+// Result implements the Simulator interface
+func (sim *StandardSimulator) Result() (t float64, X []float64) {
+	X = make([]float64, len(sim.X))
+	copy(X, sim.X)
+	return sim.t, X
+}
+
+// Stats implements the Simulator interface
+func (sim *StandardSimulator) Stats() solver.Stats {
+	return sim.stats
+}
+
+// Extinct implements the Simulator interface
+func (sim *StandardSimulator) Extinct() bool {
+	return sim.extinct
+}
+
+// Checkpoint implements the Simulator interface
+func (sim *StandardSimulator) Checkpoint() solver.Checkpoint {
+	cp := solver.Checkpoint{
+		Version:    solver.CheckpointVersion,
+		Method:     sim.method,
+		T:          sim.tstep,
+		X:          append([]float64(nil), sim.X...),
+		Iterations: sim.iterations,
+		Records:    sim.records,
+		Seed:       sim.seed,
+		Draws:      sim.draws,
+	}
+	if len(sim.history) > 0 {
+		cp.History = [][]float64{{sim.history[0]}}
+	}
+	if len(sim.history) > 1 {
+		cp.History = append(cp.History, append([]float64(nil), sim.history[1:]...))
+	}
+	return cp
+}
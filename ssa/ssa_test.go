@@ -0,0 +1,48 @@
+package ssa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+// birthDeath is a slow birth-death process, whose reactions are a few seconds
+// apart
+var birthDeath = Network{
+	Species: 1,
+	Reactions: []Reaction{
+		{Name: "birth", Products: []int{0}, Rate: 0.2},
+		{Name: "death", Reactants: []int{0}, Rate: 0.1},
+	},
+}
+
+// TestSampleEvery checks that the sampling of a simulation stops at the time
+// tmax of the controller StopAtTime, and that the result is the state at tmax.
+func TestSampleEvery(t *testing.T) {
+	X0 := []float64{2}
+	simulators := map[string]Simulator{
+		"direct":        NewDirectSimulator(),
+		"next reaction": NewNextReactionSimulator(),
+		"tau-leaping":   NewTauLeapingSimulator(),
+	}
+	for name, sim := range simulators {
+		var recorder solver.RecorderTimeSeries
+		if _, err := sim.Simulate(birthDeath, 0, X0, solver.StopAtTime(10), &recorder, Seed(5), SampleEvery(1)); err != nil {
+			t.Fatal(err)
+		}
+		series := recorder.Series
+		for k, data := range series {
+			if data.GetTime() != float64(k) {
+				t.Fatalf("%s: the record %d is at t=%g", name, k, data.GetTime())
+			}
+		}
+		if len(series) != 11 {
+			t.Fatalf("%s: %d states are recorded instead of 11 (t=0 to t=10)", name, len(series))
+		}
+		tr, Xr := sim.Result()
+		if last := series[len(series)-1]; tr != 10 || !reflect.DeepEqual(Xr, last.GetState()) {
+			t.Errorf("%s: the result is (%g,%v) instead of (10,%v)", name, tr, Xr, last.GetState())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/ad"
+	"github.com/gboulant/dingo-ode/jacobian"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The derivatives of the laser system are computed exactly with the dual numbers
+(package ad), from the rate function written as a generic function. The
+Jacobian matrix of the laser system is:
+
+	     | 0           1             m*sin(Z) |
+	J =  | -g*D*exp(L) -g*(1+exp(L)) 0        |
+	     | 0           0             0        |
+
+whose exponential terms are poorly approximated by finite differences for the
+large intensities L.
+
+Without modulation (m=0), the laser has the equilibrium state D=1, L=ln(a-1),
+whose Jacobian matrix (restricted to (L,D)) has the eigenvalues:
+
+	lambda = -g*a/2 ± i*sqrt(g*(a-1) - (g*a/2)^2)
+
+i.e. the laser relaxes to the equilibrium with damped oscillations (the
+relaxation oscillations).
+
+The sensitivity S = ∂X(t)/∂L0 of the trajectory to the initial intensity L0
+is the solution of the variational equation dS/dt = J(X).S, S(0) = (1,0,0),
+solved with the state X, where J.S is the directional derivative of f.
+
+*/
+
+// laserRate returns the rate function of the laser system as a generic
+// function, that can be evaluated with the real and the dual numbers
+func laserRate[T ad.Number[T]](dynsys LaserSystem) ad.Function[T] {
+	return func(t float64, X []T, dXdt []T) error {
+		L, D, Z := X[0], X[1], X[2]
+		dXdt[0] = D.Shift(-1).Sub(Z.Cos().Scale(dynsys.M))
+		dXdt[1] = D.Mul(L.Exp().Shift(1)).Neg().Shift(dynsys.A).Scale(dynsys.G)
+		dXdt[2] = Z.Const(dynsys.W)
+		return nil
+	}
+}
+
+// jacobian returns the analytic Jacobian matrix of the laser system at X
+func (dynsys LaserSystem) jacobian(X []float64) *linalg.Matrix {
+	L, D, Z := X[0], X[1], X[2]
+	J := linalg.NewMatrix(3, 3)
+	J.Set(0, 1, 1)
+	J.Set(0, 2, dynsys.M*math.Sin(Z))
+	J.Set(1, 0, -dynsys.G*D*math.Exp(L))
+	J.Set(1, 1, -dynsys.G*(1+math.Exp(L)))
+	return J
+}
+
+// DemoLaserDerivatives compares the Jacobian matrices of the laser system
+// computed with the dual numbers and with finite differences, computes the
+// relaxation oscillations of the laser from the eigenvalues of its Jacobian
+// matrix, the sensitivity of a trajectory to the initial intensity, and uses
+// the exact Jacobian matrix with an implicit solver.
+func DemoLaserDerivatives(postpro bool) error {
+	dynsys := configurations["chaos"]
+	f := ad.InPlace(laserRate[ad.Real](dynsys))
+	differentiator, err := ad.NewDifferentiator(laserRate[ad.Dual](dynsys))
+	if err != nil {
+		return err
+	}
+	estimator, err := jacobian.NewDense(f, 3)
+	if err != nil {
+		return err
+	}
+
+	// The generic function gives the same rates as the function F
+	X := []float64{1, 1, 0.5}
+	F, err := dynsys.F(0, X)
+	if err != nil {
+		return err
+	}
+	dXdt := make([]float64, 3)
+	if err := f(0, X, dXdt); err != nil {
+		return err
+	}
+	for i := range F {
+		if F[i] != dXdt[i] {
+			return fmt.Errorf("ERR: the generic rate function should give the same rates as F")
+		}
+	}
+
+	// Jacobian matrices for increasing intensities
+	relative := func(J, Jref *linalg.Matrix) float64 {
+		e := 0.0
+		for i := range J.Data {
+			if Jref.Data[i] != 0 {
+				e = math.Max(e, math.Abs(J.Data[i]-Jref.Data[i])/math.Abs(Jref.Data[i]))
+			}
+		}
+		return e
+	}
+	for _, L := range []float64{0, 10, 20} {
+		X := []float64{L, 1, 0.5}
+		Jref := dynsys.jacobian(X)
+		Jad, Jfd := linalg.NewMatrix(3, 3), linalg.NewMatrix(3, 3)
+		if err := differentiator.Jacobian(0, X, Jad); err != nil {
+			return err
+		}
+		if err := estimator.Jacobian(0, X, Jfd); err != nil {
+			return err
+		}
+		ead, efd := relative(Jad, Jref), relative(Jfd, Jref)
+		log.Printf("L=%-3g: relative error of the jacobian matrix %.1e (dual numbers), %.1e (finite differences)\n", L, ead, efd)
+		if ead > 1e-14 {
+			return fmt.Errorf("ERR: the Jacobian matrix computed with the dual numbers should be exact")
+		}
+	}
+
+	// Stability of the equilibrium of the laser without modulation
+	free := dynsys
+	free.M = 0
+	d, err := ad.NewDifferentiator(laserRate[ad.Dual](free))
+	if err != nil {
+		return err
+	}
+	J := linalg.NewMatrix(3, 3)
+	if err := d.Jacobian(0, []float64{math.Log(free.A - 1), 1, 0}, J); err != nil {
+		return err
+	}
+	trace := J.At(0, 0) + J.At(1, 1)
+	det := J.At(0, 0)*J.At(1, 1) - J.At(0, 1)*J.At(1, 0)
+	delta := trace*trace/4 - det
+	if !(trace < 0 && delta < 0) {
+		return fmt.Errorf("ERR: the equilibrium of the laser should be a stable focus")
+	}
+	omega := math.Sqrt(-delta)
+	log.Printf("equilibrium: eigenvalues %.4e ± %.4e i (relaxation oscillations of period %.1f)\n", trace/2, omega, 2*math.Pi/omega)
+	if expected := math.Sqrt(free.G*(free.A-1) - free.G*free.A*free.G*free.A/4); math.Abs(omega-expected) > 1e-12 {
+		return fmt.Errorf("ERR: the pulsation of the relaxation oscillations should be %g", expected)
+	}
+
+	// Sensitivity of the trajectory to the initial intensity
+	variational := func(t float64, Y []float64, dYdt []float64) error {
+		return differentiator.Directional(t, Y[:3], Y[3:], dYdt[:3], dYdt[3:])
+	}
+	T := 2 * math.Pi / dynsys.W
+	h, tmax := T/400, 2*T
+	algo := solver.NewRK4Solver()
+	X0 := []float64{1, 1, 0}
+	if _, err := algo.SolveInPlace(variational, 0, append(X0, 1, 0, 0), h, solver.StopAtTime(tmax), nil); err != nil {
+		return err
+	}
+	_, Y := algo.Result()
+	final := func(L0 float64) ([]float64, error) {
+		_, err := algo.SolveInPlace(f, 0, []float64{L0, X0[1], X0[2]}, h, solver.StopAtTime(tmax), nil)
+		_, X := algo.Result()
+		return X, err
+	}
+	dL := 1e-6
+	Xp, err := final(X0[0] + dL)
+	if err != nil {
+		return err
+	}
+	Xm, err := final(X0[0] - dL)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		s := (Xp[i] - Xm[i]) / (2 * dL)
+		log.Printf("sensitivity of X[%d] to L0 at t=%.0f: %.8f (variational), %.8f (finite differences)\n", i, tmax, Y[3+i], s)
+		if math.Abs(Y[3+i]-s) > 1e-6*math.Max(1, math.Abs(s)) {
+			return fmt.Errorf("ERR: the sensitivity of the trajectory should match the finite differences")
+		}
+	}
+
+	// Implicit solver with the exact Jacobian matrix
+	implicit := solver.NewSDIRK2Solver()
+	p := solver.MassMatrixProblem{F: f, Jacobian: differentiator.Jacobian}
+	var recorder solver.RecorderTimeSeries
+	if _, err := implicit.SolveMassMatrix(p, 0, X0, T/40, solver.StopAtTime(10*T), &recorder); err != nil {
+		return err
+	}
+	log.Printf("SDIRK2 with the exact jacobian matrices:\n%s", implicit.Stats())
+	return recorder.Series.ToCSVwithNames("out.laser_ad_data.csv", []string{"L", "D", "Z"})
+}
@@ -0,0 +1,112 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The blow up system is the simplest example of an equation whose solution
+diverges in a finite time:
+
+	dx/dt = x^2
+
+The analytical solution for an initial condition like (t0=0,x=x0) is:
+
+	x = x0/(1 - x0*t)
+
+that goes to infinity at the time tb = 1/x0. The system is used to illustrate
+the handling of the failures of a solving process.
+
+*/
+
+// BlowUpSystem modelises the equation dx/dt = x^2. The rate function returns
+// the error errBlowUp when x exceeds the value xmax.
+type BlowUpSystem struct {
+	xmax float64
+}
+
+// errBlowUp is the error returned by the BlowUpSystem when the solution
+// diverges.
+var errBlowUp = errors.New("ERR: x exceeds the limit of the blow up system")
+
+// F implements the function f of the blow up system
+func (system BlowUpSystem) F(t float64, X []float64) ([]float64, error) {
+	if X[0] > system.xmax {
+		return nil, errBlowUp
+	}
+	return []float64{X[0] * X[0]}, nil
+}
+
+func (system BlowUpSystem) GetDefaultInput() (t0 float64, X0 []float64, step float64, tmax float64) {
+	t0 = 0.0
+	X0 = []float64{1.0}
+	step = 1e-3
+	tmax = 2.0
+	return
+}
+
+// DemoBlowUp solves the blow up system beyond the time of the divergence, and
+// analyzes the errors returned by the solver: the error of the rate function
+// and the error of a solving process limited in number of steps.
+func DemoBlowUp(postpro bool) error {
+	blowup := BlowUpSystem{xmax: 1e6}
+	t0, X0, step, tmax := blowup.GetDefaultInput()
+	syssolver := NewSystemSolver(blowup)
+
+	// The rate function fails near the time of the divergence
+	err := syssolver.Solve(t0, X0, step, tmax)
+	var failure *solver.Error
+	if !errors.Is(err, solver.ErrFunction) || !errors.Is(err, errBlowUp) || !errors.As(err, &failure) {
+		return fmt.Errorf("ERR: the error %v should wrap the error of the rate function", err)
+	}
+	log.Printf("The solving process fails at t=%.4f with x=%.4g and h=%g\n", failure.T, failure.X[0], failure.H)
+	if tb := 1 / X0[0]; math.Abs(failure.T-tb) > 10*step {
+		return fmt.Errorf("ERR: the failure at t=%g is too far from the blow up time %g", failure.T, tb)
+	}
+
+	// The solving process stops after 100 steps
+	err = syssolver.Solve(t0, X0, step, tmax, solver.MaxSteps(100))
+	if !errors.Is(err, solver.ErrMaxSteps) || !errors.As(err, &failure) {
+		return fmt.Errorf("ERR: the error %v should be of kind ErrMaxSteps", err)
+	}
+	log.Printf("The solving process stops at t=%.4f: %v\n", failure.T, err)
+	return nil
+}
+
+// DemoBlowUpGuards solves the blow up system (with no limit in the rate
+// function) beyond the time of the divergence, with the different guard
+// controllers of the solver package. Each guard should stop the solving
+// process before the time tmax, with an error or with a clean stop.
+func DemoBlowUpGuards(postpro bool) error {
+	blowup := BlowUpSystem{xmax: math.Inf(1)}
+	t0, X0, step, tmax := blowup.GetDefaultInput()
+	algo := solver.NewRK4Solver()
+
+	guards := []struct {
+		name       string
+		controller solver.ProcessController
+		kind       error // kind of the expected error, nil for a clean stop
+	}{
+		{"non finite", solver.StopOnNonFinite(), solver.ErrNonFinite},
+		{"outside box", solver.StopOutsideBox([]float64{0}, []float64{1e3}), solver.ErrOutOfBounds},
+		{"norm exceeding", solver.StopOnNormExceeding(1e3), solver.ErrDivergence},
+		{"max iterations", solver.StopAtMaxIterations(100), nil},
+		{"wall clock", solver.StopAfterWallClock(0), nil},
+	}
+	for _, guard := range guards {
+		controller := solver.MultiController(solver.StopAtTime(tmax), guard.controller)
+		_, err := algo.Solve(blowup.F, t0, X0, step, controller, nil)
+		t, X := algo.Result()
+		log.Printf("%-15s: stop at t=%.4f with x=%.4g (%v)\n", guard.name, t, X[0], err)
+		if t >= tmax || (guard.kind == nil && err != nil) || (guard.kind != nil && !errors.Is(err, guard.kind)) {
+			return fmt.Errorf("ERR: the guard %s does not stop the process as expected", guard.name)
+		}
+	}
+	return nil
+}
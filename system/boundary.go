@@ -0,0 +1,254 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/bvp"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The boundary value problems (BVP) define the solution of an ODE system on an
+interval [a,b] by conditions on its ends, instead of an initial state.
+
+The Bratu problem models the steady temperature profile y(x) in a slab with an
+exothermic reaction, whose walls are at the temperature 0:
+
+ y'' + lambda*exp(y) = 0, y(0) = y(1) = 0
+
+For lambda < 3.51, the problem has two solutions, whose lower one is:
+
+ y(x) = -2*ln(cosh((x-1/2)*theta/2)/cosh(theta/4))
+
+where theta is the lowest solution of theta = sqrt(2*lambda)*cosh(theta/4).
+
+The periodic regime of the forced damped spring m*x'' = -k*x - a*x' +
+F*cos(w*t) is the solution on a period T = 2*PI/w such that X(0) = X(T). It is
+the harmonic response x(t) = Re(F*exp(i*w*t)/(k - m*w^2 + i*a*w)).
+
+*/
+
+// BratuSystem defines the Bratu problem of parameter lambda
+type BratuSystem struct {
+	lambda float64
+}
+
+// F implements the function f of the Bratu problem for the state X=(y,y')
+func (system BratuSystem) F(x float64, X []float64) ([]float64, error) {
+	return []float64{X[1], -system.lambda * math.Exp(X[0])}, nil
+}
+
+// BC implements the boundary conditions y(0) = y(1) = 0
+func (system BratuSystem) BC(Xa, Xb []float64, R []float64) error {
+	R[0] = Xa[0]
+	R[1] = Xb[0]
+	return nil
+}
+
+// solution returns the analytic lower solution y(x) of the Bratu problem
+func (system BratuSystem) solution() func(x float64) float64 {
+	theta := 1.0
+	for i := 0; i < 100; i++ {
+		theta = math.Sqrt(2*system.lambda) * math.Cosh(theta/4)
+	}
+	return func(x float64) float64 {
+		return -2 * math.Log(math.Cosh((x-0.5)*theta/2)/math.Cosh(theta/4))
+	}
+}
+
+// DemoBratu solves the Bratu problem with the single and the multiple shooting
+// methods, and compares the solutions to the analytic solution.
+func DemoBratu(postpro bool) error {
+	system := BratuSystem{lambda: 1}
+	problem := bvp.Problem{F: system.F, BC: system.BC, A: 0, B: 1}
+	y := system.solution()
+
+	methods := []struct {
+		name  string
+		algo  *bvp.ShootingSolver
+		label string
+	}{
+		{"single shooting", bvp.NewShootingSolver(solver.NewRK4Solver(), 0.01), "single"},
+		{"multiple shooting", bvp.NewMultipleShootingSolver(solver.NewRK4Solver(), 0.01, 4), "multiple"},
+	}
+	for _, method := range methods {
+		err := method.algo.Solve(problem, bvp.ConstantGuess([]float64{0, 0}))
+		if err != nil {
+			return err
+		}
+		maxError := 0.0
+		series := method.algo.Solution()
+		for _, data := range series {
+			maxError = math.Max(maxError, math.Abs(data.GetState()[0]-y(data.GetTime())))
+		}
+		log.Printf("%-17s: y'(0)=%.8f, max error %.3e (%s)\n", method.name, method.algo.Initial()[1], maxError, method.algo.Stats())
+		if maxError > 1e-8 {
+			return fmt.Errorf("ERR: the %s solution differs from the analytic solution (%.3e)", method.name, maxError)
+		}
+		series.ToCSVwithNames(fmt.Sprintf("out.bratu_%s_data.csv", method.label), []string{"y", "dy"})
+	}
+	return nil
+}
+
+// ForcedSpringSystem defines the damped spring forced by the periodic force
+// F*cos(w*t)
+type ForcedSpringSystem struct {
+	SpringSystem
+	F, w float64
+}
+
+// f implements the function f of the forced spring system
+func (dynsys ForcedSpringSystem) f(t float64, X []float64) ([]float64, error) {
+	slope, err := dynsys.SpringSystem.f(t, X)
+	if err != nil {
+		return nil, err
+	}
+	slope[1] += dynsys.F * math.Cos(dynsys.w*t) / dynsys.m
+	return slope, nil
+}
+
+// DemoPeriodicSpring computes the periodic regime of the forced spring as the
+// BVP with the periodic conditions X(0)=X(T), solved with the multiple
+// shooting method, and compares it to the harmonic response.
+func DemoPeriodicSpring(postpro bool) error {
+	dynsys := ForcedSpringSystem{
+		SpringSystem: SpringSystem{k: 2.0, m: 1.0, a: 0.1},
+		F:            0.5,
+		w:            1.2,
+	}
+	T := 2 * math.Pi / dynsys.w
+	problem := bvp.Problem{
+		F: dynsys.f,
+		BC: func(Xa, Xb []float64, R []float64) error {
+			R[0] = Xb[0] - Xa[0]
+			R[1] = Xb[1] - Xa[1]
+			return nil
+		},
+		A: 0,
+		B: T,
+	}
+	algo := bvp.NewMultipleShootingSolver(solver.NewRK4Solver(), T/200, 3)
+	err := algo.Solve(problem, bvp.ConstantGuess([]float64{0, 0}))
+	if err != nil {
+		return err
+	}
+
+	// Harmonic response X(0) = Re(F/z) and V(0) = Re(i*w*F/z) with z = k-m*w^2+i*a*w
+	re, im := dynsys.k-dynsys.m*dynsys.w*dynsys.w, dynsys.a*dynsys.w
+	d2 := re*re + im*im
+	x0 := dynsys.F * re / d2
+	v0 := dynsys.w * dynsys.F * im / d2
+	X0 := algo.Initial()
+	log.Printf("periodic regime: X(0)=(%.8f, %.8f), harmonic response: (%.8f, %.8f) (%s)\n", X0[0], X0[1], x0, v0, algo.Stats())
+	if math.Abs(X0[0]-x0) > 1e-6 || math.Abs(X0[1]-v0) > 1e-6 {
+		return fmt.Errorf("ERR: the periodic regime differs from the harmonic response")
+	}
+	return algo.Solution().ToCSVwithNames("out.spring_periodic_data.csv", []string{"x", "v"})
+}
+
+// TroeschSystem defines the Troesch problem y” = mu*sinh(mu*y), y(0)=0,
+// y(1)=1, whose solutions of the initial value problems blow up for the large
+// values of y'(0), so that the shooting methods fail.
+type TroeschSystem struct {
+	mu float64
+}
+
+// F implements the function f of the Troesch problem for the state X=(y,y')
+func (system TroeschSystem) F(x float64, X []float64) ([]float64, error) {
+	return []float64{X[1], system.mu * math.Sinh(system.mu*X[0])}, nil
+}
+
+// BC implements the boundary conditions y(0)=0 and y(1)=1
+func (system TroeschSystem) BC(Xa, Xb []float64, R []float64) error {
+	R[0] = Xa[0]
+	R[1] = Xb[0] - 1
+	return nil
+}
+
+// DemoTroesch solves the Troesch problem (mu=5) with the collocation method,
+// where the single shooting fails from the same initial guess, and compares
+// the slope y'(0) to its reference value 0.0457504614.
+func DemoTroesch(postpro bool) error {
+	system := TroeschSystem{mu: 5}
+	problem := bvp.Problem{F: system.F, BC: system.BC, A: 0, B: 1}
+	guess := func(x float64) []float64 {
+		return []float64{x, 1}
+	}
+
+	shooting := bvp.NewShootingSolver(solver.NewRK4Solver(), 0.01)
+	err := shooting.Solve(problem, guess)
+	log.Printf("single shooting: %v\n", err)
+	if !errors.Is(err, solver.ErrNewtonDivergence) {
+		return fmt.Errorf("ERR: the single shooting was expected to fail with a Newton divergence")
+	}
+
+	algo := bvp.NewCollocationSolver()
+	err = algo.Solve(problem, guess, bvp.Tolerance(1e-8))
+	if err != nil {
+		return err
+	}
+	series := algo.Solution()
+	slope := series[0].GetState()[1]
+	log.Printf("collocation: y'(0)=%.10f (%s)\n", slope, algo.Stats())
+	if math.Abs(slope-0.0457504614) > 1e-6 {
+		return fmt.Errorf("ERR: the slope y'(0) differs from the reference value")
+	}
+	return series.ToCSVwithNames("out.troesch_data.csv", []string{"y", "dy"})
+}
+
+// MathieuSystem defines the eigenvalue problem of the Mathieu equation
+// y” + (lambda - 2*q*cos(2x))*y = 0 on [0,PI], with y'(0)=y'(PI)=0 and the
+// normalization y(0)=1. The eigenvalue lambda is an unknown parameter.
+type MathieuSystem struct {
+	q float64
+}
+
+// F implements the function f of the Mathieu equation for the state X=(y,y')
+// and the parameter P=(lambda)
+func (system MathieuSystem) F(x float64, X, P []float64) ([]float64, error) {
+	return []float64{X[1], -(P[0] - 2*system.q*math.Cos(2*x)) * X[0]}, nil
+}
+
+// BC implements the boundary conditions y'(0)=y'(PI)=0 and y(0)=1
+func (system MathieuSystem) BC(Xa, Xb, P []float64, R []float64) error {
+	R[0] = Xa[1]
+	R[1] = Xb[1]
+	R[2] = Xa[0] - 1
+	return nil
+}
+
+// DemoMathieu computes the fourth eigenvalue of the Mathieu equation (q=5)
+// with the collocation method, as an unknown parameter of the boundary
+// conditions, from the guess lambda=15 and y=cos(4x). The eigenvalue is
+// compared to its reference value 17.09658.
+func DemoMathieu(postpro bool) error {
+	system := MathieuSystem{q: 5}
+	problem := bvp.ParametricProblem{F: system.F, BC: system.BC, A: 0, B: math.Pi}
+	guess := func(x float64) []float64 {
+		return []float64{math.Cos(4 * x), -4 * math.Sin(4*x)}
+	}
+	algo := bvp.NewCollocationSolver()
+	err := algo.SolveParametric(problem, guess, []float64{15})
+	if err != nil {
+		return err
+	}
+	lambda := algo.Parameters()[0]
+	log.Printf("eigenvalue lambda=%.6f (%s)\n", lambda, algo.Stats())
+	if math.Abs(lambda-17.09658) > 1e-4 {
+		return fmt.Errorf("ERR: the eigenvalue differs from the reference value")
+	}
+
+	// The continuous solution is sampled on a regular grid
+	var series solver.TimeSeries
+	for i := 0; i <= 100; i++ {
+		x := math.Pi * float64(i) / 100
+		X := make([]float64, 2)
+		algo.Eval(x, X)
+		series.Append(solver.NewTimeData(x, X))
+	}
+	return series.ToCSVwithNames("out.mathieu_data.csv", []string{"y", "dy"})
+}
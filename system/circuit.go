@@ -0,0 +1,102 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The circuit system modelizes an electrical circuit where a voltage source
+V(t) feeds, through a resistor R1, a capacitor C in parallel with a resistor
+R2. The state of the circuit is defined by the voltage u of the capacitor and
+the current i through the resistor R1, governed by the Kirchhoff laws:
+
+	C*du/dt = i - u/R2   (current law at the node of the capacitor)
+	0 = V(t) - R1*i - u  (voltage law along the loop of the source)
+
+The second equation is algebraic: the system is a differential algebraic
+equation (DAE) of index 1, naturally written in the mass matrix form
+M.dX/dt = f(t,X) with X=(u,i) and M=diag(C,0).
+
+*/
+
+// CircuitSystem modelises an RC circuit driven by a sinusoidal voltage source
+// V(t) = V0*sin(w*t).
+type CircuitSystem struct {
+	r1, r2, c float64
+	v0, w     float64
+}
+
+// voltage returns the voltage of the source at time t
+func (system CircuitSystem) voltage(t float64) float64 {
+	return system.v0 * math.Sin(system.w*t)
+}
+
+// F implements the function f of the circuit system in the mass matrix form
+func (system CircuitSystem) F(t float64, X []float64, dXdt []float64) error {
+	u, i := X[0], X[1]
+	dXdt[0] = i - u/system.r2
+	dXdt[1] = system.voltage(t) - system.r1*i - u
+	return nil
+}
+
+// M implements the mass matrix of the circuit system
+func (system CircuitSystem) M(t float64, X []float64, M *linalg.Matrix) error {
+	M.Set(0, 0, system.c)
+	return nil
+}
+
+// Problem returns the MassMatrixProblem of the circuit system
+func (system CircuitSystem) Problem() solver.MassMatrixProblem {
+	return solver.MassMatrixProblem{F: system.F, M: system.M, ConstantMass: true}
+}
+
+// DemoCircuit solves the RC circuit in its mass matrix form (a DAE with a
+// singular mass matrix). The solution is compared to the solution of the
+// equivalent ODE obtained by eliminating the current i:
+//
+//	C*du/dt = (V(t) - u)/R1 - u/R2
+func DemoCircuit(postpro bool) error {
+	circuit := CircuitSystem{r1: 1e3, r2: 2e3, c: 1e-3, v0: 5, w: 2 * math.Pi}
+	t0 := 0.0
+	X0 := []float64{0, 0} // consistent initial state: V(0)-R1*i-u = 0
+	h := 1e-3
+	tmax := 3.0
+
+	algo := solver.NewSDIRK2Solver()
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.SolveMassMatrix(circuit.Problem(), t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	if err != nil {
+		return err
+	}
+
+	ode := func(t float64, X []float64) ([]float64, error) {
+		u := X[0]
+		dudt := ((circuit.voltage(t)-u)/circuit.r1 - u/circuit.r2) / circuit.c
+		return []float64{dudt}, nil
+	}
+	reference := solver.NewRK4Solver()
+	var rkrecorder solver.RecorderTimeSeries
+	_, err = reference.Solve(ode, t0, X0[:1], h, solver.StopAtTime(tmax), &rkrecorder)
+	if err != nil {
+		return err
+	}
+
+	maxError, maxResidual := 0.0, 0.0
+	for k, data := range recorder.Series {
+		t, X := data.GetTime(), data.GetState()
+		uref := rkrecorder.Series[k].GetState()[0]
+		maxError = math.Max(maxError, math.Abs(X[0]-uref))
+		maxResidual = math.Max(maxResidual, math.Abs(circuit.voltage(t)-circuit.r1*X[1]-X[0]))
+	}
+	log.Printf("Max difference with the ODE solution: %.3e, max residual of the voltage law: %.3e\n", maxError, maxResidual)
+	if maxError > 1e-4 || maxResidual > 1e-8 {
+		return fmt.Errorf("ERR: the DAE solution is not accurate (difference: %.3e, residual: %.3e)", maxError, maxResidual)
+	}
+	return recorder.Series.ToCSVwithNames("out.circuit_data.csv", []string{"u", "i"})
+}
@@ -0,0 +1,231 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The delayed decay system is the simplest delay differential equation (DDE):
+
+	dx/dt = -x(t-1)
+
+with the initial history x(t)=1 for t<=0. The analytical solution is obtained
+by the method of steps, i.e. by integrating the equation on the successive
+intervals [k,k+1] where x(t-1) is known:
+
+	x = 1 - t                                  for t in [0,1]
+	x = 1 - t + (t-1)^2/2                      for t in [1,2]
+	x = 1 - t + (t-1)^2/2 - (t-2)^3/6          for t in [2,3]
+
+The solution is a polynomial of a degree increased by one on each interval:
+its derivative of order k is discontinuous at t=k. These discontinuities
+break the accuracy of the RK methods, unless the solver lands exactly on them.
+
+*/
+
+// DelayedDecaySystem modelises the equation dx/dt = -x(t-tau)
+type DelayedDecaySystem struct {
+	tau float64
+}
+
+// F implements the function f of the delayed decay system
+func (system DelayedDecaySystem) F(t float64, X []float64, past *solver.History, dXdt []float64) error {
+	dXdt[0] = -past.Component(0, t-system.tau)
+	return nil
+}
+
+// History implements the initial history of the delayed decay system
+func (system DelayedDecaySystem) History(t float64, X []float64) {
+	X[0] = 1
+}
+
+// solution returns the analytical solution for tau=1 and t in [0,3] (the
+// solution is extrapolated after t=3)
+func (system DelayedDecaySystem) solution(t float64) float64 {
+	x := 1 - t
+	if t > 1 {
+		x += (t - 1) * (t - 1) / 2
+	}
+	if t > 2 {
+		x -= (t - 2) * (t - 2) * (t - 2) / 6
+	}
+	return x
+}
+
+// DemoDelayedDecay solves the delayed decay system with the RK4 method, with
+// and without the tracking of the discontinuities, and compares the results
+// to the analytical solution. The step size does not divide the delay, so
+// that the steps straddle the discontinuities if they are not tracked.
+func DemoDelayedDecay(postpro bool) error {
+	decay := DelayedDecaySystem{tau: 1}
+	t0, X0, h, tmax := 0.0, []float64{1.0}, 0.3, 3.0
+
+	maxErrors := make(map[bool]float64)
+	for _, tracking := range []bool{false, true} {
+		problem := solver.DelayProblem{F: decay.F, History: decay.History}
+		if tracking {
+			problem.Delays = []float64{decay.tau}
+		}
+		algo := solver.NewDelaySolver(solver.NewRK4Solver())
+		var recorder solver.RecorderTimeSeries
+		_, err := algo.SolveDelay(problem, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+		if err != nil {
+			return err
+		}
+		for _, data := range recorder.Series {
+			if data.GetTime() > tmax {
+				break // the last step (that stops the process) overshoots tmax
+			}
+			e := math.Abs(data.GetState()[0] - decay.solution(data.GetTime()))
+			maxErrors[tracking] = math.Max(maxErrors[tracking], e)
+		}
+		log.Printf("Discontinuities tracked: %v, max error: %.3e\n", algo.Discontinuities(), maxErrors[tracking])
+		if tracking {
+			err = recorder.Series.ToCSVwithNames("out.dde_decay_data.csv", []string{"x"})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// RK4 is exact for the polynomials of degree lower than 4, if the steps
+	// do not straddle the discontinuities.
+	if maxErrors[true] > 1e-12 || maxErrors[false] < 1e-6 {
+		return fmt.Errorf("ERR: the tracking of the discontinuities should restore the accuracy (%.3e vs %.3e)", maxErrors[true], maxErrors[false])
+	}
+	return nil
+}
+
+/*
+
+The state dependent decay system is a variant of the delayed decay system
+where the delay depends on the state:
+
+	dx/dt = -x(t - tau(x))  with tau(x) = 1 + x^2/2
+
+with the initial history x(t)=1 for t<=0. The discontinuity of the derivative
+at t=0 then propagates to the times t where t - tau(x(t)) crosses a previous
+discontinuity, that are not known in advance.
+
+*/
+
+// StateDelayedDecaySystem modelises the equation dx/dt = -x(t-tau(x))
+type StateDelayedDecaySystem struct{}
+
+// delay implements the state dependent delay of the system
+func (system StateDelayedDecaySystem) delay(t float64, X []float64) float64 {
+	return 1 + X[0]*X[0]/2
+}
+
+// F implements the function f of the state dependent decay system
+func (system StateDelayedDecaySystem) F(t float64, X []float64, past *solver.History, dXdt []float64) error {
+	dXdt[0] = -past.Component(0, t-system.delay(t, X))
+	return nil
+}
+
+// History implements the initial history of the state dependent decay system
+func (system StateDelayedDecaySystem) History(t float64, X []float64) {
+	X[0] = 1
+}
+
+// DemoStateDelayedDecay solves the state dependent decay system with the RK4
+// method, with and without the tracking of the discontinuities, and compares
+// the results to a reference solution computed with a very small step size.
+func DemoStateDelayedDecay(postpro bool) error {
+	decay := StateDelayedDecaySystem{}
+	t0, X0, tmax := 0.0, []float64{1.0}, 5.0
+	problem := solver.DelayProblem{F: decay.F, History: decay.History}
+	tracked := problem
+	tracked.StateDelays = []solver.DelayFunctionOf{decay.delay}
+
+	solve := func(p solver.DelayProblem, h float64) (float64, []float64, error) {
+		algo := solver.NewDelaySolver(solver.NewRK4Solver())
+		_, err := algo.SolveDelay(p, t0, X0, h, nil, nil, solver.OutputTimes(tmax))
+		_, X := algo.Result()
+		return X[0], algo.Discontinuities(), err
+	}
+	reference, _, err := solve(tracked, 1e-3)
+	if err != nil {
+		return err
+	}
+	x, _, err := solve(problem, 0.1)
+	if err != nil {
+		return err
+	}
+	xt, discontinuities, err := solve(tracked, 0.1)
+	if err != nil {
+		return err
+	}
+	log.Printf("Discontinuities tracked: %.6f\n", discontinuities)
+	log.Printf("Error at t=%g without tracking: %.3e, with tracking: %.3e\n", tmax, math.Abs(x-reference), math.Abs(xt-reference))
+	if math.Abs(xt-reference) > 1e-5 || math.Abs(xt-reference) > math.Abs(x-reference) {
+		return fmt.Errorf("ERR: the tracking of the discontinuities should improve the accuracy")
+	}
+	return nil
+}
+
+/*
+
+The Mackey-Glass equation is a model of the regulation of the production of
+the blood cells, whose solution can be chaotic:
+
+	dx/dt = beta*x(t-tau)/(1 + x(t-tau)^n) - gamma*x
+
+With beta=0.2, gamma=0.1, n=10 and tau=17, the solution is a chaotic
+attractor, that can be represented in the plane (x(t),x(t-tau)).
+
+*/
+
+// MackeyGlassSystem modelises the Mackey-Glass equation
+type MackeyGlassSystem struct {
+	beta, gamma, n, tau float64
+}
+
+// F implements the function f of the Mackey-Glass system
+func (system MackeyGlassSystem) F(t float64, X []float64, past *solver.History, dXdt []float64) error {
+	xd := past.Component(0, t-system.tau)
+	dXdt[0] = system.beta*xd/(1+math.Pow(xd, system.n)) - system.gamma*X[0]
+	return nil
+}
+
+// History implements the initial history of the Mackey-Glass system
+func (system MackeyGlassSystem) History(t float64, X []float64) {
+	X[0] = 0.5
+}
+
+// DemoMackeyGlass solves the Mackey-Glass equation in the chaotic regime, and
+// saves the trajectory in the plane (x(t),x(t-tau)).
+func DemoMackeyGlass(postpro bool) error {
+	mg := MackeyGlassSystem{beta: 0.2, gamma: 0.1, n: 10, tau: 17}
+	problem := solver.DelayProblem{F: mg.F, History: mg.History, Delays: []float64{mg.tau}}
+	t0, X0, h, tmax := 0.0, []float64{0.5}, 0.1, 1000.0
+
+	algo := solver.NewDelaySolver(solver.NewRK4Solver())
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.SolveDelay(problem, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	if err != nil {
+		return err
+	}
+
+	// The trajectory in the plane (x(t),x(t-tau)), after the transient regime
+	history := algo.History()
+	var series solver.TimeSeries
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	for _, data := range recorder.Series {
+		t, x := data.GetTime(), data.GetState()[0]
+		if t < 10*mg.tau {
+			continue
+		}
+		xmin, xmax = math.Min(xmin, x), math.Max(xmax, x)
+		series.Append(solver.NewTimeData(t, []float64{x, history.Component(0, t-mg.tau)}))
+	}
+	log.Printf("The solution oscillates in [%.4f,%.4f]\n", xmin, xmax)
+	if xmin < 0.2 || xmax > 1.5 || xmax-xmin < 0.5 {
+		return fmt.Errorf("ERR: the solution is not on the Mackey-Glass attractor")
+	}
+	return series.ToCSVwithNames("out.mackeyglass_data.csv", []string{"x", "xtau"})
+}
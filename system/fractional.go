@@ -0,0 +1,182 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/gboulant/dingo-ode/fde"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The fractional differential equations replace the derivatives by Caputo
+fractional derivatives D^alpha of orders alpha in (0,1], that model the
+systems with a long memory. The fractional relaxation equation:
+
+	D^alpha x = -lambda*x, x(0) = x0
+
+has the solution x(t) = x0*E_alpha(-lambda*t^alpha), where E_alpha is the
+Mittag-Leffler function:
+
+	E_alpha(z) = sum[k>=0] z^k/Gamma(alpha*k+1)
+
+that is the exponential for alpha=1, and decreases as t^-alpha for alpha < 1
+(instead of exponentially).
+
+The battery model is an equivalent circuit whose diffusion in the electrodes
+is modelled by a constant phase element (CPE) of capacity C and order alpha
+in parallel with a resistance R. For a discharge at a constant current I, the
+state of charge z and the voltage v of the CPE are defined by:
+
+	dz/dt       = -I/Q
+	D^alpha v   = I/C - v/(R*C)
+
+where Q is the capacity of the battery. The voltage v relaxes to R*I with the
+Mittag-Leffler function, i.e. much more slowly than the exponential of a
+capacitor (alpha=1).
+
+*/
+
+// FractionalRelaxationSystem defines the fractional relaxation equation
+type FractionalRelaxationSystem struct {
+	alpha  float64 // order of the derivative
+	lambda float64 // relaxation rate
+}
+
+// F implements the function f of the fractional relaxation equation
+func (system FractionalRelaxationSystem) F(t float64, X []float64, dXdt []float64) error {
+	dXdt[0] = -system.lambda * X[0]
+	return nil
+}
+
+// Problem returns the FDE of the fractional relaxation
+func (system FractionalRelaxationSystem) Problem() fde.Problem {
+	return fde.Problem{F: system.F, Alpha: []float64{system.alpha}}
+}
+
+// solution returns the exact solution x(t) for x(0) = x0, computed with the
+// series of the Mittag-Leffler function (accurate for the small values of
+// lambda*t^alpha).
+func (system FractionalRelaxationSystem) solution(x0, t float64) float64 {
+	z := -system.lambda * math.Pow(t, system.alpha)
+	s := 0.0
+	for k := 0; k < 100; k++ {
+		term := math.Pow(z, float64(k)) / math.Gamma(system.alpha*float64(k)+1)
+		s += term
+		if math.Abs(term) < 1e-16 {
+			break
+		}
+	}
+	return x0 * s
+}
+
+// DemoFractionalRelaxation checks the order of convergence (1+alpha) of the
+// fractional Adams-Bashforth-Moulton method on the fractional relaxation, and
+// compares the FFT and the direct summations of the history on a long run.
+func DemoFractionalRelaxation(postpro bool) error {
+	system := FractionalRelaxationSystem{alpha: 0.6, lambda: 1}
+	p := system.Problem()
+	algo := fde.NewABMSolver()
+
+	h1, h2 := 0.01, 0.0025
+	errors := make([]float64, 2)
+	for i, h := range []float64{h1, h2} {
+		_, err := algo.Solve(p, 0, []float64{1}, h, solver.StopAtTime(1), nil)
+		if err != nil {
+			return err
+		}
+		t, X := algo.Result()
+		errors[i] = math.Abs(X[0] - system.solution(1, t))
+	}
+	order := math.Log(errors[0]/errors[1]) / math.Log(h1/h2)
+	log.Printf("error %.3e (h=%g), %.3e (h=%g), order %.2f (expected %g)\n", errors[0], h1, errors[1], h2, order, 1+system.alpha)
+	if math.Abs(order-(1+system.alpha)) > 0.2 {
+		return fmt.Errorf("ERR: the order of the fractional ABM method should be %g", 1+system.alpha)
+	}
+
+	var recorder solver.RecorderTimeSeries
+	X := make([][]float64, 2)
+	for i, opts := range [][]fde.Option{nil, {fde.DirectSummation()}} {
+		start := time.Now()
+		var r solver.Recorder
+		if i == 0 {
+			r = &recorder
+		}
+		_, err := algo.Solve(p, 0, []float64{1}, 1e-3, solver.StopAtTime(20), r, opts...)
+		if err != nil {
+			return err
+		}
+		_, X[i] = algo.Result()
+		log.Printf("%d steps in %v (direct summation: %v)\n", algo.Stats().AcceptedSteps, time.Since(start), i == 1)
+	}
+	log.Printf("x(20) = %.12f (FFT), %.12f (direct)\n", X[0][0], X[1][0])
+	if math.Abs(X[0][0]-X[1][0]) > 1e-12 {
+		return fmt.Errorf("ERR: the FFT and the direct summations should give the same result")
+	}
+	return recorder.Series.ToCSVwithNames("out.fractional_data.csv", []string{"x"})
+}
+
+// BatterySystem modelises the discharge of a battery with a constant phase
+// element
+type BatterySystem struct {
+	Q     float64 // capacity of the battery (A.s)
+	R     float64 // resistance of the CPE (ohm)
+	C     float64 // capacity of the CPE (F.s^(alpha-1))
+	alpha float64 // order of the CPE
+	I     float64 // discharge current (A)
+}
+
+// F implements the function f of the battery for the state X=(z,v)
+func (system BatterySystem) F(t float64, X []float64, dXdt []float64) error {
+	dXdt[0] = -system.I / system.Q
+	dXdt[1] = system.I/system.C - X[1]/(system.R*system.C)
+	return nil
+}
+
+// Problem returns the FDE of the battery (mixed orders 1 and alpha)
+func (system BatterySystem) Problem() fde.Problem {
+	return fde.Problem{F: system.F, Alpha: []float64{1, system.alpha}}
+}
+
+// DemoBattery simulates a one hour discharge of a battery, checks the state
+// of charge and the relaxation of the CPE voltage to R*I, and compares the
+// full memory to short memories of increasing lengths.
+func DemoBattery(postpro bool) error {
+	system := BatterySystem{Q: 2 * 3600, R: 0.01, C: 1000, alpha: 0.8, I: 1}
+	t0, X0, h, tmax := 0.0, []float64{1, 0}, 0.5, 3600.0
+	algo := fde.NewABMSolver()
+
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.Solve(system.Problem(), t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	if err != nil {
+		return err
+	}
+	t, X := algo.Result()
+	log.Printf("full memory: z=%.6f, v=%.6f V at t=%g s (R*I=%g V)\n", X[0], X[1], t, system.R*system.I)
+	z := X0[0] - system.I*t/system.Q
+	if math.Abs(X[0]-z) > 1e-9 {
+		return fmt.Errorf("ERR: the state of charge should be %g", z)
+	}
+	if math.Abs(X[1]-system.R*system.I) > 0.01*system.R*system.I {
+		return fmt.Errorf("ERR: the voltage of the CPE should relax to R*I")
+	}
+
+	previous := math.Inf(1)
+	for _, memory := range []float64{300, 600, 1200} {
+		_, err = algo.Solve(system.Problem(), t0, X0, h, solver.StopAtTime(tmax), nil, fde.ShortMemory(memory))
+		if err != nil {
+			return err
+		}
+		_, Y := algo.Result()
+		e := math.Abs(Y[1]-X[1]) / X[1]
+		log.Printf("short memory of %4g s: v=%.6f V (relative error %.2e)\n", memory, Y[1], e)
+		if e > previous || Y[0] != X[0] {
+			return fmt.Errorf("ERR: the error of the short memory should decrease with its length")
+		}
+		previous = e
+	}
+	return recorder.Series.ToCSVwithNames("out.battery_data.csv", []string{"z", "v"})
+}
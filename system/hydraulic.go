@@ -0,0 +1,143 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The hydraulic network system modelizes n water tanks (of unit section) filled
+in with constant input flows d_i, and connected by pipes to a common node from
+which the water flows out through a hole. The flow in the pipe i is laminar,
+i.e. proportional to the difference between the height h_i of the tank i and
+the pressure p of the node (expressed as a water height): q_i = (h_i - p)/R_i.
+The out flow of the node follows the Torricelli law: q = c*sqrt(p). The node
+has no volume, then the sum of the flows at the node is zero at any time. The
+equations of the network are then:
+
+	dh_i/dt = d_i - (h_i - p)/R_i
+	      0 = sum_i((h_i - p)/R_i) - c*sqrt(p)
+
+i.e. a semi-explicit DAE of index 1 whose differential components are the
+heights h_i and whose algebraic component is the pressure p. At the
+equilibrium, the out flow equals the sum D of the input flows, then:
+
+	p = (D/c)^2
+	h_i = p + R_i*d_i
+
+*/
+
+// HydraulicNetworkSystem modelises a network of water tanks connected to a
+// common node.
+type HydraulicNetworkSystem struct {
+	d []float64 // input flows of the tanks
+	r []float64 // resistances of the pipes
+	c float64   // coefficient of the out flow of the node
+}
+
+// flow returns the flow in the pipe i for the height h and the pressure p
+func (system HydraulicNetworkSystem) flow(i int, h, p float64) float64 {
+	return (h - p) / system.r[i]
+}
+
+// F implements the function f of the differential equations of the network
+func (system HydraulicNetworkSystem) F(t float64, X, Z []float64, dXdt []float64) error {
+	for i, h := range X {
+		dXdt[i] = system.d[i] - system.flow(i, h, Z[0])
+	}
+	return nil
+}
+
+// G implements the function g of the algebraic equation of the network (the
+// balance of the flows at the node)
+func (system HydraulicNetworkSystem) G(t float64, X, Z []float64, R []float64) error {
+	p := Z[0]
+	R[0] = -system.c * math.Sqrt(math.Max(p, 0))
+	for i, h := range X {
+		R[0] += system.flow(i, h, p)
+	}
+	return nil
+}
+
+// Problem returns the DAEProblem of the hydraulic network
+func (system HydraulicNetworkSystem) Problem() solver.DAEProblem {
+	return solver.DAEProblem{F: system.F, G: system.G}
+}
+
+// equilibrium returns the heights and the pressure at the equilibrium
+func (system HydraulicNetworkSystem) equilibrium() ([]float64, float64) {
+	D := 0.0
+	for _, d := range system.d {
+		D += d
+	}
+	p := (D / system.c) * (D / system.c)
+	heights := make([]float64, len(system.d))
+	for i, d := range system.d {
+		heights[i] = p + system.r[i]*d
+	}
+	return heights, p
+}
+
+// DemoHydraulicNetwork solves the hydraulic network as a DAE with the Radau5
+// solver, starting from an inconsistent guess of the pressure of the node, up
+// to the steady state that is compared to the analytical equilibrium.
+func DemoHydraulicNetwork(postpro bool) error {
+	network := HydraulicNetworkSystem{
+		d: []float64{0.2, 0.5, 0.3},
+		r: []float64{2.0, 1.0, 4.0},
+		c: 0.8,
+	}
+	t0 := 0.0
+	X0 := []float64{0.5, 0.5, 0.5}
+	Z0 := []float64{0.0} // guess of the pressure, corrected by the solver
+	h := 0.05
+	tmax := 200.0
+
+	algo := solver.NewRadau5Solver()
+	detector := solver.NewSteadyStateDetector(1e-7, 1e-6, 1.0)
+	controller := solver.Or(solver.StopAtTime(tmax), detector)
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.SolveDAE(network.Problem(), t0, X0, Z0, h, controller, &recorder)
+	if err != nil {
+		return err
+	}
+	log.Printf("Radau5 statistics:\n%s", algo.Stats())
+	log.Printf("Stop reason: %s\n", algo.StopReason())
+
+	// The recorded states (h_i,p) should satisfy the algebraic equation
+	heights := recorder.Series.Components(0, len(X0))
+	pressure := recorder.Series.Components(len(X0), len(X0)+1)
+	maxResidual := 0.0
+	R := make([]float64, 1)
+	for k := range recorder.Series {
+		network.G(0, heights[k].GetState(), pressure[k].GetState(), R)
+		maxResidual = math.Max(maxResidual, math.Abs(R[0]))
+	}
+	log.Printf("Initial pressure: %.6f, max residual of the flow balance: %.3e\n", pressure[0].GetState()[0], maxResidual)
+	if maxResidual > 1e-8 {
+		return fmt.Errorf("ERR: the flow balance is not satisfied (residual: %.3e)", maxResidual)
+	}
+
+	if !detector.Detected {
+		return fmt.Errorf("ERR: the steady state is not reached before t=%g", tmax)
+	}
+	he, pe := network.equilibrium()
+	maxError := math.Abs(detector.X[len(X0)] - pe)
+	for i := range he {
+		maxError = math.Max(maxError, math.Abs(detector.X[i]-he[i]))
+	}
+	log.Printf("Steady state at t=%.2f: %.6f (equilibrium: h=%.6f, p=%.6f)\n", detector.T, detector.X, he, pe)
+	if maxError > 1e-4 {
+		return fmt.Errorf("ERR: the steady state differs from the equilibrium (%.3e)", maxError)
+	}
+
+	err = heights.ToCSVwithNames("out.hydraulic_heights_data.csv", []string{"h0", "h1", "h2"})
+	if err != nil {
+		return err
+	}
+	return pressure.ToCSVwithNames("out.hydraulic_pressure_data.csv", []string{"p"})
+}
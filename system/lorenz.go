@@ -82,3 +82,65 @@ func DemoLorenz(postpro bool) error {
 
 	return err
 }
+
+// DemoLorenzCheckpoint illustrates the checkpoint and restart of a solving
+// process. The Lorenz system is solved in one run, and then in two runs: a
+// first run stopped at mid time whose final state is saved in a checkpoint
+// file, and a second run resumed from this checkpoint. Thanks to the chaotic
+// behavior of the system, any difference between the two solutions would be
+// amplified: the demo checks that the final states are exactly the same.
+func DemoLorenzCheckpoint(postpro bool) error {
+	dynsys := LorenzSystem{
+		rho:   28.0,
+		sigma: 10.0,
+		beta:  8.0 / 3.0,
+	}
+
+	X0 := []float64{1.0, 1.0, 1.0}
+	t0 := 0.0
+	h := 0.01
+	tmax := 100.0
+
+	algo := solver.NewRK4Solver()
+	var reference solver.RecorderTimeSeries
+	_, err := algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), &reference)
+	if err != nil {
+		return err
+	}
+	tref, Xref := algo.Result()
+
+	for _, cppath := range []string{"out.lorenz_checkpoint.json", "out.lorenz_checkpoint.bin"} {
+		var recorder solver.RecorderTimeSeries
+		_, err = algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax/2), &recorder)
+		if err != nil {
+			return err
+		}
+		err = algo.Checkpoint().Save(cppath)
+		if err != nil {
+			return err
+		}
+
+		cp, err := solver.LoadCheckpoint(cppath)
+		if err != nil {
+			return err
+		}
+		log.Printf("Resuming the solving process from t=%.2f (checkpoint %s)\n", cp.T, cppath)
+		_, err = algo.Solve(dynsys.f, 0, nil, 0, solver.StopAtTime(tmax), &recorder, solver.ResumeFrom(cp))
+		if err != nil {
+			return err
+		}
+
+		t, X := algo.Result()
+		if t != tref || len(recorder.Series) != len(reference.Series) {
+			return fmt.Errorf("ERR: the resumed process ends at t=%v with %d records instead of t=%v with %d records",
+				t, len(recorder.Series), tref, len(reference.Series))
+		}
+		for i := range X {
+			if X[i] != Xref[i] {
+				return fmt.Errorf("ERR: the resumed state %v differs from the reference state %v", X, Xref)
+			}
+		}
+	}
+	log.Printf("t: %.2f, x: %.4f, y: %.4f, z: %.4f\n", tref, Xref[0], Xref[1], Xref[2])
+	return nil
+}
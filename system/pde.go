@@ -0,0 +1,349 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/jacobian"
+	"github.com/gboulant/dingo-ode/linalg"
+	"github.com/gboulant/dingo-ode/mol"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The partial differential equations (PDE) of evolution are solved by the method
+of lines (package mol): the fields are discretized on a grid, and the PDE
+becomes an ODE system on the values of the fields at the points of the grid.
+
+The heat equation ∂u/∂t = D.Δu on [0,1] with the Dirichlet conditions u=0 has
+the solution u = sin(PI*x)*exp(-D*PI^2*t) for the initial profile sin(PI*x).
+With the Neumann conditions ∂u/∂n=0 (insulated walls), the heat is conserved,
+as the quantity of a field transported by a velocity on a periodic domain
+(∂u/∂t = -v.∂u/∂x).
+
+The Brusselator reaction-diffusion model defines the concentrations (u,v) of
+two chemical species on the square [0,1]x[0,1] with insulated walls:
+
+	∂u/∂t = A + u^2*v - (B+1)*u + alpha*Δu
+	∂v/∂t = B*u - u^2*v + alpha*Δv
+
+whose diffusion terms make the ODE system stiff for the fine grids.
+
+*/
+
+// HeatSystem returns the system of the heat equation ∂u/∂t = D.Δu on [0,1]
+// discretized with n points, for the initial profile sin(PI*x), and the
+// boundary conditions bc.
+func HeatSystem(D float64, n int, bc mol.Boundaries) (mol.System, error) {
+	layout, err := mol.NewLayout(mol.NewGrid1D(0, 1, n), mol.Field{Name: "u", BC: bc})
+	if err != nil {
+		return mol.System{}, err
+	}
+	X0, err := layout.Initial(func(x, y float64) float64 { return math.Sin(math.Pi * x) })
+	if err != nil {
+		return mol.System{}, err
+	}
+	rate := func(t float64, X []float64, dXdt []float64) error {
+		layout.Laplacian(t, X, 0, D, dXdt)
+		return nil
+	}
+	return mol.System{Layout: layout, Rate: rate, X0: X0, Step: 1e-3, Tmax: 0.1}, nil
+}
+
+// DemoHeat checks the order of convergence of the Laplacian operator (2) on
+// the heat equation with Dirichlet conditions, the conservation of the heat
+// with Neumann conditions, and the conservation of a field transported on a
+// periodic domain.
+func DemoHeat(postpro bool) error {
+	const D = 1.0
+	maxErrors := make([]float64, 2)
+	for k, n := range []int{20, 40} {
+		system, err := HeatSystem(D, n, mol.AllSides(mol.Dirichlet(0)))
+		if err != nil {
+			return err
+		}
+		t0, X0, step, tmax := system.GetDefaultInput()
+		algo := solver.NewRadau5Solver()
+		if _, err := algo.SolveInPlace(system.FInPlace, t0, X0, step*10, solver.StopAtTime(tmax), nil); err != nil {
+			return err
+		}
+		t, X := algo.Result()
+		grid := system.Layout.Grid
+		for i, u := range X {
+			exact := math.Sin(math.Pi*grid.X(i)) * math.Exp(-D*math.Pi*math.Pi*t)
+			maxErrors[k] = math.Max(maxErrors[k], math.Abs(u-exact))
+		}
+		log.Printf("dirichlet: error %.3e with %d points\n", maxErrors[k], n)
+	}
+	order := math.Log2(maxErrors[0] / maxErrors[1])
+	log.Printf("dirichlet: order %.2f (expected 2)\n", order)
+	if math.Abs(order-2) > 0.2 {
+		return fmt.Errorf("ERR: the order of the Laplacian operator should be 2")
+	}
+
+	// The heat is conserved with the insulated walls
+	system, err := HeatSystem(D, 40, mol.AllSides(mol.Neumann(0)))
+	if err != nil {
+		return err
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	syssolver := NewSystemSolver(system)
+	if err := syssolver.Solve(t0, X0, step/10, tmax); err != nil {
+		return err
+	}
+	series := *syssolver.Series()
+	sum := func(X []float64) float64 {
+		s := 0.0
+		for _, u := range X {
+			s += u * system.Layout.Grid.Dx()
+		}
+		return s
+	}
+	heat0, heat := sum(X0), sum(series[len(series)-1].GetState())
+	log.Printf("neumann: heat %.12f at t=%g, %.12f at t=%g\n", heat0, t0, heat, tmax)
+	if math.Abs(heat-heat0) > 1e-12 {
+		return fmt.Errorf("ERR: the heat should be conserved with the Neumann conditions")
+	}
+	if postpro {
+		if err := syssolver.PlotTimeSeries(system.Layout.Names(), false); err != nil {
+			return err
+		}
+	} else if err := syssolver.SaveTimeseries("out.heat_data.csv", system.Layout.Names()); err != nil {
+		return err
+	}
+
+	// A gaussian profile transported by a constant velocity on a periodic
+	// domain comes back after one period, diffused by the upwind scheme
+	layout, err := mol.NewLayout(mol.NewGrid1D(0, 1, 200), mol.Field{Name: "u", BC: mol.AllSides(mol.Periodic())})
+	if err != nil {
+		return err
+	}
+	X0, err = layout.Initial(func(x, y float64) float64 { return math.Exp(-100 * (x - 0.5) * (x - 0.5)) })
+	if err != nil {
+		return err
+	}
+	velocity := NewConstantX(layout.Grid.Points(), 1)
+	advection := mol.System{
+		Layout: layout,
+		Rate: func(t float64, X []float64, dXdt []float64) error {
+			layout.Advection(t, X, 0, velocity, nil, dXdt)
+			return nil
+		},
+	}
+	algo := solver.NewRK4Solver()
+	if _, err := algo.SolveInPlace(advection.FInPlace, 0, X0, 0.001, solver.StopAtTime(1), nil); err != nil {
+		return err
+	}
+	_, X := algo.Result()
+	peak := 0
+	for i := range X {
+		if X[i] > X[peak] {
+			peak = i
+		}
+	}
+	log.Printf("advection: quantity %.12f (initial %.12f), peak %.3f at x=%.3f\n", sum(X), sum(X0), X[peak], layout.Grid.X(peak))
+	if math.Abs(sum(X)-sum(X0)) > 1e-12 || math.Abs(layout.Grid.X(peak)-0.5) > layout.Grid.Dx() {
+		return fmt.Errorf("ERR: the transported profile should come back after one period")
+	}
+	return nil
+}
+
+// BrusselatorSystem defines the 2D Brusselator reaction-diffusion model
+type BrusselatorSystem struct {
+	A, B  float64
+	alpha float64 // diffusion coefficient
+}
+
+// System returns the system of the Brusselator discretized on a grid of n x n
+// points
+func (b BrusselatorSystem) System(n int) (mol.System, error) {
+	insulated := mol.AllSides(mol.Neumann(0))
+	layout, err := mol.NewLayout(mol.NewGrid2D(0, 1, n, 0, 1, n),
+		mol.Field{Name: "u", BC: insulated},
+		mol.Field{Name: "v", BC: insulated},
+	)
+	if err != nil {
+		return mol.System{}, err
+	}
+	X0, err := layout.Initial(
+		func(x, y float64) float64 { return 0.5 + y },
+		func(x, y float64) float64 { return 1 + 5*x },
+	)
+	if err != nil {
+		return mol.System{}, err
+	}
+	rate := func(t float64, X []float64, dXdt []float64) error {
+		u, v := layout.Field(X, 0), layout.Field(X, 1)
+		du, dv := layout.Field(dXdt, 0), layout.Field(dXdt, 1)
+		for p := range u {
+			r := u[p] * u[p] * v[p]
+			du[p] = b.A + r - (b.B+1)*u[p]
+			dv[p] = b.B*u[p] - r
+		}
+		layout.Laplacian(t, X, 0, b.alpha, dXdt)
+		layout.Laplacian(t, X, 1, b.alpha, dXdt)
+		return nil
+	}
+	return mol.System{Layout: layout, Rate: rate, X0: X0, Step: 0.1, Tmax: 10}, nil
+}
+
+// DemoBrusselator solves the 2D Brusselator with an implicit solver, whose
+// step size is beyond the stability limit of the explicit RK4 method, and
+// checks that the Jacobian matrix of the system fits in its sparsity pattern.
+func DemoBrusselator(postpro bool) error {
+	system, err := BrusselatorSystem{A: 1, B: 3.4, alpha: 0.04}.System(12)
+	if err != nil {
+		return err
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+
+	// The Jacobian matrix detected by probing the system should be zero
+	// outside of the pattern
+	pattern := system.Pattern()
+	n := len(X0)
+	if err := jacobian.Check(system.FInPlace, pattern, t0, X0); err != nil {
+		return err
+	}
+	log.Printf("jacobian pattern: %d nonzeros for a %dx%d matrix\n", pattern.Nonzeros(), n, n)
+
+	algo := solver.NewSDIRK2Solver()
+	var recorder solver.RecorderTimeSeries
+	if _, err := algo.SolveInPlace(system.FInPlace, t0, X0, step, solver.StopAtTime(tmax), &recorder); err != nil {
+		return err
+	}
+	_, X := algo.Result()
+	log.Printf("SDIRK2 statistics (h=%g):\n%s", step, algo.Stats())
+
+	explicit := solver.NewRK4Solver()
+	controller := solver.Or(solver.StopAtTime(tmax), solver.StopOnNonFinite())
+	_, err = explicit.SolveInPlace(system.FInPlace, t0, X0, step, controller, nil)
+	if !errors.Is(err, solver.ErrNonFinite) {
+		return fmt.Errorf("ERR: the RK4 method should be unstable with the step size %g", step)
+	}
+	log.Printf("RK4 (h=%g): %v\n", step, err)
+	if _, err := explicit.SolveInPlace(system.FInPlace, t0, X0, step/10, solver.StopAtTime(tmax), nil); err != nil {
+		return err
+	}
+	_, Xref := explicit.Result()
+	e := 0.0
+	for i := range X {
+		e = math.Max(e, math.Abs(X[i]-Xref[i]))
+	}
+	log.Printf("SDIRK2 (h=%g) vs RK4 (h=%g): max difference %.3e\n", step, step/10, e)
+	if e > 0.05 {
+		return fmt.Errorf("ERR: the SDIRK2 and RK4 solutions should be close")
+	}
+	return recorder.Series.ToCSVwithNames("out.brusselator_data.csv", system.Layout.Names())
+}
+
+// DemoJacobian compares the dense and the sparse Jacobian matrices of the 2D
+// Brusselator computed by finite differences, and their cost for an implicit
+// solver. The sparse Jacobian matrix uses the pattern of the layout, and it is
+// compared to the pattern detected by probing the rate function.
+func DemoJacobian(postpro bool) error {
+	system, err := BrusselatorSystem{A: 1, B: 3.4, alpha: 0.04}.System(12)
+	if err != nil {
+		return err
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	n := len(X0)
+
+	pattern := system.Pattern()
+	detected, err := jacobian.Detect(system.FInPlace, t0, X0)
+	if err != nil {
+		return err
+	}
+	log.Printf("pattern: %d nonzeros (layout), %d nonzeros (detected)\n", pattern.Nonzeros(), detected.Nonzeros())
+	if err := jacobian.Check(system.FInPlace, pattern, t0, X0); err != nil {
+		return err
+	}
+
+	dense, err := jacobian.NewDense(system.FInPlace, n)
+	if err != nil {
+		return err
+	}
+	sparse, err := jacobian.NewSparse(system.FInPlace, pattern)
+	if err != nil {
+		return err
+	}
+	log.Printf("evaluations for a jacobian matrix: %d (dense), %d (sparse)\n", dense.Groups()+1, sparse.Groups()+1)
+	Jd, Js := linalg.NewMatrix(n, n), linalg.NewMatrix(n, n)
+	if err := dense.Jacobian(t0, X0, Jd); err != nil {
+		return err
+	}
+	if err := sparse.Jacobian(t0, X0, Js); err != nil {
+		return err
+	}
+	for i := range Jd.Data {
+		if Jd.Data[i] != Js.Data[i] {
+			return fmt.Errorf("ERR: the dense and the sparse Jacobian matrices should be the same")
+		}
+	}
+
+	results := make([][]float64, 2)
+	for k, estimator := range []*jacobian.Estimator{dense, sparse} {
+		algo := solver.NewSDIRK2Solver()
+		p := solver.MassMatrixProblem{F: system.FInPlace, Jacobian: estimator.Jacobian}
+		before := estimator.Evaluations()
+		if _, err := algo.SolveMassMatrix(p, t0, X0, step, solver.StopAtTime(tmax), nil); err != nil {
+			return err
+		}
+		_, results[k] = algo.Result()
+		stats := algo.Stats()
+		log.Printf("%-6s: %d jacobian matrices with %d evaluations in %v\n", []string{"dense", "sparse"}[k], stats.JacobianEvaluations, estimator.Evaluations()-before, stats.WallTime)
+	}
+	for i := range results[0] {
+		if math.Abs(results[0][i]-results[1][i]) > 1e-12 {
+			return fmt.Errorf("ERR: the solutions with the dense and the sparse Jacobian matrices should be the same")
+		}
+	}
+	return nil
+}
+
+// DemoBandedJacobian solves the heat equation discretized with many points
+// with the implicit solvers, using the dense iteration matrix and the band
+// iteration matrix given by the pattern of the layout (tridiagonal), and
+// compares the solutions and the costs.
+func DemoBandedJacobian(postpro bool) error {
+	system, err := HeatSystem(1, 200, mol.AllSides(mol.Dirichlet(0)))
+	if err != nil {
+		return err
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	pattern := system.Pattern()
+	kl, ku := pattern.Bandwidths()
+	log.Printf("pattern: %d nonzeros, bandwidths (%d,%d)\n", pattern.Nonzeros(), kl, ku)
+
+	methods := []struct {
+		name string
+		new  func() solver.ImplicitSolver
+	}{
+		{"sdirk2", solver.NewSDIRK2Solver},
+		{"radau5", solver.NewRadau5Solver},
+	}
+	for _, method := range methods {
+		results := make([][]float64, 2)
+		for k, pattern := range []*linalg.Pattern{nil, pattern} {
+			algo := method.new()
+			p := solver.MassMatrixProblem{F: system.FInPlace, Pattern: pattern}
+			if _, err := algo.SolveMassMatrix(p, t0, X0, step*10, solver.StopAtTime(tmax), nil); err != nil {
+				return err
+			}
+			_, results[k] = algo.Result()
+			stats := algo.Stats()
+			log.Printf("%s %-5s: %d evaluations, %d factorizations in %v\n", method.name, []string{"dense", "band"}[k], stats.FunctionEvaluations, stats.LUFactorizations, stats.WallTime)
+		}
+		e := 0.0
+		for i := range results[0] {
+			e = math.Max(e, math.Abs(results[0][i]-results[1][i]))
+		}
+		log.Printf("%s: max difference %.3e\n", method.name, e)
+		if e > 1e-8 {
+			return fmt.Errorf("ERR: the solutions with the dense and the band iteration matrices should be the same")
+		}
+	}
+	return nil
+}
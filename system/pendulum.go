@@ -0,0 +1,107 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The simple pendulum of length l in the gravity field is defined by the angle
+theta from the vertical:
+
+ theta'' = -(g/l)*sin(theta)
+
+It is a special second order system (the acceleration does not depend on the
+velocity), that can be solved with the Runge-Kutta-Nyström methods. The
+energy of the pendulum
+
+ E = l*theta'^2/2 - g*cos(theta)
+
+(per unit of mass and length) is conserved.
+
+*/
+
+// PendulumSystem defines the dynamical system of the simple pendulum
+type PendulumSystem struct {
+	l       float64 // length of the pendulum
+	gravity float64 // acceleration of the gravity
+}
+
+// G implements the acceleration of the pendulum (in theta” = G(t,theta))
+func (dynsys PendulumSystem) G(t float64, X, V, A []float64) error {
+	A[0] = -dynsys.gravity / dynsys.l * math.Sin(X[0])
+	return nil
+}
+
+// energy returns the energy of the pendulum in the state Y=(theta,theta')
+func (dynsys PendulumSystem) energy(Y []float64) float64 {
+	return dynsys.l*Y[1]*Y[1]/2 - dynsys.gravity*math.Cos(Y[0])
+}
+
+// DemoPendulum compares the RK4, RKN4 and DPRKN6 methods on the pendulum with a
+// large amplitude, at about the same number of evaluations of the acceleration,
+// and checks that the order of the DPRKN6 method is at least 6.
+func DemoPendulum(postpro bool) error {
+	dynsys := PendulumSystem{l: 1.0, gravity: 9.81}
+	g := solver.SecondOrderFunction(dynsys.G).FirstOrder()
+	Y0 := solver.SecondOrderState([]float64{2.5}, []float64{0.0})
+	t0 := 0.0
+	tmax := 20.0
+
+	solve := func(algo solver.Solver, h float64, r solver.Recorder) ([]float64, uint64, error) {
+		_, err := algo.SolveInPlace(g, t0, Y0, h, solver.StopAtTime(tmax), r)
+		_, Y := algo.Result()
+		return Y, algo.Stats().FunctionEvaluations, err
+	}
+	Yref, _, err := solve(solver.NewDPRKN6Solver(), 1e-3, nil)
+	if err != nil {
+		return err
+	}
+
+	// The step sizes are chosen for about the same number of evaluations (the
+	// step sizes divide tmax, so that the last step ends exactly at tmax)
+	methods := []struct {
+		name string
+		algo solver.Solver
+		h    float64
+	}{
+		{"rk4", solver.NewRK4Solver(), 0.02},
+		{"rkn4", solver.NewRKN4Solver(), 0.02},
+		{"dprkn6", solver.NewDPRKN6Solver(), 0.025},
+	}
+	for _, method := range methods {
+		var recorder solver.RecorderTimeSeries
+		Y, evals, err := solve(method.algo, method.h, &recorder)
+		if err != nil {
+			return err
+		}
+		drift := 0.0
+		for _, data := range recorder.Series {
+			drift = math.Max(drift, math.Abs(dynsys.energy(data.GetState())-dynsys.energy(Y0)))
+		}
+		log.Printf("%-6s: error %.3e, energy drift %.3e with %d evaluations\n", method.name, math.Abs(Y[0]-Yref[0]), drift, evals)
+		recorder.Series.ToCSVwithNames(fmt.Sprintf("out.pendulum_%s_data.csv", method.name), []string{"theta", "omega"})
+	}
+
+	algo := solver.NewDPRKN6Solver()
+	h := 0.05
+	Y1, _, err := solve(algo, h, nil)
+	if err != nil {
+		return err
+	}
+	Y2, _, err := solve(algo, h/2, nil)
+	if err != nil {
+		return err
+	}
+	e1, e2 := math.Abs(Y1[0]-Yref[0]), math.Abs(Y2[0]-Yref[0])
+	order := math.Log2(e1 / e2)
+	log.Printf("DPRKN6: error %.3e (h=%g), %.3e (h=%g), order %.2f\n", e1, h, e2, h/2, order)
+	if order < 5.7 {
+		return fmt.Errorf("ERR: the order of the DPRKN6 method should be 6")
+	}
+	return nil
+}
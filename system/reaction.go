@@ -0,0 +1,228 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+	"github.com/gboulant/dingo-ode/ssa"
+)
+
+/*
+
+For the small populations, the continuous models are no longer valid: the
+populations are integer numbers that change by the random occurrences of
+reactions (a birth, a death, a collision of two molecules). The evolution is
+then simulated by the Gillespie algorithms (package ssa), from the
+stoichiometry and the propensity of each reaction.
+
+The discrete version of the Volterra system at the scale s (number of
+individuals for a unit of the continuous populations) is defined by the
+reactions:
+
+	X     -> 2X     (rate a)    birth of a prey
+	X + Y -> Y      (rate b/s)  prey eaten by a predator
+	X + Y -> X + 2Y (rate d/s)  birth of a predator
+	Y     -> 0      (rate g)    death of a predator
+
+whose mean behaviour follows the Volterra equations for the large scales.
+Contrary to the continuous model, the discrete populations eventually
+collapse: the predators die out when a fluctuation drives them to zero.
+
+The birth-death process (0 -> A at the rate k, A -> 0 at the rate r*A) has
+a Poisson stationary distribution, of mean and variance k/r. The dimerization
+model of Gillespie (2001) combines reactions of order 1 and 2:
+
+	S1      -> 0       (rate c1)
+	S1 + S1 -> S2      (rate c2)
+	S2      -> S1 + S1 (rate c3)
+	S2      -> S3      (rate c4)
+
+*/
+
+// DiscreteVolterraSystem modelises a prey/predator system with discrete
+// populations.
+type DiscreteVolterraSystem struct {
+	VolterraSystem
+	s float64 // scale of the populations (number of individuals for a unit)
+}
+
+// Network returns the reaction network of the discrete Volterra system
+func (system DiscreteVolterraSystem) Network() ssa.Network {
+	return ssa.Network{
+		Species: 2,
+		Reactions: []ssa.Reaction{
+			{Name: "prey birth", Reactants: []int{0}, Products: []int{0, 0}, Rate: system.a},
+			{Name: "predation", Reactants: []int{0, 1}, Products: []int{1}, Rate: system.b / system.s},
+			{Name: "predator birth", Reactants: []int{0, 1}, Products: []int{0, 1, 1}, Rate: system.d / system.s},
+			{Name: "predator death", Reactants: []int{1}, Rate: system.g},
+		},
+	}
+}
+
+// DemoDiscreteVolterra simulates the discrete Volterra system with the next
+// reaction method until the extinction of a species, and records the
+// populations at regular times.
+func DemoDiscreteVolterra(postpro bool) error {
+	system := DiscreteVolterraSystem{
+		VolterraSystem: VolterraSystem{a: 2. / 3., b: 4. / 3., d: 1., g: 1.},
+		s:              20,
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	X0 = []float64{math.Round(system.s * X0[0]), math.Round(system.s * X0[1])}
+
+	algo := ssa.NewNextReactionSimulator()
+	var recorder solver.RecorderTimeSeries
+	extinction := solver.Controller(func(t float64, X []float64) (bool, error) {
+		return X[0] == 0 || X[1] == 0, nil
+	})
+	controller := solver.Or(solver.StopAtTime(100*tmax), extinction)
+	_, err := algo.Simulate(system.Network(), t0, X0, controller, &recorder, ssa.SampleEvery(step), ssa.Seed(7))
+	if err != nil {
+		return err
+	}
+	t, X := algo.Result()
+	stats := algo.Stats()
+	log.Printf("populations at t=%.2f: %v after %d reactions\n", t, X, stats.AcceptedSteps)
+	if X[0] < 0 || X[1] < 0 || X[0] != math.Trunc(X[0]) || X[1] != math.Trunc(X[1]) {
+		return fmt.Errorf("ERR: the populations should be non negative integers")
+	}
+	return recorder.Series.ToCSVwithNames("out.volterra_ssa_data.csv", []string{"x", "y"})
+}
+
+// BirthDeathSystem modelises the birth-death process of a species A
+type BirthDeathSystem struct {
+	k float64 // birth rate
+	r float64 // death rate of each individual
+}
+
+// Network returns the reaction network of the birth-death process
+func (system BirthDeathSystem) Network() ssa.Network {
+	return ssa.Network{
+		Species: 1,
+		Reactions: []ssa.Reaction{
+			{Name: "birth", Products: []int{0}, Rate: system.k},
+			{Name: "death", Reactants: []int{0}, Rate: system.r},
+		},
+	}
+}
+
+// DemoBirthDeath checks the mean and the variance of the stationary
+// distribution of the birth-death process simulated with the three methods.
+func DemoBirthDeath(postpro bool) error {
+	system := BirthDeathSystem{k: 10, r: 0.1}
+	lambda := system.k / system.r
+	methods := []struct {
+		name string
+		algo ssa.Simulator
+	}{
+		{"direct", ssa.NewDirectSimulator()},
+		{"next reaction", ssa.NewNextReactionSimulator()},
+		{"tau-leaping", ssa.NewTauLeapingSimulator()},
+	}
+	for _, method := range methods {
+		var recorder solver.RecorderTimeSeries
+		_, err := method.algo.Simulate(system.Network(), 0, []float64{lambda}, solver.StopAtTime(20000), &recorder, ssa.SampleEvery(1))
+		if err != nil {
+			return err
+		}
+		series := recorder.Series
+		mean, variance := 0.0, 0.0
+		for _, data := range series {
+			mean += data.GetState()[0]
+		}
+		mean /= float64(len(series))
+		for _, data := range series {
+			x := data.GetState()[0]
+			variance += (x - mean) * (x - mean)
+		}
+		variance /= float64(len(series) - 1)
+		stats := method.algo.Stats()
+		log.Printf("%-13s: mean %.2f, variance %.2f (expected %g) in %d steps\n", method.name, mean, variance, lambda, stats.AcceptedSteps)
+		if math.Abs(mean-lambda) > 0.05*lambda || math.Abs(variance-lambda) > 0.15*lambda {
+			return fmt.Errorf("ERR: the stationary distribution of the %s method should be a Poisson distribution of mean %g", method.name, lambda)
+		}
+	}
+	return nil
+}
+
+// DimerizationSystem modelises the dimerization model of Gillespie
+type DimerizationSystem struct {
+	c1, c2, c3, c4 float64
+}
+
+// Network returns the reaction network of the dimerization model
+func (system DimerizationSystem) Network() ssa.Network {
+	return ssa.Network{
+		Species: 3,
+		Reactions: []ssa.Reaction{
+			{Name: "decay", Reactants: []int{0}, Rate: system.c1},
+			{Name: "dimerization", Reactants: []int{0, 0}, Products: []int{1}, Rate: system.c2},
+			{Name: "dissociation", Reactants: []int{1}, Products: []int{0, 0}, Rate: system.c3},
+			{Name: "conversion", Reactants: []int{1}, Products: []int{2}, Rate: system.c4},
+		},
+	}
+}
+
+// F implements the reaction rate equations of the dimerization model, i.e.
+// the continuous model of the large populations
+func (system DimerizationSystem) F(t float64, X []float64) ([]float64, error) {
+	dimerization := system.c2 * X[0] * X[0] / 2
+	dissociation := system.c3 * X[1]
+	conversion := system.c4 * X[1]
+	return []float64{
+		-system.c1*X[0] - 2*dimerization + 2*dissociation,
+		dimerization - dissociation - conversion,
+		conversion,
+	}, nil
+}
+
+// DemoDimerization compares the mean populations of the dimerization model
+// simulated with the three methods to the solution of the reaction rate
+// equations, and the number of steps of the exact and approximate methods.
+func DemoDimerization(postpro bool) error {
+	system := DimerizationSystem{c1: 1, c2: 0.0002, c3: 0.5, c4: 0.04}
+	X0 := []float64{10000, 0, 0}
+	tmax := 5.0
+
+	algo := solver.NewRK4Solver()
+	_, err := algo.Solve(system.F, 0, X0, 1e-3, solver.StopAtTime(tmax), nil)
+	if err != nil {
+		return err
+	}
+	_, Xode := algo.Result()
+	log.Printf("reaction rate equations: %.2f\n", Xode)
+
+	methods := []struct {
+		name string
+		algo ssa.Simulator
+	}{
+		{"direct", ssa.NewDirectSimulator()},
+		{"next reaction", ssa.NewNextReactionSimulator()},
+		{"tau-leaping", ssa.NewTauLeapingSimulator()},
+	}
+	const runs = 50
+	for _, method := range methods {
+		mean := make([]float64, len(X0))
+		steps := 0.0
+		for seed := int64(1); seed <= runs; seed++ {
+			_, err := method.algo.Simulate(system.Network(), 0, X0, solver.StopAtTime(tmax), nil, ssa.Seed(seed))
+			if err != nil {
+				return err
+			}
+			_, X := method.algo.Result()
+			for i := range mean {
+				mean[i] += X[i] / runs
+			}
+			steps += float64(method.algo.Stats().AcceptedSteps) / runs
+		}
+		log.Printf("%-13s: mean populations %.2f in %.0f steps\n", method.name, mean, steps)
+		for i := range mean {
+			if math.Abs(mean[i]-Xode[i]) > 0.02*math.Max(Xode[i], 100) {
+				return fmt.Errorf("ERR: the mean populations of the %s method should be close to the reaction rate equations", method.name)
+			}
+		}
+	}
+	return nil
+}
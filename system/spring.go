@@ -49,6 +49,7 @@ import (
 	"log"
 	"math"
 
+	"github.com/gboulant/dingo-ode/linalg"
 	"github.com/gboulant/dingo-ode/solver"
 )
 
@@ -214,18 +215,21 @@ func DemoSpring03(postpro bool) error {
 	algo := solver.NewEulerSolver()
 	var recorder solver.RecorderTimeSeries
 	algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	log.Printf("Euler statistics:\n%s", algo.Stats())
 	timeseriesEuler := recorder.Series.Clone()
 	timeseriesEuler.ToCSV("out.spring03_simulation_euler.csv")
 
 	algo = solver.NewRK2Solver()
 	recorder.Series.Clear()
 	algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	log.Printf("RK2 statistics:\n%s", algo.Stats())
 	timeseriesRK2 := recorder.Series.Clone()
 	timeseriesRK2.ToCSV("out.spring03_simulation_rk2.csv")
 
 	algo = solver.NewRK4Solver()
 	recorder.Series.Clear()
 	algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	log.Printf("RK4 statistics:\n%s", algo.Stats())
 	timeseriesRK4 := recorder.Series.Clone()
 	timeseriesRK4.ToCSV("out.spring03_simulation_rk4.csv")
 
@@ -284,3 +288,181 @@ func DemoSpring03(postpro bool) error {
 	}
 	return err
 }
+
+// DemoSpring04 illustrates the detection of events. The maxima of the position
+// x are detected as the decreasing zero crossings of the velocity v, and the
+// pseudo-period of the damped spring is measured as the time between two
+// maxima. The measure is compared to the analytic pseudo-period 2*PI/w.
+func DemoSpring04(postpro bool) error {
+	dynsys := SpringSystem{
+		k: 2.0,
+		m: 1.0,
+		a: 0.1,
+	}
+
+	X0 := []float64{0.5, 0.0}
+	t0 := 0.0
+	h := 0.1
+	tmax := 60.0
+
+	maximum := solver.Event{
+		Name:      "maximum",
+		G:         func(t float64, X []float64) float64 { return X[1] },
+		Direction: -1,
+	}
+
+	algo := solver.NewRK4Solver()
+	_, err := algo.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), nil, solver.DetectEvents(maximum))
+	if err != nil {
+		return err
+	}
+
+	events := algo.Events()
+	if len(events) < 2 {
+		return fmt.Errorf("ERR: %d maxima detected, at least 2 expected", len(events))
+	}
+	var timeseries solver.TimeSeries
+	for _, event := range events {
+		timeseries.Append(solver.NewTimeData(event.T, event.X))
+	}
+	timeseries.ToCSVwithNames("out.spring04_maxima.csv", []string{"x", "v"})
+
+	period := (events[len(events)-1].T - events[0].T) / float64(len(events)-1)
+	w0 := math.Sqrt(dynsys.k / dynsys.m)
+	w := math.Sqrt(w0*w0 - dynsys.a*dynsys.a/(4*dynsys.m*dynsys.m))
+	log.Printf("Pseudo-period measured: %.6f, analytic: %.6f\n", period, 2*math.Pi/w)
+	if math.Abs(period-2*math.Pi/w) > 1e-4 {
+		return fmt.Errorf("ERR: the measured pseudo-period %.6f differs from %.6f", period, 2*math.Pi/w)
+	}
+	return nil
+}
+
+// DemoSpringMassMatrix solves the damped spring in its natural mass matrix
+// form, i.e. m*x” = -k*x - a*v written as:
+//
+//	|1 0| |x'|   |      v     |
+//	|0 m|.|v'| = | -k*x - a*v |
+//
+// with the implicit SDIRK2 method. The solution is compared to the solution
+// of the explicit form dX/dt = f(X,t) computed with the RK4 method.
+func DemoSpringMassMatrix(postpro bool) error {
+	dynsys := SpringSystem{
+		k: 2.0,
+		m: 3.0,
+		a: 0.1,
+	}
+
+	X0 := []float64{0.5, 0.0}
+	t0 := 0.0
+	h := 0.01
+	tmax := 20.0
+
+	problem := solver.MassMatrixProblem{
+		F: func(t float64, X []float64, dXdt []float64) error {
+			dXdt[0] = X[1]
+			dXdt[1] = -dynsys.k*X[0] - dynsys.a*X[1]
+			return nil
+		},
+		M: func(t float64, X []float64, M *linalg.Matrix) error {
+			M.Set(0, 0, 1)
+			M.Set(1, 1, dynsys.m)
+			return nil
+		},
+		ConstantMass: true,
+	}
+	algo := solver.NewSDIRK2Solver()
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.SolveMassMatrix(problem, t0, X0, h, solver.StopAtTime(tmax), &recorder)
+	if err != nil {
+		return err
+	}
+	log.Printf("SDIRK2 statistics:\n%s", algo.Stats())
+
+	reference := solver.NewRK4Solver()
+	var rkrecorder solver.RecorderTimeSeries
+	_, err = reference.Solve(dynsys.f, t0, X0, h, solver.StopAtTime(tmax), &rkrecorder)
+	if err != nil {
+		return err
+	}
+
+	maxError := 0.0
+	for i, data := range recorder.Series {
+		xref := rkrecorder.Series[i].GetState()[0]
+		maxError = math.Max(maxError, math.Abs(data.GetState()[0]-xref))
+	}
+	log.Printf("Max difference between the mass matrix and the explicit forms: %.3e\n", maxError)
+	if maxError > 1e-3 {
+		return fmt.Errorf("ERR: the mass matrix solution differs from the reference (%.3e)", maxError)
+	}
+	return recorder.Series.ToCSVwithNames("out.spring_massmatrix_data.csv", []string{"x", "v"})
+}
+
+// g implements the acceleration of the spring system in the second order form
+// x” = g(t,x,v)
+func (dynsys SpringSystem) g(t float64, X, V, A []float64) error {
+	A[0] = -X[0]*dynsys.k/dynsys.m - V[0]*dynsys.a/dynsys.m
+	return nil
+}
+
+// DemoSpringNystrom solves the damped spring defined in its second order form
+// x” = g(t,x,v), (1) with the RK4 method applied to the equivalent first order
+// system, which gives the same solution as the hand written function f, and
+// (2) with the Runge-Kutta-Nyström method RKN4, whose order of convergence is
+// checked.
+func DemoSpringNystrom(postpro bool) error {
+	dynsys := SpringSystem{
+		k: 2.0,
+		m: 1.0,
+		a: 0.1,
+	}
+	g := solver.SecondOrderFunction(dynsys.g)
+	Y0 := solver.SecondOrderState([]float64{0.5}, []float64{0.0})
+	t0 := 0.0
+	h := 0.1
+	tmax := 20.0
+
+	algo := solver.NewRK4Solver()
+	_, err := algo.Solve(dynsys.f, t0, Y0, h, solver.StopAtTime(tmax), nil)
+	if err != nil {
+		return err
+	}
+	_, X := algo.Result()
+	_, err = algo.SolveInPlace(g.FirstOrder(), t0, Y0, h, solver.StopAtTime(tmax), nil)
+	if err != nil {
+		return err
+	}
+	_, Y := algo.Result()
+	log.Printf("RK4 with f: x=%.10f v=%.10f, with the second order g: x=%.10f v=%.10f\n", X[0], X[1], Y[0], Y[1])
+	if math.Abs(X[0]-Y[0]) > 1e-12 || math.Abs(X[1]-Y[1]) > 1e-12 {
+		return fmt.Errorf("ERR: the first order reduction of g should be equivalent to f")
+	}
+
+	// The error of the RKN4 method is measured by comparison with a
+	// solution computed with a fine step size
+	rkn := solver.NewRKN4Solver()
+	solve := func(h float64) ([]float64, error) {
+		_, err := rkn.SolveInPlace(g.FirstOrder(), t0, Y0, h, solver.StopAtTime(tmax), nil)
+		_, Y := rkn.Result()
+		return Y, err
+	}
+	h = 0.025
+	Yref, err := solve(h / 64)
+	if err != nil {
+		return err
+	}
+	Y1, err := solve(h)
+	if err != nil {
+		return err
+	}
+	Y2, err := solve(h / 2)
+	if err != nil {
+		return err
+	}
+	e1, e2 := math.Abs(Y1[0]-Yref[0]), math.Abs(Y2[0]-Yref[0])
+	order := math.Log2(e1 / e2)
+	log.Printf("RKN4: error %.3e (h=%g), %.3e (h=%g), order %.2f\n", e1, h, e2, h/2, order)
+	if order < 3.7 {
+		return fmt.Errorf("ERR: the order of the RKN4 method should be 4")
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/sde"
+	"github.com/gboulant/dingo-ode/solver"
+)
+
+/*
+
+The stochastic versions of the systems add a noise to the deterministic
+equations dX/dt = f(t,X), that become stochastic differential equations (SDE)
+in the Itô form:
+
+	dX = f(t,X).dt + g(t,X).dW
+
+where W is a vector of independent Wiener processes. For the Volterra system,
+the noise models the random fluctuations of the reproduction and death rates,
+and is proportional to the populations (multiplicative noise): g = (s*x, s*y).
+For the laser system, the noise models the spontaneous emission, and is added
+to the intensity L independently of the state (additive noise).
+
+The geometric brownian motion dX = mu*X.dt + sigma*X.dW is used to check the
+convergence of the methods, because its exact solution is known from the value
+W(t) of the Wiener process:
+
+	X = X0*exp((mu - sigma^2/2)*t + sigma*W(t))
+
+*/
+
+// StochasticVolterraSystem modelises a prey/predator system with a noise
+// proportional to the populations.
+type StochasticVolterraSystem struct {
+	VolterraSystem
+	s float64 // intensity of the noise
+}
+
+// Diffusion implements the diffusion g of the stochastic Volterra system
+// (diagonal noise)
+func (system StochasticVolterraSystem) Diffusion(t float64, X []float64, G []float64) error {
+	G[0] = system.s * X[0]
+	G[1] = system.s * X[1]
+	return nil
+}
+
+// Problem returns the SDE of the stochastic Volterra system
+func (system StochasticVolterraSystem) Problem() sde.Problem {
+	return sde.Problem{
+		Drift:     solver.Function(system.F).InPlace(),
+		Diffusion: system.Diffusion,
+		Noise:     sde.DiagonalNoise,
+	}
+}
+
+// DemoStochasticVolterra solves the stochastic Volterra system with the
+// Milstein method, and checks that the solving process is reproducible (same
+// result for the same seed).
+func DemoStochasticVolterra(postpro bool) error {
+	system := StochasticVolterraSystem{
+		VolterraSystem: VolterraSystem{a: 2. / 3., b: 4. / 3., d: 1., g: 1.},
+		s:              0.05,
+	}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	algo := sde.NewMilsteinSolver()
+
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.Solve(system.Problem(), t0, X0, step/10, solver.StopAtTime(tmax), &recorder, sde.Seed(42))
+	if err != nil {
+		return err
+	}
+	_, X1 := algo.Result()
+	_, err = algo.Solve(system.Problem(), t0, X0, step/10, solver.StopAtTime(tmax), nil, sde.Seed(42))
+	if err != nil {
+		return err
+	}
+	_, X2 := algo.Result()
+	_, err = algo.Solve(system.Problem(), t0, X0, step/10, solver.StopAtTime(tmax), nil, sde.Seed(43))
+	if err != nil {
+		return err
+	}
+	_, X3 := algo.Result()
+	log.Printf("Final populations with the seed 42: %.6f (again: %.6f), with the seed 43: %.6f\n", X1, X2, X3)
+	if X1[0] != X2[0] || X1[1] != X2[1] || (X1[0] == X3[0] && X1[1] == X3[1]) {
+		return fmt.Errorf("ERR: the solving process is not reproducible with the seed")
+	}
+	return recorder.Series.ToCSVwithNames("out.volterra_sde_data.csv", []string{"x", "y"})
+}
+
+// StochasticLaserSystem modelises a laser with an additive noise on the light
+// intensity (spontaneous emission).
+type StochasticLaserSystem struct {
+	LaserSystem
+	s float64 // intensity of the noise
+}
+
+// Diffusion implements the diffusion g of the stochastic laser system
+// (diagonal and additive noise)
+func (system StochasticLaserSystem) Diffusion(t float64, X []float64, G []float64) error {
+	G[0] = system.s
+	G[1] = 0
+	G[2] = 0
+	return nil
+}
+
+// Problem returns the SDE of the stochastic laser system
+func (system StochasticLaserSystem) Problem() sde.Problem {
+	return sde.Problem{
+		Drift:     solver.Function(system.F).InPlace(),
+		Diffusion: system.Diffusion,
+		Noise:     sde.DiagonalNoise,
+		Additive:  true,
+	}
+}
+
+// DemoStochasticLaser solves the stochastic laser system in the chaotic
+// configuration with the SRA1 method (strong order 1.5 for the additive
+// noise).
+func DemoStochasticLaser(postpro bool) error {
+	system := StochasticLaserSystem{LaserSystem: configurations["chaos"], s: 1e-2}
+	T := 2 * math.Pi / system.W
+	t0, X0, h, tmax := 0.0, []float64{1.0, 1.0, 0.0}, T/80, 20*T
+
+	algo := sde.NewSRA1Solver()
+	var recorder solver.RecorderTimeSeries
+	controller := solver.Or(solver.StopAtTime(tmax), solver.StopOnNonFinite())
+	_, err := algo.Solve(system.Problem(), t0, X0, h, controller, &recorder)
+	if err != nil {
+		return err
+	}
+	log.Printf("SRA1 statistics:\n%s", algo.Stats())
+	return recorder.Series.ToCSVwithNames("out.laser_sde_data.csv", []string{"L", "D", "Z"})
+}
+
+// GeometricBrownianMotion modelises the SDE dX = mu*X.dt + sigma*X.dW
+type GeometricBrownianMotion struct {
+	mu, sigma float64
+}
+
+// Problem returns the SDE of the geometric brownian motion
+func (system GeometricBrownianMotion) Problem() sde.Problem {
+	return sde.Problem{
+		Drift: func(t float64, X []float64, dXdt []float64) error {
+			dXdt[0] = system.mu * X[0]
+			return nil
+		},
+		Diffusion: func(t float64, X []float64, G []float64) error {
+			G[0] = system.sigma * X[0]
+			return nil
+		},
+		Noise: sde.ScalarNoise,
+	}
+}
+
+// solution returns the exact solution at time t for the value W of the
+// Wiener process
+func (system GeometricBrownianMotion) solution(x0, t, W float64) float64 {
+	return x0 * math.Exp((system.mu-system.sigma*system.sigma/2)*t+system.sigma*W)
+}
+
+// strongError returns the mean absolute error at t=1 of the solver, over a
+// set of paths of the Wiener process, and the mean number of steps.
+func (system GeometricBrownianMotion) strongError(algo sde.Solver, h float64, opts ...sde.Option) (float64, float64, error) {
+	const paths = 200
+	x0 := 1.0
+	e, steps := 0.0, 0.0
+	for seed := int64(1); seed <= paths; seed++ {
+		options := append([]sde.Option{sde.Seed(seed)}, opts...)
+		_, err := algo.Solve(system.Problem(), 0, []float64{x0}, h, solver.StopAtTime(1), nil, options...)
+		if err != nil {
+			return 0, 0, err
+		}
+		t, X := algo.Result()
+		e += math.Abs(X[0] - system.solution(x0, t, algo.Wiener()[0]))
+		steps += float64(algo.Stats().AcceptedSteps)
+	}
+	return e / paths, steps / paths, nil
+}
+
+// DemoSDEConvergence checks the strong order of convergence of the
+// Euler-Maruyama (0.5) and the Milstein (1) methods on the geometric brownian
+// motion, and the accuracy of the adaptive step size.
+func DemoSDEConvergence(postpro bool) error {
+	gbm := GeometricBrownianMotion{mu: 0.5, sigma: 0.5}
+	methods := []struct {
+		name  string
+		algo  sde.Solver
+		order float64
+	}{
+		{"euler-maruyama", sde.NewEulerMaruyamaSolver(), 0.5},
+		{"milstein", sde.NewMilsteinSolver(), 1},
+	}
+	for _, method := range methods {
+		h1, h2 := 1.0/16, 1.0/256
+		e1, _, err := gbm.strongError(method.algo, h1)
+		if err != nil {
+			return err
+		}
+		e2, _, err := gbm.strongError(method.algo, h2)
+		if err != nil {
+			return err
+		}
+		order := math.Log(e1/e2) / math.Log(h1/h2)
+		log.Printf("%-15s: error %.3e (h=%g), %.3e (h=%g), order %.2f\n", method.name, e1, h1, e2, h2, order)
+		if math.Abs(order-method.order) > 0.2 {
+			return fmt.Errorf("ERR: the order of the %s method should be %g", method.name, method.order)
+		}
+	}
+
+	algo := sde.NewMilsteinSolver()
+	previous := math.Inf(1)
+	for _, tol := range []float64{1e-2, 1e-3, 1e-4} {
+		e, steps, err := gbm.strongError(algo, 0.1, sde.Adaptive(tol, tol))
+		if err != nil {
+			return err
+		}
+		log.Printf("adaptive milstein: error %.3e with %.0f steps (tolerance %g)\n", e, steps, tol)
+		if e > previous {
+			return fmt.Errorf("ERR: the error of the adaptive method should decrease with the tolerance")
+		}
+		previous = e
+	}
+	return nil
+}
@@ -25,8 +25,10 @@ are implemented using the System interface (watertank, volterra).
 */
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 
 	"github.com/gboulant/dingo-ode/solver"
 )
@@ -40,6 +42,25 @@ type System interface {
 	GetDefaultInput() (t0 float64, X0 []float64, step float64, tmax float64)
 }
 
+// InPlaceSystem is an optional interface for a System that also implements the
+// allocation-free variant of the rate function F (see solver.InPlaceFunction).
+// The SystemSolver uses FInPlace instead of F when the System implements it.
+type InPlaceSystem interface {
+	System
+	// FInPlace should write the rate dX/dt = F(X,t) in the slice dXdt
+	FInPlace(t float64, X []float64, dXdt []float64) error
+}
+
+// DiscontinuousSystem is an optional interface for a System whose rate
+// function F is discontinuous at known times (e.g. a forcing term switched on
+// or off). The SystemSolver lands exactly on these times (see
+// solver.Breakpoints), so that no step straddles a discontinuity.
+type DiscontinuousSystem interface {
+	System
+	// Breakpoints should return the times of the discontinuities of F
+	Breakpoints() []float64
+}
+
 // SystemSolver is a tool that helps the setup and execution of a diego solver
 // on a specified System. It solves the initial value problem with a stop
 // condition of type stopAtTime, and with a timeseries recorder.
@@ -61,10 +82,30 @@ func NewSystemSolver(system System) SystemSolver {
 	return s
 }
 
-// Solve executes the Solve function of the solver of the SystemSolver
-func (s *SystemSolver) Solve(t0 float64, X0 []float64, h, tmax float64) error {
+// Solve executes the Solve function of the solver of the SystemSolver. The
+// options are transmitted to the solver.
+func (s *SystemSolver) Solve(t0 float64, X0 []float64, h, tmax float64, opts ...solver.Option) error {
+	return s.SolveContext(context.Background(), t0, X0, h, tmax, opts...)
+}
+
+// SolveContext executes the SolveContext function of the solver of the
+// SystemSolver, i.e. the solving process is aborted when the context is
+// canceled or when its deadline is exceeded. The timeseries recorded until the
+// interruption is kept in the SystemSolver. If tmax < t0, the system is
+// integrated backward in time, whatever the sign of the step size h.
+func (s *SystemSolver) SolveContext(ctx context.Context, t0 float64, X0 []float64, h, tmax float64, opts ...solver.Option) error {
+	h = math.Copysign(h, tmax-t0)
 	controller := solver.StopAtTime(tmax)
-	n, err := s.solver.Solve(s.system.F, t0, X0, h, controller, &s.recorder)
+	if system, ok := s.system.(DiscontinuousSystem); ok {
+		opts = append([]solver.Option{solver.Breakpoints(system.Breakpoints()...)}, opts...)
+	}
+	var n uint64
+	var err error
+	if system, ok := s.system.(InPlaceSystem); ok {
+		n, err = s.solver.SolveInPlaceContext(ctx, system.FInPlace, t0, X0, h, controller, &s.recorder, opts...)
+	} else {
+		n, err = s.solver.SolveContext(ctx, s.system.F, t0, X0, h, controller, &s.recorder, opts...)
+	}
 	log.Printf("DBG: number of iterations: %d\n", n)
 	return err
 }
@@ -81,6 +122,17 @@ func (s SystemSolver) SaveTimeseries(csvpath string, names []string) error {
 	return s.Series().ToCSVwithNames(csvpath, names)
 }
 
+// Stats returns the statistics of the last solving process of the SystemSolver
+func (s SystemSolver) Stats() solver.Stats {
+	return s.solver.Stats()
+}
+
+// StopReason returns the reason why the last solving process of the
+// SystemSolver stopped
+func (s SystemSolver) StopReason() solver.StopReason {
+	return s.solver.StopReason()
+}
+
 // PlotTimeSeries plots the current timeseries and assign the specified names to
 // the curves. WARN: this function uses an external python library (matplotlib)
 // that should be installed on your system.
@@ -1,6 +1,12 @@
 package system
 
-import "math"
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/gboulant/dingo-ode/solver"
+)
 
 /*
 
@@ -83,3 +89,130 @@ func DemoVolterra(postpro bool) error {
 	}
 	return err
 }
+
+// DemoVolterraBackward illustrates the backward integration. The volterra
+// system is integrated forward from (t0,X0) to tmax, and then backward from the
+// final state to t0: the result of the backward integration should be the
+// initial state X0 (up to the numerical errors).
+func DemoVolterraBackward(postpro bool) error {
+	system := VolterraSystem{a: 2. / 3., b: 4. / 3., d: 1., g: 1.}
+	t0, X0, step, tmax := system.GetDefaultInput()
+
+	algo := solver.NewRK4Solver()
+	_, err := algo.Solve(system.F, t0, X0, step, solver.StopAtTime(tmax), nil)
+	if err != nil {
+		return err
+	}
+	t1, X1 := algo.Result()
+
+	var recorder solver.RecorderTimeSeries
+	_, err = algo.Solve(system.F, t1, X1, -step, solver.StopAtTime(t0), &recorder)
+	if err != nil {
+		return err
+	}
+	t, X := algo.Result()
+
+	log.Printf("Forward : t: %.4f, x: %.6f, y: %.6f\n", t1, X1[0], X1[1])
+	log.Printf("Backward: t: %.4f, x: %.6f, y: %.6f\n", t, X[0], X[1])
+	log.Printf("Initial : t: %.4f, x: %.6f, y: %.6f\n", t0, X0[0], X0[1])
+	if math.Abs(t-t0) > 1e-8 || math.Abs(X[0]-X0[0]) > 1e-4 || math.Abs(X[1]-X0[1]) > 1e-4 {
+		return fmt.Errorf("ERR: the backward integration ends at t=%v, X=%v instead of t=%v, X=%v", t, X, t0, X0)
+	}
+
+	// The backward timeseries is recorded by decreasing time
+	timeseries := recorder.Series
+	timeseries.Sort()
+	return timeseries.ToCSVwithNames("out.volterra_backward_data.csv", []string{"x", "y"})
+}
+
+// DemoVolterraStopReason illustrates the controller combinators: the solving
+// process stops when the population of preys is above its equilibrium value
+// while the population of predators is below its equilibrium value, during 3
+// consecutive iterations. The reason of the stop (the controller that stops
+// the process) is then reported by the solver. The same condition is then
+// applied after a transient regime of one period.
+func DemoVolterraStopReason(postpro bool) error {
+	system := VolterraSystem{a: 2. / 3., b: 4. / 3., d: 1., g: 1.}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	xe, ye := system.g/system.d, system.a/system.b
+	period := 2. * math.Pi / math.Sqrt(system.a*system.b)
+
+	preysAbove := solver.Controller(func(t float64, X []float64) (bool, error) { return X[0] > xe, nil })
+	predatorsBelow := solver.Controller(func(t float64, X []float64) (bool, error) { return X[1] < ye, nil })
+	outbreak := solver.Debounce(3, solver.And(
+		solver.Named("preys above", preysAbove),
+		solver.Named("predators below", predatorsBelow),
+	))
+
+	algo := solver.NewRK4Solver()
+	for _, transient := range []uint64{0, uint64(period / step)} {
+		controller := solver.Or(
+			solver.Named("time", solver.StopAtTime(tmax)),
+			solver.Named("outbreak", solver.AfterN(transient, outbreak)),
+		)
+		_, err := algo.Solve(system.F, t0, X0, step, controller, nil)
+		if err != nil {
+			return err
+		}
+		reason := algo.StopReason()
+		_, X := algo.Result()
+		log.Printf("Stop after %d iterations of transient: %v\n", transient, reason)
+		if reason.Controller != "outbreak" || X[0] < xe || X[1] > ye {
+			return fmt.Errorf("ERR: the process should be stopped by the outbreak condition")
+		}
+		if transient > 0 && reason.T < period {
+			return fmt.Errorf("ERR: the process should not be stopped during the transient (t=%g)", reason.T)
+		}
+	}
+	return nil
+}
+
+// DemoVolterraStopTime illustrates the combinators And and Debounce applied to
+// the controller StopAtTime. The process stops 3 iterations after the middle
+// time tmid (Debounce), or at the first iteration after tmid where the
+// population of preys is above its equilibrium value (And). The same
+// controllers are used for a forward integration from t0 and a backward
+// integration from tmax, that both stop after tmid in their time direction.
+func DemoVolterraStopTime(postpro bool) error {
+	system := VolterraSystem{a: 2. / 3., b: 4. / 3., d: 1., g: 1.}
+	t0, X0, step, tmax := system.GetDefaultInput()
+	xe := system.g / system.d
+	tmid := (t0 + tmax) / 2
+
+	algo := solver.NewRK4Solver()
+	_, err := algo.Solve(system.F, t0, X0, step, solver.StopAtTime(tmax), nil)
+	if err != nil {
+		return err
+	}
+	t1, X1 := algo.Result()
+
+	preysAbove := solver.Controller(func(t float64, X []float64) (bool, error) { return X[0] > xe, nil })
+	controllers := []struct {
+		name       string
+		controller solver.ProcessController
+	}{
+		{"debounce", solver.Debounce(3, solver.StopAtTime(tmid))},
+		{"and", solver.And(solver.StopAtTime(tmid), solver.Named("preys above", preysAbove))},
+	}
+	for _, c := range controllers {
+		for _, direction := range []float64{1, -1} {
+			ts, Xs := t0, X0
+			if direction < 0 {
+				ts, Xs = t1, X1
+			}
+			_, err := algo.Solve(system.F, ts, Xs, direction*step, c.controller, nil)
+			if err != nil {
+				return err
+			}
+			reason := algo.StopReason()
+			log.Printf("%-8s (direction %+.0f): %v\n", c.name, direction, reason)
+			if (reason.T-tmid)*direction <= 0 || (reason.T-tmid)*direction > tmax-tmid {
+				return fmt.Errorf("ERR: the controller %s should stop the process after tmid=%g (t=%g)", c.name, tmid, reason.T)
+			}
+			if c.name == "debounce" && math.Abs(reason.T-(tmid+3*direction*step)) > step/2 {
+				return fmt.Errorf("ERR: the controller %s should stop the process 3 iterations after tmid=%g (t=%g)", c.name, tmid, reason.T)
+			}
+		}
+	}
+	return nil
+}
@@ -2,7 +2,12 @@ package system
 
 import (
 	"fmt"
+	"log"
+	"math"
 	"math/rand"
+	"runtime"
+
+	"github.com/gboulant/dingo-ode/solver"
 )
 
 /*
@@ -92,11 +97,18 @@ type CascadingWaterTankSystem struct {
 // F implements the function f of the chained watertank system
 func (system CascadingWaterTankSystem) F(t float64, X []float64) ([]float64, error) {
 	dXdt := make([]float64, len(X))
+	err := system.FInPlace(t, X, dXdt)
+	return dXdt, err
+}
+
+// FInPlace implements the allocation-free variant of the function F, so that
+// the CascadingWaterTankSystem is an InPlaceSystem.
+func (system CascadingWaterTankSystem) FInPlace(t float64, X []float64, dXdt []float64) error {
 	dXdt[0] = system.d - system.a*X[0]
 	for i := 1; i < len(X); i++ {
 		dXdt[i] = system.a * (X[i-1] - X[i])
 	}
-	return dXdt, nil
+	return nil
 }
 
 func (system CascadingWaterTankSystem) GetDefaultInput() (t0 float64, X0 []float64, step float64, tmax float64) {
@@ -149,3 +161,238 @@ func DemoCascadingWaterTank(postpro bool) error {
 	}
 	return err
 }
+
+// DemoAllocations checks that the iterations of a solving process are free of
+// memory allocations when the system is defined by an InPlaceFunction. The
+// allocations are counted for a short and a long simulation of a large
+// cascading water tank system: they should be the same, whatever the number of
+// iterations. The allocations per iteration of the solvers are also reported
+// by the benchmarks of the package solver (go test -bench . ./solver).
+func DemoAllocations(postpro bool) error {
+	watertank := CascadingWaterTankSystem{d: 2, a: 1, n: 1000}
+	t0, X0, step, _ := watertank.GetDefaultInput()
+	algo := solver.NewRK4Solver()
+
+	mallocs := func(nbSteps int) (uint64, error) {
+		tmax := t0 + float64(nbSteps)*step
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		before := stats.Mallocs
+		_, err := algo.SolveInPlace(watertank.FInPlace, t0, X0, step, solver.StopAtTime(tmax), nil)
+		runtime.ReadMemStats(&stats)
+		return stats.Mallocs - before, err
+	}
+
+	// The first run allocates the workspace of the solver
+	if _, err := mallocs(10); err != nil {
+		return err
+	}
+	nshort, err := mallocs(100)
+	if err != nil {
+		return err
+	}
+	nlong, err := mallocs(10000)
+	if err != nil {
+		return err
+	}
+	log.Printf("Allocations for 100 steps: %d, for 10000 steps: %d\n", nshort, nlong)
+	if nlong > nshort {
+		return fmt.Errorf("ERR: %d allocations for 9900 additional steps", nlong-nshort)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// DemoWaterTankRelay simulates a water tank whose input pump is controlled by a
+// relay: the pump is switched off when the water height exceeds a high level,
+// and switched on when the height goes below a low level. The system is then a
+// hybrid system with two modes (pump on, pump off) switched by two events.
+func DemoWaterTankRelay(postpro bool) error {
+	watertank := WaterTankSystem{d: 2, a: 1}
+	hlow, hhigh := 1.0, 1.5
+
+	pumpOn := watertank.F
+	pumpOff := func(t float64, X []float64) ([]float64, error) {
+		return []float64{-watertank.a * X[0]}, nil
+	}
+	const modeOn, modeOff = 0, 1
+
+	high := solver.Event{
+		Name:      "high",
+		G:         func(t float64, X []float64) float64 { return X[0] - hhigh },
+		Direction: 1,
+		Reset:     func(t float64, X []float64, mode int) (int, error) { return modeOff, nil },
+	}
+	low := solver.Event{
+		Name:      "low",
+		G:         func(t float64, X []float64) float64 { return X[0] - hlow },
+		Direction: -1,
+		Reset:     func(t float64, X []float64, mode int) (int, error) { return modeOn, nil },
+	}
+
+	t0 := 0.0
+	X0 := []float64{0.0}
+	step := 0.05
+	tmax := 20.0
+
+	events := []solver.Event{high, low}
+	algo := solver.NewRK4Solver()
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.Solve(pumpOn, t0, X0, step, solver.StopAtTime(tmax), &recorder,
+		solver.Modes(pumpOff), solver.DetectEvents(events...))
+	if err != nil {
+		return err
+	}
+
+	for _, transition := range algo.Transitions() {
+		log.Printf("t: %.4f, event: %s, mode: %d -> %d\n", transition.T,
+			events[transition.Event].Name, transition.From, transition.To)
+	}
+	if len(algo.Transitions()) < 2 {
+		return fmt.Errorf("ERR: %d transitions, the relay should switch the pump", len(algo.Transitions()))
+	}
+
+	return recorder.Series.ToCSVwithNames("out.watertank_relay_data.csv", []string{"h"})
+}
+
+// ----------------------------------------------------------------------------
+
+// ScheduledWaterTankSystem modelises a water tank whose input pump is switched
+// on and off at scheduled times: the pump is on at the beginning, and it is
+// switched at each time of the schedule. The rate function is then
+// discontinuous at the times of the schedule, and the system is a
+// DiscontinuousSystem.
+type ScheduledWaterTankSystem struct {
+	d, a     float64
+	schedule []float64
+}
+
+// pumpOn returns true if the pump is on at time t
+func (system ScheduledWaterTankSystem) pumpOn(t float64) bool {
+	on := true
+	for _, ts := range system.schedule {
+		if t >= ts {
+			on = !on
+		}
+	}
+	return on
+}
+
+// F implements the function f of the scheduled watertank system
+func (system ScheduledWaterTankSystem) F(t float64, X []float64) ([]float64, error) {
+	dhdt := -system.a * X[0]
+	if system.pumpOn(t) {
+		dhdt += system.d
+	}
+	return []float64{dhdt}, nil
+}
+
+// Breakpoints returns the times of the schedule, so that the
+// ScheduledWaterTankSystem is a DiscontinuousSystem.
+func (system ScheduledWaterTankSystem) Breakpoints() []float64 {
+	return system.schedule
+}
+
+func (system ScheduledWaterTankSystem) GetDefaultInput() (t0 float64, X0 []float64, step float64, tmax float64) {
+	t0 = 0.0
+	X0 = []float64{0.0}
+	step = 0.3
+	tmax = 8.0
+	return
+}
+
+// solution returns the analytical solution at time t for the initial
+// condition (t0=0,h=h0), obtained by chaining the exponential solutions of the
+// successive phases of the schedule.
+func (system ScheduledWaterTankSystem) solution(h0 float64, t float64) float64 {
+	h, ts := h0, 0.0
+	on := true
+	for _, tnext := range append(system.schedule, math.Inf(1)) {
+		dt := math.Min(t, tnext) - ts
+		he := 0.0
+		if on {
+			he = system.d / system.a
+		}
+		h = he + (h-he)*math.Exp(-system.a*dt)
+		if t <= tnext {
+			break
+		}
+		ts, on = tnext, !on
+	}
+	return h
+}
+
+// DemoWaterTankSchedule simulates a water tank whose pump is switched on and
+// off at scheduled times, with a step size that does not fit the schedule. The
+// solution is compared to the analytical solution, with and without landing
+// on the breakpoints of the schedule: the steps that straddle a discontinuity
+// degrade the accuracy of the RK4 method to first order.
+func DemoWaterTankSchedule(postpro bool) error {
+	watertank := ScheduledWaterTankSystem{d: 2, a: 1, schedule: []float64{2, 5}}
+	t0, X0, step, tmax := watertank.GetDefaultInput()
+
+	maxError := func(series *solver.TimeSeries) float64 {
+		e := 0.0
+		for _, data := range *series {
+			h := watertank.solution(X0[0], data.GetTime())
+			e = math.Max(e, math.Abs(data.GetState()[0]-h))
+		}
+		return e
+	}
+
+	// Without breakpoints (the watertank is wrapped in a simple System)
+	syssolver := NewSystemSolver(struct{ System }{watertank})
+	if err := syssolver.Solve(t0, X0, step, tmax); err != nil {
+		return err
+	}
+	errorWithout := maxError(syssolver.Series())
+
+	// With the breakpoints of the DiscontinuousSystem
+	syssolver = NewSystemSolver(watertank)
+	if err := syssolver.Solve(t0, X0, step, tmax); err != nil {
+		return err
+	}
+	errorWith := maxError(syssolver.Series())
+
+	log.Printf("Max error without breakpoints: %.3e, with breakpoints: %.3e\n", errorWithout, errorWith)
+	if errorWith > 1e-4 || errorWith > errorWithout {
+		return fmt.Errorf("ERR: the error with breakpoints (%.3e) is too large", errorWith)
+	}
+
+	return syssolver.SaveTimeseries("out.watertank_schedule_data.csv", []string{"h"})
+}
+
+// DemoWaterTankSteadyState solves the cascading water tank system until it
+// reaches its steady state, instead of guessing a time tmax long enough. At the
+// equilibrium, all the tanks have the height d/a.
+func DemoWaterTankSteadyState(postpro bool) error {
+	watertank := CascadingWaterTankSystem{d: 2, a: 1, n: 8}
+	t0, X0, step, _ := watertank.GetDefaultInput()
+
+	detector := solver.NewSteadyStateDetector(1e-6, 1e-6, 1.0)
+	controller := solver.MultiController(solver.StopAtTime(1000), detector)
+	algo := solver.NewRK4Solver()
+	var recorder solver.RecorderTimeSeries
+	_, err := algo.SolveInPlace(watertank.FInPlace, t0, X0, step, controller, &recorder)
+	if err != nil {
+		return err
+	}
+	if !detector.Detected {
+		return fmt.Errorf("ERR: no steady state detected")
+	}
+
+	log.Printf("Steady state reached at t=%.4f: %.6f\n", detector.T, detector.X)
+	he := watertank.d / watertank.a
+	for i, h := range detector.X {
+		if math.Abs(h-he) > 1e-4 {
+			return fmt.Errorf("ERR: the height of the tank %d (%g) differs from the equilibrium %g", i, h, he)
+		}
+	}
+
+	names := make([]string, len(X0))
+	for i := 0; i < len(names); i++ {
+		names[i] = fmt.Sprintf("h%d", i)
+	}
+	return recorder.Series.ToCSVwithNames("out.watertank_steady_data.csv", names)
+}